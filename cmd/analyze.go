@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/zheng/crag/internal/analyzer"
 	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/snapshot"
 	"github.com/zheng/crag/internal/storage"
 )
 
@@ -15,12 +19,34 @@ func analyzeCmd() *cobra.Command {
 	var incremental bool
 	var gitBase string
 	var remote bool
+	var includeModules []string
+	var externalIfaces []string
+	var snapshotName string
+	var snapshotDir string
+	var noSync bool
 
 	cmd := &cobra.Command{
 		Use:   "analyze [project-path]",
 		Short: "分析 Go 项目并构建调用图",
-		Args:  cobra.MaximumNArgs(1),
+		Long: `分析 Go 项目并构建调用图，写入数据库。
+
+使用 --snapshot 将图谱保存为命名快照（快照目录中的独立数据库，默认 .crag.snapshots/），
+不会覆盖主数据库；之后可用 crag diff 对比两个快照。快照名是 git 提交、标签或分支时，
+会在临时 worktree 中分析该提交，否则分析当前工作区。
+
+增量模式 (-i) 对比源文件内容哈希与上次分析的记录（不依赖 git），重新分析内容变更的包
+以及直接或间接导入它们的包，其余节点沿用原有记录；结果与全量分析一致。
+旧数据库没有文件记录时，改用 git diff 检测变更。
+
+示例：
+  crag analyze .
+  crag analyze . -i                      # 增量更新
+  crag analyze . --snapshot v1.2.0       # 分析标签 v1.2.0 并保存为快照
+  crag analyze . --snapshot before-refactor
+  crag diff v1.2.0 before-refactor`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			start := time.Now()
 			projectPath := "."
 			if len(args) > 0 {
 				projectPath = args[0]
@@ -30,39 +56,95 @@ func analyzeCmd() *cobra.Command {
 				DbPath = outputPath
 			}
 
+			// Snapshot mode: write a separate named database, optionally for a git commit
+			analysisPath := projectPath
+			if snapshotName != "" {
+				if incremental {
+					return fmt.Errorf("--snapshot 不能与 --incremental 同时使用")
+				}
+				if snapshotDir == "" {
+					snapshotDir = snapshot.Dir(DbPath)
+				}
+				if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+					return fmt.Errorf("创建快照目录失败: %w", err)
+				}
+				DbPath = snapshot.Path(snapshotDir, snapshotName)
+
+				if commit, err := analyzer.ResolveGitRef(projectPath, snapshotName); err == nil {
+					fmt.Printf("快照 %s: 分析提交 %s\n", snapshotName, commit)
+					worktreePath, cleanup, err := analyzer.CreateWorktree(projectPath, commit)
+					if err != nil {
+						return err
+					}
+					defer cleanup()
+					analysisPath = worktreePath
+				} else {
+					fmt.Printf("快照 %s: 分析当前工作区\n", snapshotName)
+				}
+			}
+
+			// Open database
+			db, err := storage.Open(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			// Hash the source files; incremental mode compares them with the last analysis
+			var previousFiles []*graph.SourceFile
+			if incremental {
+				if previousFiles, err = db.GetSourceFiles(); err != nil {
+					return fmt.Errorf("读取文件记录失败: %w", err)
+				}
+			}
+			files, err := analyzer.ScanSourceFiles(analysisPath, previousFiles)
+			if err != nil {
+				return fmt.Errorf("扫描源文件失败: %w", err)
+			}
+
 			// Incremental mode: detect changed files
-			var changedPackages []string
+			var changedFiles []string
+			var gitChangedDirs []string // set when falling back to git
 			if incremental {
-				// 如果启用 remote 模式，自动获取远程分支作为 base
-				if remote {
-					remoteBranch, err := analyzer.GetRemoteTrackingBranch(projectPath)
+				if len(previousFiles) > 0 {
+					fmt.Println("对比源文件哈希...")
+					changedFiles = analyzer.ChangedSourceFiles(previousFiles, files)
+				} else {
+					fmt.Println("数据库中没有文件哈希记录，改用 git 检测变更...")
+					// 如果启用 remote 模式，自动获取远程分支作为 base
+					if remote {
+						remoteBranch, err := analyzer.GetRemoteTrackingBranch(projectPath)
+						if err != nil {
+							fmt.Printf("警告: 无法获取远程分支: %v，将使用默认 HEAD\n", err)
+						} else {
+							gitBase = remoteBranch
+							fmt.Printf("对比远程分支: %s\n", remoteBranch)
+						}
+					}
+
+					changes, err := analyzer.GetGitChanges(projectPath, gitBase)
 					if err != nil {
-						fmt.Printf("警告: 无法获取远程分支: %v，将使用默认 HEAD\n", err)
+						fmt.Printf("警告: 无法获取 git 变更，将执行全量分析: %v\n", err)
+						incremental = false
 					} else {
-						gitBase = remoteBranch
-						fmt.Printf("对比远程分支: %s\n", remoteBranch)
+						changedFiles = changes.ChangedFiles
+						gitChangedDirs = changes.ChangedPackages
 					}
 				}
-
-				fmt.Println("检测 git 变更...")
-				changes, err := analyzer.GetGitChanges(projectPath, gitBase)
-				if err != nil {
-					fmt.Printf("警告: 无法获取 git 变更，将执行全量分析: %v\n", err)
-					incremental = false
-				} else if !changes.HasChanges() {
+			}
+			if incremental {
+				if len(changedFiles) == 0 {
 					fmt.Println("没有检测到 Go 文件变更，跳过分析")
 					return nil
-				} else {
-					fmt.Printf("检测到 %d 个变更文件，涉及 %d 个包:\n", len(changes.ChangedFiles), len(changes.ChangedPackages))
-					for _, f := range changes.ChangedFiles {
-						fmt.Printf("  - %s\n", f)
-					}
-					changedPackages = changes.ChangedPackages
+				}
+				fmt.Printf("检测到 %d 个变更文件:\n", len(changedFiles))
+				for _, f := range changedFiles {
+					fmt.Printf("  - %s\n", f)
 				}
 			}
 
 			// Load packages
-			pkgs, err := analyzer.LoadPackages(projectPath)
+			pkgs, err := analyzer.LoadPackages(analysisPath, includeModules...)
 			if err != nil {
 				return fmt.Errorf("加载包失败: %w", err)
 			}
@@ -72,27 +154,35 @@ func analyzeCmd() *cobra.Command {
 			if len(pkgs) == 0 {
 				return fmt.Errorf("未找到有效的 Go 包")
 			}
+			analyzer.SetFilePackages(files, analysisPath, pkgs)
 
-			// Convert changed package dirs to full package paths for incremental mode
-			if incremental && len(changedPackages) > 0 {
-				fullPkgPaths := make([]string, 0, len(changedPackages))
-				for _, relativePath := range changedPackages {
-					suffix := strings.TrimPrefix(relativePath, "./")
-					if suffix == "" {
-						suffix = "."
-					}
+			// Incremental mode: re-analyze the changed packages and every package importing them
+			var targetPackages []string
+			if incremental {
+				var changedPackages []string
+				if gitChangedDirs == nil {
+					changedPackages = analyzer.ChangedPackages(changedFiles, previousFiles, files)
+				} else {
+					// Convert changed package dirs to full package paths
+					for _, relativePath := range gitChangedDirs {
+						suffix := strings.TrimPrefix(relativePath, "./")
+						if suffix == "" {
+							suffix = "."
+						}
 
-					for _, pkg := range pkgs {
-						if pkg.PkgPath != "" {
-							if strings.HasSuffix(pkg.PkgPath, "/"+suffix) || strings.HasSuffix(pkg.PkgPath, suffix) {
-								fullPkgPaths = append(fullPkgPaths, pkg.PkgPath)
-								break
+						for _, pkg := range pkgs {
+							if pkg.PkgPath != "" {
+								if strings.HasSuffix(pkg.PkgPath, "/"+suffix) || strings.HasSuffix(pkg.PkgPath, suffix) {
+									changedPackages = append(changedPackages, pkg.PkgPath)
+									break
+								}
 							}
 						}
 					}
 				}
-				changedPackages = fullPkgPaths
-				fmt.Printf("转换为完整包路径: %v\n", changedPackages)
+				targetPackages = analyzer.ReverseDependents(pkgs, changedPackages)
+				fmt.Printf("变更包: %v\n", changedPackages)
+				fmt.Printf("连同依赖它们的包，共 %d 个包需要重新分析\n", len(targetPackages))
 			}
 
 			// Build SSA
@@ -104,31 +194,31 @@ func analyzeCmd() *cobra.Command {
 				return fmt.Errorf("构建调用图失败: %w", err)
 			}
 
-			// Open database
-			db, err := storage.Open(DbPath)
+			// Write everything in one transaction; on failure the previous graph is kept
+			batch, err := db.BeginBatch(storage.BatchOptions{SyncOff: noSync})
 			if err != nil {
-				return fmt.Errorf("打开数据库失败: %w", err)
+				return fmt.Errorf("开始写入事务失败: %w", err)
 			}
-			defer db.Close()
+			defer batch.Rollback()
 
-			// Incremental mode: only delete changed packages' data
-			if incremental && len(changedPackages) > 0 {
-				fmt.Printf("增量模式：删除 %d 个变更包的旧数据...\n", len(changedPackages))
-				deletedCount, err := db.DeleteNodesByPackage(changedPackages)
+			// Incremental mode: replace the target packages and reuse every other node
+			insertNode, insertEdge := batch.InsertNode, batch.InsertEdge
+			insertFieldTag, insertEnumSwitch := batch.InsertFieldTag, batch.InsertEnumSwitch
+			insertExternalCall := batch.InsertExternalCall
+			var inc *storage.Incremental
+			if incremental {
+				fmt.Printf("增量模式：删除 %d 个包的旧数据...\n", len(targetPackages))
+				var deletedCount int64
+				inc, deletedCount, err = batch.BeginIncremental(targetPackages)
 				if err != nil {
-					fmt.Printf("警告：删除旧数据失败: %v，将执行全量重建\n", err)
-					if err := db.Clear(); err != nil {
-						return fmt.Errorf("清空数据库失败: %w", err)
-					}
-				} else {
-					fmt.Printf("已删除 %d 个旧节点\n", deletedCount)
-					orphanCount, _ := db.DeleteOrphanEdges()
-					if orphanCount > 0 {
-						fmt.Printf("清理 %d 条孤立边\n", orphanCount)
-					}
+					return fmt.Errorf("增量更新失败: %w (可去掉 -i 执行全量分析)", err)
 				}
+				fmt.Printf("已删除 %d 个旧节点\n", deletedCount)
+				insertNode, insertEdge = inc.InsertNode, inc.InsertEdge
+				insertFieldTag, insertEnumSwitch = inc.InsertFieldTag, inc.InsertEnumSwitch
+				insertExternalCall = inc.InsertExternalCall
 			} else {
-				if err := db.Clear(); err != nil {
+				if err := batch.Clear(); err != nil {
 					return fmt.Errorf("清空数据库失败: %w", err)
 				}
 			}
@@ -137,25 +227,23 @@ func analyzeCmd() *cobra.Command {
 			builder := graph.NewBuilder(
 				prog.Fset,
 				pkgs,
-				projectPath,
-				db.InsertNode,
-				db.InsertEdge,
+				analysisPath,
+				insertNode,
+				insertEdge,
 			)
 
-			if incremental && len(changedPackages) > 0 {
-				builder.SetTargetPackages(changedPackages)
-				fmt.Printf("增量模式：仅插入变更包的节点\n")
-			}
+			builder.SetExternalCallFn(insertExternalCall)
 
 			if err := builder.Build(cg); err != nil {
 				return fmt.Errorf("构建图失败: %w", err)
 			}
 
 			// Build interface implementation graph
-			interfaceAnalyzer := analyzer.NewInterfaceAnalyzer(pkgs, projectPath)
+			interfaceAnalyzer := analyzer.NewInterfaceAnalyzer(pkgs, analysisPath)
+			interfaceAnalyzer.SetExternalInterfaces(externalIfaces)
 			ifaceCount, typeCount, implCount, err := interfaceAnalyzer.BuildInterfaceGraph(
-				db.InsertNode,
-				db.InsertEdge,
+				insertNode,
+				insertEdge,
 			)
 			if err != nil {
 				fmt.Printf("警告: 接口分析失败: %v\n", err)
@@ -163,14 +251,31 @@ func analyzeCmd() *cobra.Command {
 				fmt.Printf("接口分析: %d 个接口, %d 个类型, %d 个实现关系\n", ifaceCount, typeCount, implCount)
 			}
 
-			// Build var/const reference graph
-			varConstAnalyzer := analyzer.NewVarConstAnalyzer(pkgs, projectPath)
-			if incremental && len(changedPackages) > 0 {
-				varConstAnalyzer.SetTargetPackages(changedPackages)
+			// Type assertions and type switches
+			assertCount, err := builder.BuildTypeAssertions(prog, interfaceAnalyzer.GetNamedTypeNodeMap())
+			if err != nil {
+				fmt.Printf("警告: 类型断言分析失败: %v\n", err)
+			} else if assertCount > 0 {
+				fmt.Printf("类型断言分析: %d 个类型断言关系\n", assertCount)
 			}
+
+			// Link interface methods to concrete methods and dispatching callers
+			methodCount, methodImplCount, dispatchCount, err := interfaceAnalyzer.BuildInterfaceMethodGraph(
+				insertNode,
+				insertEdge,
+				builder.GetNodeMap(),
+			)
+			if err != nil {
+				fmt.Printf("警告: 接口方法分析失败: %v\n", err)
+			} else if methodCount > 0 {
+				fmt.Printf("接口方法分析: %d 个接口方法, %d 个实现方法, %d 个接口调用\n", methodCount, methodImplCount, dispatchCount)
+			}
+
+			// Build var/const reference graph
+			varConstAnalyzer := analyzer.NewVarConstAnalyzer(pkgs, analysisPath)
 			varCount, constCount, refCount, err := varConstAnalyzer.BuildVarConstGraph(
-				db.InsertNode,
-				db.InsertEdge,
+				insertNode,
+				insertEdge,
 				builder.GetNodeMap(),
 			)
 			if err != nil {
@@ -179,6 +284,72 @@ func analyzeCmd() *cobra.Command {
 				fmt.Printf("变量/常量分析: %d 个变量, %d 个常量, %d 个引用关系\n", varCount, constCount, refCount)
 			}
 
+			// Build enum graph (depends on const nodes)
+			enumCount, switchCount, incompleteCount, err := varConstAnalyzer.BuildEnumGraph(
+				insertNode,
+				insertEdge,
+				insertEnumSwitch,
+				builder.GetNodeMap(),
+			)
+			if err != nil {
+				fmt.Printf("警告: 枚举分析失败: %v\n", err)
+			} else if enumCount > 0 {
+				fmt.Printf("枚举分析: %d 个枚举, %d 个 switch (未完整处理 %d 个)\n", enumCount, switchCount, incompleteCount)
+			}
+
+			// Build struct field / tag graph
+			structTagAnalyzer := analyzer.NewStructTagAnalyzer(pkgs, analysisPath)
+			fieldCount, tagCount, usageCount, err := structTagAnalyzer.BuildStructTagGraph(
+				insertNode,
+				insertEdge,
+				insertFieldTag,
+				interfaceAnalyzer.GetTypeNodeMap(),
+				builder.GetNodeMap(),
+			)
+			if err != nil {
+				fmt.Printf("警告: 结构体标签分析失败: %v\n", err)
+			} else if fieldCount > 0 {
+				fmt.Printf("结构体标签分析: %d 个字段, %d 个标签, %d 个构造/解码关系\n", fieldCount, tagCount, usageCount)
+			}
+
+			if inc != nil {
+				staleNodes, staleEdges, err := inc.Finish()
+				if err != nil {
+					return fmt.Errorf("增量更新失败: %w", err)
+				}
+				if staleNodes > 0 || staleEdges > 0 {
+					fmt.Printf("清理 %d 个过期节点, %d 条过期关系\n", staleNodes, staleEdges)
+				}
+			}
+
+			if err := batch.SaveSourceFiles(files); err != nil {
+				return fmt.Errorf("写入文件记录失败: %w", err)
+			}
+
+			// Record how this graph was built
+			flags := analyzer.AnalysisFlags(includeModules, externalIfaces)
+			if incremental {
+				flags = append(flags, "--incremental")
+				if gitChangedDirs != nil {
+					flags = append(flags, "--base="+gitBase)
+				}
+			}
+			meta := analyzer.CollectMetadata(analysisPath, pkgs, flags)
+			if abs, err := filepath.Abs(projectPath); err == nil {
+				meta.ProjectRoot = abs
+			}
+			meta.Incremental = incremental
+			meta.Snapshot = snapshotName
+			meta.DurationMs = time.Since(start).Milliseconds()
+			meta.AnalyzedAt = time.Now()
+			if err := batch.SaveMetadata(meta); err != nil {
+				fmt.Printf("警告: 写入分析元数据失败: %v\n", err)
+			}
+
+			if err := batch.Commit(); err != nil {
+				return fmt.Errorf("提交写入事务失败: %w", err)
+			}
+
 			nodeCount, edgeCount, _ := db.GetStats()
 			fmt.Printf("写入数据库: %s\n", DbPath)
 			fmt.Printf("完成! 已存储 %d 个函数节点\n", builder.GetNodeCount())
@@ -189,9 +360,14 @@ func analyzeCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "输出数据库路径")
-	cmd.Flags().BoolVarP(&incremental, "incremental", "i", false, "增量分析模式 (只分析 git 变更)")
-	cmd.Flags().StringVar(&gitBase, "base", "HEAD", "git 比较基准 (默认 HEAD，即未提交的变更)")
-	cmd.Flags().BoolVarP(&remote, "remote", "r", false, "与远程同分支对比 (origin/<当前分支>)")
+	cmd.Flags().BoolVarP(&incremental, "incremental", "i", false, "增量分析模式 (只重新分析内容变更的包及依赖它们的包)")
+	cmd.Flags().StringVar(&gitBase, "base", "HEAD", "数据库没有文件哈希记录时使用的 git 比较基准 (默认 HEAD，即未提交的变更)")
+	cmd.Flags().BoolVarP(&remote, "remote", "r", false, "无文件哈希记录时与远程同分支对比 (origin/<当前分支>)")
+	cmd.Flags().StringSliceVar(&includeModules, "include-module", nil, "将匹配的依赖模块包作为项目代码分析 (如 github.com/ourorg/...，可重复)")
+	cmd.Flags().StringVar(&snapshotName, "snapshot", "", "保存为命名快照 (名称或 git 提交/标签/分支)，不覆盖主数据库")
+	cmd.Flags().StringVar(&snapshotDir, "snapshot-dir", "", "快照目录 (默认: 数据库同名的 .snapshots 目录)")
+	cmd.Flags().BoolVar(&noSync, "no-sync", false, "写入时关闭 fsync (PRAGMA synchronous=OFF)，更快，但断电可能损坏数据库")
+	cmd.Flags().StringSliceVar(&externalIfaces, "external-iface", analyzer.DefaultExternalInterfaces, "检测项目类型是否实现的外部接口 (如 io.Reader、net/http.Handler，可重复)")
 
 	return cmd
 }
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/analyzer"
+	"github.com/zheng/crag/internal/apidiff"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func apidiffCmd() *cobra.Command {
+	var base string
+	var format string
+	var showCompatible bool
+
+	cmd := &cobra.Command{
+		Use:   "apidiff [project-path]",
+		Short: "检测导出 API 相对基准分支的破坏性变更",
+		Long: `在临时 git worktree 中分析基准分支，与当前工作区对比导出的函数、方法、
+接口、结构体字段和变量/常量，将每处变更分为破坏性或兼容，
+并列出破坏性变更在项目内的调用者和间接依赖者。
+
+发现破坏性变更时以非零状态码退出，可用于 CI。
+
+示例：
+  crag apidiff --base main
+  crag apidiff --base origin/main --format json
+  crag apidiff --base v1.2.0 --compatible   # 同时列出兼容变更`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectPath := "."
+			if len(args) > 0 {
+				projectPath = args[0]
+			}
+
+			tmpDir, err := os.MkdirTemp("", "crag-apidiff-")
+			if err != nil {
+				return fmt.Errorf("创建临时目录失败: %w", err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			fmt.Fprintf(os.Stderr, "检出基准 %s ...\n", base)
+			basePath, cleanup, err := analyzer.CreateWorktree(projectPath, base)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			// External interfaces are not part of the project API
+			noExternal := []string{}
+
+			fmt.Fprintf(os.Stderr, "分析基准 %s ...\n", base)
+			baseDBPath := filepath.Join(tmpDir, "base.db")
+			if _, _, err := runInitialAnalysis(basePath, baseDBPath, nil, noExternal); err != nil {
+				return fmt.Errorf("分析基准失败: %w", err)
+			}
+
+			fmt.Fprintln(os.Stderr, "分析当前工作区 ...")
+			headDBPath := filepath.Join(tmpDir, "head.db")
+			if _, _, err := runInitialAnalysis(projectPath, headDBPath, nil, noExternal); err != nil {
+				return fmt.Errorf("分析当前工作区失败: %w", err)
+			}
+
+			baseDB, err := storage.Open(baseDBPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer baseDB.Close()
+			headDB, err := storage.Open(headDBPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer headDB.Close()
+
+			report, err := apidiff.Compare(baseDB, headDB)
+			if err != nil {
+				return err
+			}
+
+			if format == "json" {
+				if err := outputJSON(report); err != nil {
+					return err
+				}
+			} else {
+				printAPIDiff(base, report, showCompatible)
+			}
+
+			if len(report.Breaking) > 0 {
+				cmd.SilenceUsage = true
+				cmd.SilenceErrors = true // main prints the error
+				return fmt.Errorf("发现 %d 个破坏性 API 变更", len(report.Breaking))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&base, "base", "main", "对比的基准 git ref (分支/标签/提交)")
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	cmd.Flags().BoolVar(&showCompatible, "compatible", false, "同时列出兼容变更")
+
+	return cmd
+}
+
+func printAPIDiff(base string, report *apidiff.Report, showCompatible bool) {
+	fmt.Printf("API 变更: %s → 当前工作区\n\n", base)
+
+	if len(report.Breaking) == 0 {
+		fmt.Println("✅ 没有破坏性变更")
+	} else {
+		fmt.Printf("❌ 破坏性变更 (共 %d 个)\n\n", len(report.Breaking))
+		for _, c := range report.Breaking {
+			printAPIChange(c)
+			printNodeList("调用者", c.Callers)
+			printNodeList("需同步修改的实现", c.Implementations)
+			printNodeList("间接依赖", c.Dependents)
+			fmt.Println()
+		}
+	}
+
+	if !showCompatible {
+		if len(report.Compatible) > 0 {
+			fmt.Printf("\n兼容变更 %d 个 (使用 --compatible 查看)\n", len(report.Compatible))
+		}
+		return
+	}
+
+	fmt.Printf("\n✅ 兼容变更 (共 %d 个)\n\n", len(report.Compatible))
+	for _, c := range report.Compatible {
+		printAPIChange(c)
+	}
+}
+
+func printAPIChange(c *apidiff.Change) {
+	fmt.Printf("  [%s] %s %s  %s:%d\n", changeLabel(c.Change), apiKindLabel(c.Kind), display.ShortFuncName(c.Name), c.File, c.Line)
+	fmt.Printf("      %s\n", c.Reason)
+	if c.OldSignature != "" && c.Change != apidiff.ChangeAdded {
+		fmt.Printf("      原: %s\n", display.ShortSignature(c.OldSignature))
+	}
+	if c.NewSignature != "" && c.Change != apidiff.ChangeRemoved {
+		fmt.Printf("      新: %s\n", display.ShortSignature(c.NewSignature))
+	}
+}
+
+func printNodeList(label string, nodes []*graph.Node) {
+	if len(nodes) == 0 {
+		return
+	}
+	fmt.Printf("      %s (%d):\n", label, len(nodes))
+	for i, n := range nodes {
+		prefix := "├──"
+		if i == len(nodes)-1 {
+			prefix = "└──"
+		}
+		fmt.Printf("      %s %s  %s:%d\n", prefix, display.ShortFuncName(n.Name), n.File, n.Line)
+	}
+}
+
+func changeLabel(t apidiff.ChangeType) string {
+	switch t {
+	case apidiff.ChangeRemoved:
+		return "删除"
+	case apidiff.ChangeAdded:
+		return "新增"
+	default:
+		return "修改"
+	}
+}
+
+func apiKindLabel(kind graph.NodeKind) string {
+	switch kind {
+	case graph.NodeKindFunc:
+		return "函数"
+	case graph.NodeKindInterface:
+		return "接口"
+	case graph.NodeKindStruct:
+		return "类型"
+	case graph.NodeKindField:
+		return "字段"
+	case graph.NodeKindVar:
+		return "变量"
+	case graph.NodeKindConst:
+		return "常量"
+	default:
+		return string(kind)
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func dbCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "管理数据库 schema 版本",
+		Long: `查看和升级 .crag.db 的 schema 版本。
+
+打开数据库时会自动应用缺失的迁移；由更新版本 crag 写入的数据库会被拒绝，
+需要先升级 crag。团队共享数据库文件时，可以用这些命令确认版本是否兼容。`,
+	}
+
+	cmd.AddCommand(dbVersionCmd())
+	cmd.AddCommand(dbMigrateCmd())
+	return cmd
+}
+
+func dbVersionCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "显示数据库 schema 版本及待应用的迁移",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openExistingDB()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			version, err := db.SchemaVersion()
+			if err != nil {
+				return fmt.Errorf("读取 schema 版本失败: %w", err)
+			}
+			history, err := db.SchemaHistory()
+			if err != nil {
+				return fmt.Errorf("读取迁移记录失败: %w", err)
+			}
+			pending, err := db.PendingMigrations()
+			tooNew := false
+			if err != nil {
+				if _, ok := err.(*storage.ErrSchemaTooNew); !ok {
+					return err
+				}
+				tooNew = true
+			}
+
+			if format == "json" {
+				var pendingVersions []int
+				for _, m := range pending {
+					pendingVersions = append(pendingVersions, m.Version)
+				}
+				return outputJSON(map[string]any{
+					"database":  DbPath,
+					"version":   version,
+					"supported": storage.LatestSchemaVersion(),
+					"history":   history,
+					"pending":   pendingVersions,
+				})
+			}
+
+			fmt.Printf("数据库: %s\n", DbPath)
+			fmt.Printf("schema 版本: %d\n", version)
+			fmt.Printf("crag 支持的版本: %d\n", storage.LatestSchemaVersion())
+
+			if len(history) > 0 {
+				fmt.Println("\n已应用的迁移:")
+				for _, h := range history {
+					fmt.Printf("  %3d  %s  (%s)\n", h.Version, h.Description, h.AppliedAt)
+				}
+			}
+
+			switch {
+			case tooNew:
+				fmt.Println("\n⚠️  数据库由更新版本的 crag 写入，请升级 crag")
+			case len(pending) > 0:
+				fmt.Printf("\n待应用的迁移 (%d):\n", len(pending))
+				for _, m := range pending {
+					fmt.Printf("  %3d  %s\n", m.Version, m.Description)
+				}
+				fmt.Println("\n💡 运行 crag db migrate 升级，或直接使用其他命令时自动升级")
+			default:
+				fmt.Println("\n✅ 数据库已是最新版本")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	return cmd
+}
+
+func dbMigrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "将数据库升级到当前 crag 支持的 schema 版本",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openExistingDB()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			from, err := db.SchemaVersion()
+			if err != nil {
+				return fmt.Errorf("读取 schema 版本失败: %w", err)
+			}
+
+			applied, err := db.Migrate()
+			for _, m := range applied {
+				fmt.Printf("✓ %3d  %s\n", m.Version, m.Description)
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(applied) == 0 {
+				fmt.Printf("数据库已是最新版本 (%d)\n", from)
+				return nil
+			}
+			fmt.Printf("\n✅ schema 版本 %d → %d\n", from, storage.LatestSchemaVersion())
+			return nil
+		},
+	}
+}
+
+// openExistingDB opens the database without migrating it, failing if it does not exist
+func openExistingDB() (*storage.DB, error) {
+	if _, err := os.Stat(DbPath); err != nil {
+		return nil, fmt.Errorf("数据库不存在: %s (请先运行 crag analyze)", DbPath)
+	}
+	db, err := storage.OpenWithoutMigrate(DbPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/snapshot"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func diffCmd() *cobra.Command {
+	var format string
+	var limit int
+	var snapshotDir string
+
+	cmd := &cobra.Command{
+		Use:   "diff <a> <b>",
+		Short: "对比两个快照的调用图",
+		Long: `对比两个图谱（由 crag analyze --snapshot 生成的快照名，或数据库文件路径），
+列出新增/删除的函数、签名变更、新增/删除的调用和引用关系，以及调用者数量变化导致的风险变化。
+
+示例：
+  crag analyze . --snapshot v1.2.0
+  crag analyze . --snapshot v1.3.0
+  crag diff v1.2.0 v1.3.0
+  crag diff v1.3.0 .crag.db            # 快照 vs 当前数据库
+  crag diff v1.2.0 v1.3.0 --format json`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if snapshotDir == "" {
+				snapshotDir = snapshot.Dir(DbPath)
+			}
+
+			pathA, err := snapshot.Resolve(snapshotDir, args[0])
+			if err != nil {
+				return err
+			}
+			pathB, err := snapshot.Resolve(snapshotDir, args[1])
+			if err != nil {
+				return err
+			}
+
+			dbA, err := storage.OpenReadOnly(pathA)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer dbA.Close()
+			dbB, err := storage.OpenReadOnly(pathB)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer dbB.Close()
+
+			report, err := snapshot.Diff(dbA, dbB)
+			if err != nil {
+				return fmt.Errorf("对比失败: %w", err)
+			}
+
+			if format == "json" {
+				return outputJSON(report)
+			}
+			printGraphDiff(args[0], args[1], report, limit)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	cmd.Flags().IntVar(&limit, "limit", 50, "每类变更最多显示条数 (0=全部)")
+	cmd.Flags().StringVar(&snapshotDir, "snapshot-dir", "", "快照目录 (默认: 数据库同名的 .snapshots 目录)")
+
+	return cmd
+}
+
+func printGraphDiff(a, b string, report *snapshot.DiffReport, limit int) {
+	fmt.Printf("图谱对比: %s → %s\n\n", a, b)
+
+	if report.IsEmpty() {
+		fmt.Println("✅ 没有差异")
+		return
+	}
+
+	fmt.Printf("函数: +%d -%d, 签名变更 %d\n", len(report.AddedFuncs), len(report.RemovedFuncs), len(report.SignatureChanges))
+	fmt.Printf("关系: +%d -%d, 风险变化 %d\n", len(report.AddedEdges), len(report.RemovedEdges), len(report.RiskChanges))
+
+	printDiffNodes("新增函数", "+", report.AddedFuncs, limit)
+	printDiffNodes("删除函数", "-", report.RemovedFuncs, limit)
+
+	if len(report.SignatureChanges) > 0 {
+		fmt.Printf("\n📝 签名变更 (%d)\n", len(report.SignatureChanges))
+		for i, c := range report.SignatureChanges {
+			if limitReached(i, limit, len(report.SignatureChanges)) {
+				break
+			}
+			fmt.Printf("  ~ %s  %s:%d\n", display.ShortFuncName(c.Name), c.File, c.Line)
+			fmt.Printf("      原: %s\n", display.ShortSignature(c.OldSignature))
+			fmt.Printf("      新: %s\n", display.ShortSignature(c.NewSignature))
+		}
+	}
+
+	printDiffEdges("新增关系", "+", report.AddedEdges, limit)
+	printDiffEdges("删除关系", "-", report.RemovedEdges, limit)
+
+	if len(report.RiskChanges) > 0 {
+		fmt.Printf("\n⚠️  风险变化 (%d)\n", len(report.RiskChanges))
+		for i, r := range report.RiskChanges {
+			if limitReached(i, limit, len(report.RiskChanges)) {
+				break
+			}
+			level := r.NewLevel
+			if r.LevelChanged() {
+				level = fmt.Sprintf("%s → %s", r.OldLevel, r.NewLevel)
+			}
+			fmt.Printf("  %+4d  %s  调用者: 直接 %d → %d / 总 %d → %d  [%s]\n", r.TotalDelta(), display.ShortFuncName(r.Name),
+				r.OldCallers, r.NewCallers, r.OldTotalCallers, r.NewTotalCallers, level)
+		}
+	}
+}
+
+func printDiffNodes(title, sign string, nodes []*graph.Node, limit int) {
+	if len(nodes) == 0 {
+		return
+	}
+	fmt.Printf("\n%s (%d)\n", title, len(nodes))
+	for i, n := range nodes {
+		if limitReached(i, limit, len(nodes)) {
+			break
+		}
+		fmt.Printf("  %s %s  %s:%d\n", sign, display.ShortFuncName(n.Name), n.File, n.Line)
+	}
+}
+
+func printDiffEdges(title, sign string, edges []*snapshot.EdgeChange, limit int) {
+	if len(edges) == 0 {
+		return
+	}
+	fmt.Printf("\n%s (%d)\n", title, len(edges))
+	for i, e := range edges {
+		if limitReached(i, limit, len(edges)) {
+			break
+		}
+		fmt.Printf("  %s %s → %s  (%s)\n", sign, display.ShortFuncName(e.From), display.ShortFuncName(e.To), e.Kind)
+	}
+}
+
+// limitReached prints a truncation note and reports true once i reaches limit
+func limitReached(i, limit, total int) bool {
+	if limit > 0 && i >= limit {
+		fmt.Printf("  ... 还有 %d 条 (使用 --limit 0 查看全部)\n", total-limit)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/doctor"
+	"github.com/zheng/crag/internal/graph"
+)
+
+func doctorCmd() *cobra.Command {
+	var repair bool
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "检查数据库完整性以及图谱是否过期",
+		Long: `检查数据库并给出修复方法：
+
+  - schema 版本是否有待应用的迁移
+  - 引用不存在节点的孤立边、字段标签、枚举 switch 和外部调用
+  - 对应多个节点的重名
+  - 可达性索引是否覆盖所有函数
+  - 项目在分析后是否有变更 (对比文件内容哈希与 git 状态)
+  - 节点所在的源文件是否还存在，函数的记录行号处是否还是其声明
+
+--repair 自动修复：应用迁移，删除孤立记录并重建索引，图谱过期时重新分析项目
+(变更可由文件哈希解释时增量分析，否则全量分析，沿用上次的分析参数)。
+发现问题且未修复时以非零状态退出，可用于 CI。
+
+示例：
+  crag doctor
+  crag doctor --repair
+  crag doctor -d shared.crag.db --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := runDoctor()
+			if err != nil {
+				return err
+			}
+
+			if repair && len(report.Problems()) > 0 {
+				if format != "json" {
+					printDoctorReport(report)
+					fmt.Println("\n🔧 开始修复")
+				}
+				if err := repairDatabase(report, format == "json"); err != nil {
+					return err
+				}
+				if report, err = runDoctor(); err != nil {
+					return err
+				}
+				if format != "json" {
+					fmt.Println("\n复查结果:")
+				}
+			}
+
+			if format == "json" {
+				if err := outputJSON(report); err != nil {
+					return err
+				}
+			} else {
+				printDoctorReport(report)
+			}
+
+			problems := report.Problems()
+			if len(problems) == 0 {
+				return nil
+			}
+			if format != "json" && !repair {
+				fmt.Println("\n💡 运行 crag doctor --repair 自动修复")
+			}
+			cmd.SilenceUsage = true
+			return fmt.Errorf("发现 %d 个问题", len(problems))
+		},
+	}
+
+	cmd.Flags().BoolVar(&repair, "repair", false, "自动修复发现的问题")
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	return cmd
+}
+
+func runDoctor() (*doctor.Report, error) {
+	db, err := openExistingDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return doctor.Check(db, DbPath)
+}
+
+// repairDatabase fixes the problems of a report. Analysis goes last: it runs
+// against the migrated database and rewrites everything the cleanup touched.
+func repairDatabase(report *doctor.Report, quiet bool) error {
+	logf := func(format string, args ...any) {
+		if !quiet {
+			fmt.Printf(format, args...)
+		}
+	}
+
+	db, err := openExistingDB()
+	if err != nil {
+		return err
+	}
+	if report.Needs(doctor.RepairMigrate) {
+		applied, err := db.Migrate()
+		if err != nil {
+			db.Close()
+			return fmt.Errorf("迁移失败: %w", err)
+		}
+		logf("  ✅ 已应用 %d 个迁移\n", len(applied))
+
+		// The graph checks were skipped on the old schema
+		if report, err = doctor.Check(db, DbPath); err != nil {
+			db.Close()
+			return err
+		}
+	}
+	if report.Needs(doctor.RepairCleanup) {
+		o, err := doctor.Cleanup(db)
+		if err != nil {
+			db.Close()
+			return fmt.Errorf("清理失败: %w", err)
+		}
+		logf("  ✅ 已删除 %d 条孤立记录并重建可达性索引\n", o.Total())
+	}
+	db.Close()
+
+	full := report.Needs(doctor.RepairFull)
+	if !full && !report.Needs(doctor.RepairIncremental) {
+		return nil
+	}
+	mode := "增量"
+	if full {
+		mode = "全量"
+	}
+	logf("  🔄 %s重新分析 %s\n\n", mode, report.Metadata.ProjectRoot)
+	if quiet {
+		// Keep stdout for the JSON report; analysis progress goes to stderr
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
+	}
+	if err := reanalyze(report.Metadata, !full); err != nil {
+		return fmt.Errorf("重新分析失败: %w", err)
+	}
+	return nil
+}
+
+// reanalyze runs crag analyze on the project of a graph with its recorded flags
+func reanalyze(meta *graph.Metadata, incremental bool) error {
+	analyze := analyzeCmd()
+	analyze.SetArgs(doctor.AnalyzeArgs(meta, incremental))
+	return analyze.Execute()
+}
+
+func printDoctorReport(report *doctor.Report) {
+	fmt.Printf("数据库: %s\n\n", report.Database)
+	for _, res := range report.Results {
+		switch {
+		case res.Skipped != "":
+			fmt.Printf("⏭️  %s: 跳过 (%s)\n", res.Title, res.Skipped)
+		case res.OK:
+			fmt.Printf("✅ %s", res.Title)
+			if res.Message != "" {
+				fmt.Printf(": %s", res.Message)
+			}
+			fmt.Println()
+		default:
+			fmt.Printf("⚠️  %s: %s\n", res.Title, res.Message)
+		}
+		if res.Skipped != "" {
+			continue
+		}
+		for _, d := range res.Details {
+			fmt.Printf("     - %s\n", d)
+		}
+		if !res.OK && res.Fix != "" {
+			fmt.Printf("     修复: %s\n", res.Fix)
+		}
+	}
+}
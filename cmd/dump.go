@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/dump"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func dumpCmd() *cobra.Command {
+	var outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "dump",
+		Short: "将图谱导出为可移植的 NDJSON 文件",
+		Long: `将数据库中的全部节点 (所有类型)、边、字段标签、枚举 switch、外部调用、元数据、
+源文件哈希和注释导出为版本化的 NDJSON 文件 (格式说明见 internal/dump)。
+
+输出按 ID 排序、逐行一条记录，同一图谱的 dump 完全相同，可以提交到仓库、
+在代码评审中 diff，或作为构建产物分发；用 crag load 即可在没有 Go 工具链的
+机器上重建 .crag.db。
+
+示例：
+  crag dump -o graph.ndjson
+  crag dump | gzip > graph.ndjson.gz`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := storage.OpenReadOnly(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			var w io.Writer = os.Stdout
+			if outputFile != "" && outputFile != "-" {
+				f, err := os.Create(outputFile)
+				if err != nil {
+					return fmt.Errorf("创建输出文件失败: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			header, err := dump.Write(db, w)
+			if err != nil {
+				return fmt.Errorf("导出失败: %w", err)
+			}
+			if w != os.Stdout {
+				fmt.Printf("已导出到 %s: %d 节点, %d 边, %d 条注释 (格式 %s v%d)\n",
+					outputFile, header.Counts[dump.TypeNode], header.Counts[dump.TypeEdge], header.Counts[dump.TypeNote],
+					dump.FormatName, dump.FormatVersion)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "输出文件 (默认输出到标准输出)")
+	return cmd
+}
+
+func loadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "load <graph.ndjson>",
+		Short: "从 NDJSON dump 重建数据库",
+		Long: `读取 crag dump 生成的文件，写入 -d 指定的数据库 (不存在则创建)。
+
+数据库中原有的图谱、元数据和源文件记录会被替换；注释会合并，相同的注释
+不会重复添加。整个加载在一个事务中完成，失败时保留原有图谱。
+文件名为 - 时从标准输入读取。
+
+示例：
+  crag load graph.ndjson -d .crag.db
+  gunzip -c graph.ndjson.gz | crag load -`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader = os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("打开 dump 失败: %w", err)
+				}
+				defer f.Close()
+				r = f
+			}
+
+			db, err := storage.Open(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			batch, err := db.BeginBatch(storage.BatchOptions{})
+			if err != nil {
+				return fmt.Errorf("开始写入事务失败: %w", err)
+			}
+			defer batch.Rollback()
+
+			stats, err := dump.Read(r, batch)
+			if err != nil {
+				return fmt.Errorf("加载失败: %w", err)
+			}
+			if err := batch.Commit(); err != nil {
+				return fmt.Errorf("提交写入事务失败: %w", err)
+			}
+
+			fmt.Printf("已加载到 %s: %d 节点, %d 边, %d 字段标签, %d 枚举 switch, %d 源文件, %d 外部调用, 新增 %d 条注释\n",
+				DbPath, stats.Nodes, stats.Edges, stats.FieldTags, stats.EnumSwitches, stats.Files, stats.External, stats.Notes)
+			if stats.Skipped > 0 {
+				fmt.Printf("⚠️  跳过 %d 条未知类型的记录 (由更新版本的 crag 写入)\n", stats.Skipped)
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// enumResult groups an enum with its members and the switches over it
+type enumResult struct {
+	Enum     *graph.Node                `json:"enum"`
+	Members  []*graph.Node              `json:"members"`
+	Switches []*storage.EnumSwitchUsage `json:"switches"`
+}
+
+func enumCmd() *cobra.Command {
+	var format string
+	var incompleteOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "enum <type>",
+		Short: "查看枚举类型的成员及未完整处理的 switch 语句",
+		Long: `列出枚举类型（同一具名类型的一组常量，通常使用 iota 声明）的成员及其值，
+并找出对该类型 switch 但未覆盖所有成员的位置。
+
+新增枚举成员前运行此命令，可以快速找到需要补充 case 的 switch。
+
+示例：
+  crag enum Status
+  crag enum order.Status --incomplete`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pattern := args[0]
+
+			db, err := storage.OpenReadOnly(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			nodes, err := db.FindNodesByPattern(pattern)
+			if err != nil {
+				return fmt.Errorf("查询失败: %w", err)
+			}
+
+			var enums []*graph.Node
+			for _, n := range nodes {
+				if n.Kind == graph.NodeKindEnum {
+					enums = append(enums, n)
+				}
+			}
+
+			if len(enums) == 0 {
+				fmt.Printf("未找到枚举类型: %s\n", pattern)
+				fmt.Println("\n💡 提示：请先运行 analyze 命令分析项目：")
+				fmt.Println("   crag analyze .")
+				return nil
+			}
+
+			results := make([]*enumResult, 0, len(enums))
+			for _, e := range enums {
+				r := &enumResult{Enum: e}
+				if r.Members, err = db.GetEnumMembers(e.ID); err != nil {
+					return fmt.Errorf("查询枚举成员失败: %w", err)
+				}
+				switches, err := db.GetEnumSwitches(e.ID)
+				if err != nil {
+					return fmt.Errorf("查询 switch 语句失败: %w", err)
+				}
+				for _, s := range switches {
+					if incompleteOnly && len(s.Switch.Missing) == 0 {
+						continue
+					}
+					r.Switches = append(r.Switches, s)
+				}
+				results = append(results, r)
+			}
+
+			if format == "json" {
+				return outputJSON(results)
+			}
+
+			for _, r := range results {
+				fmt.Printf("枚举: %s (%s)\n", display.ShortFuncName(r.Enum.Name), r.Enum.Signature)
+				fmt.Printf("  位置: %s:%d\n", r.Enum.File, r.Enum.Line)
+				if r.Enum.Doc != "" {
+					fmt.Printf("  说明: %s\n", strings.Split(r.Enum.Doc, "\n")[0])
+				}
+
+				fmt.Printf("\n  成员 (%d):\n", len(r.Members))
+				for _, m := range r.Members {
+					fmt.Printf("    %-24s = %s\n", display.ShortFuncName(m.Name), m.Value)
+				}
+
+				incomplete := 0
+				for _, s := range r.Switches {
+					if len(s.Switch.Missing) > 0 {
+						incomplete++
+					}
+				}
+				fmt.Printf("\n  switch 语句 (%d, 未完整处理 %d):\n", len(r.Switches), incomplete)
+				if len(r.Switches) == 0 {
+					fmt.Println("    (无)")
+				}
+				for _, s := range r.Switches {
+					status := "✓ 完整"
+					if len(s.Switch.Missing) > 0 {
+						status = "⚠ 缺少 " + strings.Join(s.Switch.Missing, ", ")
+						if s.Switch.HasDefault {
+							status += " (有 default)"
+						}
+					}
+					fmt.Printf("    %s  %s:%d  %s\n", display.ShortFuncName(s.Func.Name), s.Switch.File, s.Switch.Line, status)
+				}
+				fmt.Println()
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	cmd.Flags().BoolVar(&incompleteOnly, "incomplete", false, "只显示未覆盖所有成员的 switch")
+
+	return cmd
+}
@@ -21,7 +21,7 @@ func exportCmd() *cobra.Command {
 		Short: "导出 RAG 文档",
 		Long:  "导出完整的项目调用图谱文档（Markdown 格式），可作为 AI 编码上下文",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			db, err := storage.Open(DbPath)
+			db, err := storage.OpenReadOnly(DbPath)
 			if err != nil {
 				return fmt.Errorf("打开数据库失败: %w", err)
 			}
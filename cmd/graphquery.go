@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/query"
+)
+
+func queryCmd() *cobra.Command {
+	var limit int
+	var format string
+	var backend string
+
+	cmd := &cobra.Command{
+		Use:   "query <expr>",
+		Short: "用查询语言组合查询图谱",
+		Long: `用一个表达式回答 upstream/downstream/search/implements 无法单独回答的组合问题。
+
+表达式是节点集合的运算：
+  storage.Save  "(*pkg.T).M"  #123      节点：完整名、短名称或 #ID
+  kind=func  package~"handler"          过滤：kind/name/package/module/file，
+  name=Save  file~"^cmd/"              运算符 = != ~ !~ (~ 为正则)
+  all  tests  exported                  预定义集合 (tests: _test.go 中的节点，
+                                        分析不加载测试文件，目前为空)
+  callers(x)  callees(x)                调用关系，* 为任意深度，
+  callers*(x)  callees{1,3}(x)          {n} {n,m} {n,} 指定深度范围
+  implements(x)  implementers(x)        类型实现的接口 / 接口的实现类型
+  references(x)  referrers(x)           函数引用的变量常量 / 引用变量常量的函数
+  fields(x)                             结构体的字段
+  limit(x, n)                           按名称排序后的前 n 个
+  a & b   a | b   a - b                 交集、并集、差集 (& 优先)
+
+名称中含有字母、数字和 _ . / # $ 以外的字符时需要加引号。
+
+示例：
+  crag query 'callers*(storage.Save) & package~"handler" - tests'
+  crag query 'implementers(io.Reader) & exported'
+  crag query 'referrers(config.Timeout) - callers*(main)'
+  crag query 'kind=func & callees(db.Exec) - callees(db.Begin)' --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			expr, err := query.Parse(args[0])
+			if err != nil {
+				return fmt.Errorf("查询语法错误: %w", err)
+			}
+
+			db, store, err := openStore(backend)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			g, err := query.Load(store)
+			if err != nil {
+				return fmt.Errorf("加载图谱失败: %w", err)
+			}
+			nodes, err := g.Eval(expr)
+			if err != nil {
+				return err
+			}
+			for _, notice := range g.Notices(expr) {
+				fmt.Fprintf(os.Stderr, "⚠️  %s\n", notice)
+			}
+
+			total := len(nodes)
+			if limit > 0 && len(nodes) > limit {
+				nodes = nodes[:limit]
+			}
+
+			if format == "json" {
+				return outputJSON(nodes)
+			}
+
+			if total == 0 {
+				fmt.Println("没有匹配的节点")
+				return nil
+			}
+			fmt.Printf("共 %d 个节点", total)
+			if len(nodes) < total {
+				fmt.Printf(" (显示前 %d 个，--limit 0 显示全部)", len(nodes))
+			}
+			fmt.Println()
+			for _, n := range nodes {
+				fmt.Printf("  [%s] %s\n    %s:%d  #%d\n", n.Kind, display.ShortFuncName(n.Name), n.File, n.Line, n.ID)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 100, "最多显示数量 (0=全部)")
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	addBackendFlag(cmd, &backend)
+
+	return cmd
+}
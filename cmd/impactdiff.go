@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zheng/crag/internal/analyzer"
+	"github.com/zheng/crag/internal/impact"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// runDiffImpact runs crag impact --diff/--patch: the aggregated impact of all
+// symbols a git diff or patch touches
+func runDiffImpact(base, patch string, applied bool, upstreamDepth int, format string) error {
+	db, err := storage.OpenReadOnly(DbPath)
+	if err != nil {
+		return fmt.Errorf("打开数据库失败: %w", err)
+	}
+	defer db.Close()
+
+	meta, err := db.GetMetadata()
+	if err != nil {
+		return fmt.Errorf("读取元数据失败: %w", err)
+	}
+	projectRoot := "."
+	if meta != nil && meta.ProjectRoot != "" {
+		projectRoot = meta.ProjectRoot
+	}
+
+	var raw []byte
+	label := base
+	switch patch {
+	case "":
+		if label == "" {
+			label = "HEAD"
+		}
+		if raw, err = analyzer.GitDiff(projectRoot, base); err != nil {
+			return err
+		}
+	case "-":
+		if raw, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("读取标准输入失败: %w", err)
+		}
+	default:
+		if raw, err = os.ReadFile(patch); err != nil {
+			return fmt.Errorf("读取补丁失败: %w", err)
+		}
+		label = patch
+	}
+
+	files, err := impact.ParseDiff(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("解析 diff 失败: %w", err)
+	}
+	if patch != "" {
+		// Patches carry paths relative to the repository root
+		if prefix, err := analyzer.GitPrefix(projectRoot); err == nil && prefix != "" {
+			for _, f := range files {
+				f.OldPath = strings.TrimPrefix(f.OldPath, prefix)
+				f.NewPath = strings.TrimPrefix(f.NewPath, prefix)
+			}
+		}
+	}
+
+	opts := impact.DiffOptions{Base: label, UpstreamDepth: upstreamDepth}
+	switch {
+	case patch == "":
+		// The working tree is the new side; it applies to files unchanged since analysis
+		current, err := currentSourceFiles(db, projectRoot)
+		if err != nil {
+			return err
+		}
+		opts.Current = func(path string) bool { return current[path] }
+	case applied:
+		opts.Current = func(string) bool { return true }
+	}
+
+	report, err := impact.NewAnalyzer(db).AnalyzeDiff(files, opts)
+	if err != nil {
+		return err
+	}
+	report.GroupByOwner(loadOwners(db))
+
+	switch format {
+	case "json":
+		return outputJSON(report)
+	case "markdown":
+		fmt.Print(report.FormatMarkdown())
+	default:
+		fmt.Print(report.FormatText())
+	}
+	return nil
+}
+
+// currentSourceFiles returns the files whose content is the same as when the
+// graph was built
+func currentSourceFiles(db *storage.DB, projectRoot string) (map[string]bool, error) {
+	recorded, err := db.GetSourceFiles()
+	if err != nil {
+		return nil, fmt.Errorf("读取源文件记录失败: %w", err)
+	}
+	scanned, err := analyzer.ScanSourceFiles(projectRoot, recorded)
+	if err != nil {
+		return nil, fmt.Errorf("扫描源文件失败: %w", err)
+	}
+	hashes := make(map[string]string, len(recorded))
+	for _, f := range recorded {
+		hashes[f.Path] = f.Hash
+	}
+	current := make(map[string]bool)
+	for _, f := range scanned {
+		if hash, ok := hashes[f.Path]; ok && hash == f.Hash {
+			current[f.Path] = true
+		}
+	}
+	return current, nil
+}
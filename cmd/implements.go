@@ -23,7 +23,7 @@ func implementsCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			listAll, _ := cmd.Flags().GetBool("list")
 
-			db, err := storage.Open(DbPath)
+			db, err := storage.OpenReadOnly(DbPath)
 			if err != nil {
 				return fmt.Errorf("打开数据库失败: %w", err)
 			}
@@ -42,7 +42,7 @@ func implementsCmd() *cobra.Command {
 					return nil
 				}
 
-				fmt.Printf("项目接口列表 (共 %d 个)\n\n", len(interfaces))
+				fmt.Printf("接口列表 (共 %d 个)\n\n", len(interfaces))
 				for _, iface := range interfaces {
 					methods := display.ShortSignature(iface.Signature)
 					if methods == "" {
@@ -50,7 +50,10 @@ func implementsCmd() *cobra.Command {
 					}
 					fmt.Printf("  %s\n", display.ShortFuncName(iface.Name))
 					fmt.Printf("    方法: %s\n", methods)
-					fmt.Printf("    位置: %s:%d\n\n", iface.File, iface.Line)
+					if iface.File != "" {
+						fmt.Printf("    位置: %s:%d\n", iface.File, iface.Line)
+					}
+					fmt.Println()
 				}
 				return nil
 			}
@@ -73,7 +76,9 @@ func implementsCmd() *cobra.Command {
 				}
 
 				fmt.Printf("接口: %s\n", display.ShortFuncName(iface.Name))
-				fmt.Printf("位置: %s:%d\n", iface.File, iface.Line)
+				if iface.File != "" {
+					fmt.Printf("位置: %s:%d\n", iface.File, iface.Line)
+				}
 				if iface.Signature != "" {
 					fmt.Printf("方法: %s\n", display.ShortSignature(iface.Signature))
 				}
@@ -133,7 +138,10 @@ func implementsCmd() *cobra.Command {
 						}
 						fmt.Printf("  %s\n", display.ShortFuncName(iface.Name))
 						fmt.Printf("    方法: %s\n", methods)
-						fmt.Printf("    位置: %s:%d\n\n", iface.File, iface.Line)
+						if iface.File != "" {
+							fmt.Printf("    位置: %s:%d\n", iface.File, iface.Line)
+						}
+						fmt.Println()
 					}
 				}
 				return nil
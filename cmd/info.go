@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/analyzer"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func infoCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "info",
+		Short: "显示数据库的分析元数据 (何时、如何生成)",
+		Long: `显示图谱的生成信息：项目路径、模块、git 提交及是否有未提交变更、
+Go 与 crag 版本、调用图算法、分析参数、耗时和包数量。
+
+若项目在本机可访问，还会与当前 git HEAD 对比，提示图谱是否已过期。
+
+示例：
+  crag info
+  crag info -d shared.crag.db --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := storage.OpenReadOnly(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			meta, err := db.GetMetadata()
+			if err != nil {
+				return fmt.Errorf("读取元数据失败: %w", err)
+			}
+			nodeCount, edgeCount, _ := db.GetStats()
+			schemaVersion, _ := db.SchemaVersion()
+
+			var warnings []string
+			if meta != nil {
+				warnings = analyzer.CheckStale(meta)
+			}
+
+			if format == "json" {
+				return outputJSON(map[string]any{
+					"database":       DbPath,
+					"schema_version": schemaVersion,
+					"nodes":          nodeCount,
+					"edges":          edgeCount,
+					"metadata":       meta,
+					"warnings":       warnings,
+				})
+			}
+
+			fmt.Printf("数据库: %s (schema 版本 %d)\n", DbPath, schemaVersion)
+			fmt.Printf("图谱: %d 节点, %d 边\n\n", nodeCount, edgeCount)
+
+			if meta == nil {
+				fmt.Println("没有分析元数据 (数据库由旧版本 crag 生成或尚未分析)")
+				fmt.Println("\n💡 提示：重新运行 crag analyze . 以记录元数据")
+				return nil
+			}
+
+			printMetadata(meta)
+
+			if len(warnings) > 0 {
+				fmt.Println("\n⚠️  图谱可能已过期:")
+				for _, w := range warnings {
+					fmt.Printf("  - %s\n", w)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	return cmd
+}
+
+func printMetadata(meta *graph.Metadata) {
+	commit := meta.GitCommit
+	if commit == "" {
+		commit = "(非 git 仓库)"
+	} else if meta.GitDirty {
+		commit += " (有未提交的变更)"
+	}
+	mode := "全量"
+	if meta.Incremental {
+		mode = "增量"
+	}
+	flags := meta.Flags
+	if flags == "" {
+		flags = "(默认)"
+	}
+
+	fmt.Printf("项目路径:   %s\n", meta.ProjectRoot)
+	fmt.Printf("模块:       %s\n", meta.ModulePath)
+	fmt.Printf("git 提交:   %s\n", commit)
+	fmt.Printf("分析时间:   %s\n", meta.AnalyzedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("分析模式:   %s\n", mode)
+	if meta.Snapshot != "" {
+		fmt.Printf("快照:       %s\n", meta.Snapshot)
+	}
+	fmt.Printf("耗时:       %s\n", time.Duration(meta.DurationMs)*time.Millisecond)
+	fmt.Printf("包数量:     %d\n", meta.PackageCount)
+	fmt.Printf("Go 版本:    %s\n", meta.GoVersion)
+	fmt.Printf("crag 版本:  %s\n", meta.CragVersion)
+	fmt.Printf("调用图算法: %s\n", meta.Algorithm)
+	fmt.Printf("分析参数:   %s\n", flags)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/merge"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func mergeCmd() *cobra.Command {
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "merge <a.db> <b.db> [more.db...] -o <org.db>",
+		Short: "合并多个仓库的图谱，解析跨仓库调用",
+		Long: `将多个仓库各自分析得到的数据库合并为一个组织级图谱。
+
+节点 ID 由类型和完整名称决定，同一函数在多个输入中出现时 (例如通过
+--include-module 分析过的共享库) 合并为一个节点，优先采用其所属仓库中的版本。
+分析时记录的外部调用 (调用其他模块的函数) 如果指向另一个输入中的函数，
+会解析为普通的调用边，之后对库函数执行 impact 即可看到所有仓库中的调用者。
+
+合并后各仓库的文件路径加上模块路径前缀 (如 github.com/org/lib/store.go)。
+合并结果不能增量分析，源码变更后请重新分析各仓库并再次合并。
+
+示例：
+  crag merge service/.crag.db lib/.crag.db -o org.db
+  crag impact github.com/org/lib.Save -d org.db`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outputPath == "" {
+				return fmt.Errorf("请使用 -o 指定输出数据库")
+			}
+			outAbs, _ := filepath.Abs(outputPath)
+
+			var sources []*merge.Source
+			for _, path := range args {
+				if abs, _ := filepath.Abs(path); abs == outAbs {
+					return fmt.Errorf("输出数据库不能是输入之一: %s", path)
+				}
+				db, err := storage.OpenReadOnly(path)
+				if err != nil {
+					return fmt.Errorf("打开数据库 %s 失败: %w", path, err)
+				}
+				defer db.Close()
+				sources = append(sources, &merge.Source{Name: path, DB: db})
+			}
+
+			out, err := storage.Open(outputPath)
+			if err != nil {
+				return fmt.Errorf("打开输出数据库失败: %w", err)
+			}
+			defer out.Close()
+
+			batch, err := out.BeginBatch(storage.BatchOptions{})
+			if err != nil {
+				return fmt.Errorf("开始写入事务失败: %w", err)
+			}
+			defer batch.Rollback()
+
+			stats, err := merge.Merge(sources, batch)
+			if err != nil {
+				return fmt.Errorf("合并失败: %w", err)
+			}
+			if err := batch.Commit(); err != nil {
+				return fmt.Errorf("提交写入事务失败: %w", err)
+			}
+
+			for _, s := range stats.Sources {
+				module := s.Module
+				if module == "" {
+					module = "(合并图谱)"
+				}
+				fmt.Printf("  %s  %s: %d 节点", s.Name, module, s.Nodes)
+				if s.Shadowed > 0 {
+					fmt.Printf(" (%d 个由所属仓库的版本取代)", s.Shadowed)
+				}
+				fmt.Printf(", %d 边, %d 外部调用\n", s.Edges, s.External)
+			}
+			fmt.Printf("\n已合并到 %s: %d 节点, %d 边\n", outputPath, stats.Nodes, stats.Edges)
+			fmt.Printf("跨仓库调用: 解析 %d 个, 未解析 %d 个 (指向未合并的模块)\n", stats.Resolved, stats.Unresolved)
+			if stats.Notes > 0 {
+				fmt.Printf("注释: %d 条\n", stats.Notes)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "输出数据库路径")
+	return cmd
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/impact"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func noteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "note",
+		Short: "为函数等节点添加注释，记录设计决策",
+		Long: `为函数、类型、变量等节点添加注释。
+
+注释按节点完整名称保存，重新分析后仍然保留，并显示在 impact、export 和
+MCP 工具的输出中，让设计决策和注意事项始终跟随代码。
+
+示例：
+  crag note add HandleRequest "重试逻辑依赖幂等性，勿移除 requestID 校验"
+  crag note list                 # 所有注释
+  crag note list HandleRequest   # 某个函数的注释`,
+	}
+
+	cmd.AddCommand(noteAddCmd())
+	cmd.AddCommand(noteListCmd())
+	return cmd
+}
+
+func noteAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <function-name> <text>",
+		Short: "为节点添加一条注释",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := storage.Open(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			node, err := resolveNoteTarget(db, args[0])
+			if err != nil {
+				return err
+			}
+
+			note, err := db.AddNote(node.Name, strings.Join(args[1:], " "))
+			if err != nil {
+				return fmt.Errorf("添加注释失败: %w", err)
+			}
+			fmt.Printf("✅ 已为 %s 添加注释 (#%d)\n", display.ShortFuncName(node.Name), note.ID)
+			return nil
+		},
+	}
+}
+
+func noteListCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "list [function-name]",
+		Short: "列出注释",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := storage.OpenReadOnly(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			var notes []*graph.Note
+			if len(args) == 1 {
+				node, err := resolveNoteTarget(db, args[0])
+				if err != nil {
+					return err
+				}
+				notes, err = db.GetNotes(node.Name)
+				if err != nil {
+					return fmt.Errorf("查询失败: %w", err)
+				}
+			} else {
+				notes, err = db.GetAllNotes()
+				if err != nil {
+					return fmt.Errorf("查询失败: %w", err)
+				}
+			}
+
+			if format == "json" {
+				return outputJSON(notes)
+			}
+
+			if len(notes) == 0 {
+				fmt.Println("没有注释")
+				fmt.Println("\n💡 使用 crag note add <函数名> \"注释内容\" 添加注释")
+				return nil
+			}
+
+			for _, group := range groupNotes(notes) {
+				name := group[0].NodeName
+				label := display.ShortFuncName(name)
+				if node, err := db.GetNodeByName(name); err == nil {
+					label += fmt.Sprintf("  %s:%d", node.File, node.Line)
+				} else {
+					label += "  (节点已不存在)"
+				}
+				fmt.Println(label)
+				fmt.Println(impact.FormatNotes(group))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	return cmd
+}
+
+// resolveNoteTarget finds the node a note refers to by exact name, pattern or #ID
+func resolveNoteTarget(db *storage.DB, name string) (*graph.Node, error) {
+	if node, err := db.GetNodeByName(name); err == nil {
+		return node, nil
+	}
+
+	nodes, err := db.FindNodesByPattern(name)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, fmt.Errorf("未找到函数: %s", name)
+	case 1:
+		return nodes[0], nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "'%s' 匹配到 %d 个结果，请使用完整名称或 #ID:\n", name, len(nodes))
+	for i, n := range nodes {
+		fmt.Fprintf(&sb, "  [%d] %s\n      %s:%d  #%d\n", i+1, n.Name, n.File, n.Line, n.ID)
+	}
+	return nil, fmt.Errorf("%s", strings.TrimSuffix(sb.String(), "\n"))
+}
+
+// groupNotes splits notes ordered by node name into one group per node
+func groupNotes(notes []*graph.Note) [][]*graph.Note {
+	var groups [][]*graph.Note
+	for i, n := range notes {
+		if i == 0 || n.NodeName != notes[i-1].NodeName {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], n)
+	}
+	return groups
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/owners"
+)
+
+// ownersReport is the JSON output of crag owners
+type ownersReport struct {
+	Target  *graph.Node     `json:"target"`
+	Owners  []string        `json:"owners"`
+	Callers []*owners.Group `json:"callers"`
+}
+
+func ownersCmd() *cobra.Command {
+	var depth int
+	var format string
+	var backend string
+
+	cmd := &cobra.Command{
+		Use:   "owners <function-name>",
+		Short: "查询函数及其调用者的负责人 (CODEOWNERS)",
+		Long: `根据项目的 CODEOWNERS 文件，显示函数所属的负责人，并按负责人分组列出
+它的上游调用者，用于确定修改前需要通知哪些团队。
+
+CODEOWNERS 按 GitHub/GitLab 的位置查找 (.github/、.gitlab/、仓库根目录、docs/)，
+支持 gitignore 风格的通配符和 GitLab 分节。
+
+示例：
+  crag owners HandleRequest             # 负责人 + 按负责人分组的调用者
+  crag owners HandleRequest --depth 1   # 只看直接调用者`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			funcName := args[0]
+
+			db, store, err := openStore(backend)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			rs := loadOwners(store)
+			if rs == nil {
+				return fmt.Errorf("未找到 CODEOWNERS 文件 (.github/、.gitlab/、仓库根目录或 docs/ 下)")
+			}
+
+			nodes, err := store.FindNodesByPattern(funcName)
+			if err != nil {
+				return fmt.Errorf("查询失败: %w", err)
+			}
+			if len(nodes) == 0 {
+				return fmt.Errorf("未找到函数: %s", funcName)
+			}
+			node := nodes[0]
+			if exact, err := store.GetNodeByName(funcName); err == nil {
+				node = exact
+			}
+
+			callers, err := store.GetUpstreamCallers(node.ID, depth)
+			if err != nil {
+				return fmt.Errorf("查询调用者失败: %w", err)
+			}
+			report := &ownersReport{
+				Target:  node,
+				Owners:  rs.Owners(node.File),
+				Callers: rs.GroupByOwner(callers),
+			}
+
+			if format == "json" {
+				return outputJSON(report)
+			}
+
+			fmt.Printf("📍 %s  %s:%d\n", display.ShortFuncName(node.Name), node.File, node.Line)
+			if len(report.Owners) > 0 {
+				fmt.Printf("👤 负责人: %s\n", strings.Join(report.Owners, " "))
+			} else {
+				fmt.Printf("👤 负责人: %s\n", owners.OwnerLabel(owners.Unowned))
+			}
+			fmt.Printf("   (%s)\n\n", rs.Path)
+
+			if len(report.Callers) == 0 {
+				fmt.Println("👥 调用者\n└── (无)")
+				return nil
+			}
+
+			fmt.Printf("👥 调用者按负责人分组 (深度 %d，共 %d 个负责人)\n", depth, len(report.Callers))
+			for i, g := range report.Callers {
+				prefix, indent := "├──", "│   "
+				if i == len(report.Callers)-1 {
+					prefix, indent = "└──", "    "
+				}
+				fmt.Printf("%s %s  %d 个函数\n", prefix, owners.OwnerLabel(g.Owner), len(g.Nodes))
+				for _, n := range g.Nodes {
+					fmt.Printf("%s  %s  %s:%d\n", indent, display.ShortFuncName(n.Name), n.File, n.Line)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&depth, "depth", 7, "上游调用者追溯深度")
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	addBackendFlag(cmd, &backend)
+
+	return cmd
+}
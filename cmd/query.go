@@ -23,7 +23,7 @@ func upstreamCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			funcName := args[0]
 
-			db, err := storage.Open(DbPath)
+			db, err := storage.OpenReadOnly(DbPath)
 			if err != nil {
 				return fmt.Errorf("打开数据库失败: %w", err)
 			}
@@ -44,7 +44,7 @@ func upstreamCmd() *cobra.Command {
 						} else {
 							fmt.Println("找到多个匹配的函数，请选择:")
 							for i, n := range nodes {
-								fmt.Printf("  [%d] %s\n      %s:%d\n", i+1, display.ShortFuncName(n.Name), n.File, n.Line)
+								fmt.Printf("  [%d] %s\n      %s:%d  #%d\n", i+1, display.ShortFuncName(n.Name), n.File, n.Line, n.ID)
 							}
 							fmt.Print("\n请输入序号 [1-" + fmt.Sprint(len(nodes)) + "]: ")
 
@@ -130,7 +130,7 @@ func downstreamCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			funcName := args[0]
 
-			db, err := storage.Open(DbPath)
+			db, err := storage.OpenReadOnly(DbPath)
 			if err != nil {
 				return fmt.Errorf("打开数据库失败: %w", err)
 			}
@@ -151,7 +151,7 @@ func downstreamCmd() *cobra.Command {
 						} else {
 							fmt.Println("找到多个匹配的函数，请选择:")
 							for i, n := range nodes {
-								fmt.Printf("  [%d] %s\n      %s:%d\n", i+1, display.ShortFuncName(n.Name), n.File, n.Line)
+								fmt.Printf("  [%d] %s\n      %s:%d  #%d\n", i+1, display.ShortFuncName(n.Name), n.File, n.Line, n.ID)
 							}
 							fmt.Print("\n请输入序号 [1-" + fmt.Sprint(len(nodes)) + "]: ")
 
@@ -230,15 +230,46 @@ func impactCmd() *cobra.Command {
 	var downstreamDepth int
 	var format string
 	var selectN int
+	var diff bool
+	var patch string
+	var applied bool
 
 	cmd := &cobra.Command{
-		Use:   "impact <function-name>",
+		Use:   "impact <function-name> | --diff [base] | --patch <file>",
 		Short: "分析函数变更的影响范围",
-		Args:  cobra.ExactArgs(1),
+		Long: `分析函数变更的影响范围：调用者、被调用者、实现、引用和负责人。
+
+--diff 分析 git 工作区相对 base (默认 HEAD) 的全部改动，--patch 分析补丁文件
+(- 表示标准输入)。变更行按图谱记录的行范围归属到所在的函数、变量或常量，
+输出一份汇总报告：变更的符号、它们的全部调用者以及整体风险等级。
+文件内容与分析时一致时按变更后的行号匹配，否则按变更前的行号；补丁默认
+按变更前的行号匹配 (评审尚未应用的补丁)，已应用的补丁加 --applied。
+
+示例：
+  crag impact storage.Open
+  crag impact --diff
+  crag impact --diff main --format markdown
+  git format-patch -1 --stdout | crag impact --patch -`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if diff || patch != "" {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if diff || patch != "" {
+				if patch != "" && len(args) > 0 {
+					return fmt.Errorf("--patch 不能同时指定 base")
+				}
+				base := ""
+				if len(args) > 0 {
+					base = args[0]
+				}
+				return runDiffImpact(base, patch, applied, upstreamDepth, format)
+			}
 			funcName := args[0]
 
-			db, err := storage.Open(DbPath)
+			db, err := storage.OpenReadOnly(DbPath)
 			if err != nil {
 				return fmt.Errorf("打开数据库失败: %w", err)
 			}
@@ -259,7 +290,7 @@ func impactCmd() *cobra.Command {
 						} else {
 							fmt.Println("找到多个匹配的函数，请选择:")
 							for i, n := range nodes {
-								fmt.Printf("  [%d] %s\n      %s:%d\n", i+1, display.ShortFuncName(n.Name), n.File, n.Line)
+								fmt.Printf("  [%d] %s\n      %s:%d  #%d\n", i+1, display.ShortFuncName(n.Name), n.File, n.Line, n.ID)
 							}
 							fmt.Print("\n请输入序号 [1-" + fmt.Sprint(len(nodes)) + "]: ")
 
@@ -282,6 +313,8 @@ func impactCmd() *cobra.Command {
 				}
 			}
 
+			report.GroupByOwner(loadOwners(db))
+
 			switch format {
 			case "json":
 				return outputJSON(report)
@@ -299,7 +332,13 @@ func impactCmd() *cobra.Command {
 					if report.Target.Signature != "" {
 						fmt.Printf("   类型: %s\n", report.Target.Signature)
 					}
+					if report.Target.Value != "" {
+						fmt.Printf("   值: %s\n", report.Target.Value)
+					}
 					fmt.Println()
+					if notes := report.FormatNotes(); notes != "" {
+						fmt.Println(notes)
+					}
 
 					if len(report.DirectCallers) > 0 {
 						fmt.Printf("⬆️ 引用此%s的函数 (共 %d 个)\n", kindLabel, len(report.DirectCallers))
@@ -314,6 +353,10 @@ func impactCmd() *cobra.Command {
 						fmt.Printf("⬆️ 引用此%s的函数\n", kindLabel)
 						fmt.Println("└── (无)")
 					}
+					if derived := report.FormatDerived(); derived != "" {
+						fmt.Println()
+						fmt.Print(derived)
+					}
 				} else {
 					upstreamTree, err := db.GetUpstreamCallTree(report.Target.ID, upstreamDepth)
 					if err != nil {
@@ -341,6 +384,9 @@ func impactCmd() *cobra.Command {
 						fmt.Printf("   %s\n", display.ShortSignature(report.Target.Signature))
 					}
 					fmt.Println()
+					if notes := report.FormatNotes(); notes != "" {
+						fmt.Println(notes)
+					}
 
 					if len(upstreamTree) > 0 {
 						fmt.Printf("⬆️ 调用者 (深度 %d)\n", upstreamDepth)
@@ -358,6 +404,22 @@ func impactCmd() *cobra.Command {
 						fmt.Println("⬇️ 被调用")
 						fmt.Println("└── (无)")
 					}
+					if impls := report.FormatImplementations(); impls != "" {
+						fmt.Println()
+						fmt.Print(impls)
+					}
+					if asserts := report.FormatTypeAssertions(); asserts != "" {
+						fmt.Println()
+						fmt.Print(asserts)
+					}
+					if derived := report.FormatDerived(); derived != "" {
+						fmt.Println()
+						fmt.Print(derived)
+					}
+				}
+				if owners := report.FormatOwners(); owners != "" {
+					fmt.Println()
+					fmt.Print(owners)
 				}
 			}
 
@@ -369,6 +431,9 @@ func impactCmd() *cobra.Command {
 	cmd.Flags().IntVar(&downstreamDepth, "downstream-depth", 7, "下游递归深度")
 	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json/markdown)")
 	cmd.Flags().IntVar(&selectN, "select", 0, "当匹配到多个函数时，直接选择第N个（跳过交互提示）")
+	cmd.Flags().BoolVar(&diff, "diff", false, "分析 git 工作区相对 base (默认 HEAD) 的改动")
+	cmd.Flags().StringVar(&patch, "patch", "", "分析补丁文件中的改动 (- 表示标准输入)")
+	cmd.Flags().BoolVar(&applied, "applied", false, "补丁已应用到项目 (按变更后的行号匹配)")
 
 	return cmd
 }
@@ -381,7 +446,7 @@ func listCmd() *cobra.Command {
 		Use:   "list",
 		Short: "列出所有函数/变量/常量",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			db, err := storage.Open(DbPath)
+			db, err := storage.OpenReadOnly(DbPath)
 			if err != nil {
 				return fmt.Errorf("打开数据库失败: %w", err)
 			}
@@ -435,20 +500,35 @@ func listCmd() *cobra.Command {
 }
 
 func searchCmd() *cobra.Command {
+	var limit int
+
 	cmd := &cobra.Command{
-		Use:   "search <pattern>",
+		Use:   "search <query>",
 		Short: "搜索函数/变量/常量",
-		Args:  cobra.ExactArgs(1),
+		Long: `全文搜索节点名称、文档注释、签名和包路径，按 BM25 相关度排序。
+
+查询语法：
+  - 多个词同时匹配：parse config
+  - 驼峰名按单词匹配：FindNodes 可找到 FindNodesByPattern
+  - 前缀匹配：Handl*
+  - 短语："error handling"
+  - 字段过滤：doc:<词> 文档注释，sig:<词> 签名，pkg:<词> 包路径
+
+示例：
+  crag search Handler
+  crag search "doc:retry sig:context"
+  crag search "pkg:storage Node*"`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			pattern := args[0]
+			query := strings.Join(args, " ")
 
-			db, err := storage.Open(DbPath)
+			db, err := storage.OpenReadOnly(DbPath)
 			if err != nil {
 				return fmt.Errorf("打开数据库失败: %w", err)
 			}
 			defer db.Close()
 
-			nodes, err := db.FindNodesByPattern(pattern)
+			nodes, err := db.SearchNodes(query, limit)
 			if err != nil {
 				return fmt.Errorf("搜索失败: %w", err)
 			}
@@ -460,12 +540,17 @@ func searchCmd() *cobra.Command {
 
 			fmt.Printf("找到 %d 个匹配:\n\n", len(nodes))
 			for _, n := range nodes {
-				fmt.Printf("  [%s] %s\n    %s:%d\n", n.Kind, n.Name, n.File, n.Line)
+				fmt.Printf("  [%s] %s\n    %s:%d  #%d\n", n.Kind, n.Name, n.File, n.Line, n.ID)
+				if n.ModuleVersion != "" {
+					fmt.Printf("    模块: %s@%s\n", n.Module, n.ModuleVersion)
+				}
 			}
 
 			return nil
 		},
 	}
 
+	cmd.Flags().IntVar(&limit, "limit", 50, "最多显示数量 (0=全部)")
+
 	return cmd
 }
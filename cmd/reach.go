@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+func reachCmd() *cobra.Command {
+	var format string
+	var backend string
+
+	cmd := &cobra.Command{
+		Use:   "reach <caller> <callee>",
+		Short: "判断一个函数是否(直接或间接)调用另一个函数",
+		Long: `判断 caller 的调用链中是否会执行到 callee。
+
+基于分析后预先计算的可达性索引，不受调用深度限制，递归调用也能正确处理。
+函数名可以是完整名、短名称或 #ID，多个匹配时取第一个函数。
+
+示例：
+  crag reach main storage.Save
+  crag reach '(*Server).handleToolsCall' '#123' --format json`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, store, err := openStore(backend)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			from, err := findFunc(store, args[0])
+			if err != nil {
+				return err
+			}
+			to, err := findFunc(store, args[1])
+			if err != nil {
+				return err
+			}
+			reaches, err := store.Reaches(from.ID, to.ID)
+			if err != nil {
+				return fmt.Errorf("查询失败: %w", err)
+			}
+
+			if format == "json" {
+				return outputJSON(map[string]any{
+					"from":    from,
+					"to":      to,
+					"reaches": reaches,
+				})
+			}
+
+			fromName, toName := display.ShortFuncName(from.Name), display.ShortFuncName(to.Name)
+			if reaches {
+				fmt.Printf("✅ %s 会调用到 %s\n", fromName, toName)
+				fmt.Printf("\n💡 使用 crag query 'callees*(#%d) & callers*(#%d)' 查看途经的函数\n", from.ID, to.ID)
+			} else {
+				fmt.Printf("❌ %s 不会调用到 %s\n", fromName, toName)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+	addBackendFlag(cmd, &backend)
+
+	return cmd
+}
+
+// findFunc returns the first function matching a name, short name or #ID
+func findFunc(store storage.Store, name string) (*graph.Node, error) {
+	nodes, err := store.FindNodesByPattern(name)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	for _, n := range nodes {
+		if n.Kind == graph.NodeKindFunc {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("未找到函数: %s", name)
+}
@@ -2,14 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/zheng/crag/internal/display"
-	"github.com/zheng/crag/internal/storage"
+	"github.com/zheng/crag/internal/impact"
 )
 
 func riskCmd() *cobra.Command {
 	var limit int
+	var ownerDepth int
+	var backend string
 
 	cmd := &cobra.Command{
 		Use:   "risk [function-name]",
@@ -29,14 +32,14 @@ func riskCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			showTop, _ := cmd.Flags().GetBool("top")
 
-			db, err := storage.Open(DbPath)
+			db, store, err := openStore(backend)
 			if err != nil {
-				return fmt.Errorf("打开数据库失败: %w", err)
+				return err
 			}
 			defer db.Close()
 
 			if showTop || len(args) == 0 {
-				risks, err := db.GetTopRiskyFunctions(limit)
+				risks, err := store.GetTopRiskyFunctions(limit)
 				if err != nil {
 					return fmt.Errorf("查询失败: %w", err)
 				}
@@ -46,21 +49,26 @@ func riskCmd() *cobra.Command {
 					return nil
 				}
 
+				rs := loadOwners(store)
 				fmt.Printf("高风险函数排行 (Top %d)\n\n", limit)
 				for _, r := range risks {
 					riskIcon := getRiskIcon(r.RiskLevel)
 					fmt.Printf("%s %-8s  %s\n", riskIcon, r.RiskLevel, display.ShortFuncName(r.Node.Name))
-					fmt.Printf("             调用者: %d  %s:%d\n\n", r.DirectCallers, r.Node.File, r.Node.Line)
+					fmt.Printf("             调用者: 直接 %d / 总 %d  %s:%d\n", r.DirectCallers, r.TotalCallers, r.Node.File, r.Node.Line)
+					if nodeOwners := rs.Owners(r.Node.File); len(nodeOwners) > 0 {
+						fmt.Printf("             负责人: %s\n", strings.Join(nodeOwners, " "))
+					}
+					fmt.Println()
 				}
 
-				fmt.Println("风险等级: 🔴critical(>=50) 🟠high(>=20) 🟡medium(>=5) 🟢low")
+				fmt.Println("风险等级: 🔴critical(直接>=50 或总>=200) 🟠high(>=20/100) 🟡medium(>=5/30) 🟢low")
 				fmt.Println("\n💡 使用 crag risk <函数名> 查看详细分析")
 				return nil
 			}
 
 			funcName := args[0]
 
-			nodes, err := db.FindNodesByPattern(funcName)
+			nodes, err := store.FindNodesByPattern(funcName)
 			if err != nil {
 				return fmt.Errorf("查询失败: %w", err)
 			}
@@ -70,7 +78,7 @@ func riskCmd() *cobra.Command {
 			}
 
 			node := nodes[0]
-			risk, err := db.GetRiskScore(node.ID)
+			risk, err := store.GetRiskScore(node.ID)
 			if err != nil {
 				return fmt.Errorf("计算风险失败: %w", err)
 			}
@@ -85,6 +93,23 @@ func riskCmd() *cobra.Command {
 
 			fmt.Printf("### 风险等级: %s %s\n\n", riskIcon, risk.RiskLevel)
 			fmt.Printf("直接调用者: %d\n", risk.DirectCallers)
+			if risk.TotalCallers > 0 {
+				fmt.Printf("总调用者: %d (最长调用链 %d 层)\n", risk.TotalCallers, risk.MaxDepth)
+			}
+
+			if rs := loadOwners(store); rs != nil {
+				callers, err := store.GetUpstreamCallers(node.ID, ownerDepth)
+				if err != nil {
+					return fmt.Errorf("查询调用者失败: %w", err)
+				}
+				if nodeOwners := rs.Owners(node.File); len(nodeOwners) > 0 {
+					fmt.Printf("负责人: %s\n", strings.Join(nodeOwners, " "))
+				}
+				if groups := rs.GroupByOwner(callers); len(groups) > 0 {
+					fmt.Println()
+					fmt.Print(impact.FormatOwnerGroups(fmt.Sprintf("👥 受影响的负责人 (%d 层内调用者)", ownerDepth), groups))
+				}
+			}
 
 			fmt.Println("\n**建议:**")
 			switch risk.RiskLevel {
@@ -110,7 +135,9 @@ func riskCmd() *cobra.Command {
 	}
 
 	cmd.Flags().IntVar(&limit, "limit", 20, "显示数量")
+	cmd.Flags().IntVar(&ownerDepth, "owner-depth", 7, "按负责人分组时追溯的上游调用深度")
 	cmd.Flags().Bool("top", false, "显示风险最高的函数列表")
+	addBackendFlag(cmd, &backend)
 
 	return cmd
 }
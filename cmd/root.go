@@ -22,4 +22,18 @@ func RegisterCommands(rootCmd *cobra.Command) {
 	rootCmd.AddCommand(viewCmd())
 	rootCmd.AddCommand(implementsCmd())
 	rootCmd.AddCommand(riskCmd())
+	rootCmd.AddCommand(tagsCmd())
+	rootCmd.AddCommand(enumCmd())
+	rootCmd.AddCommand(apidiffCmd())
+	rootCmd.AddCommand(dbCmd())
+	rootCmd.AddCommand(infoCmd())
+	rootCmd.AddCommand(diffCmd())
+	rootCmd.AddCommand(noteCmd())
+	rootCmd.AddCommand(ownersCmd())
+	rootCmd.AddCommand(dumpCmd())
+	rootCmd.AddCommand(loadCmd())
+	rootCmd.AddCommand(mergeCmd())
+	rootCmd.AddCommand(queryCmd())
+	rootCmd.AddCommand(reachCmd())
+	rootCmd.AddCommand(doctorCmd())
 }
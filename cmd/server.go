@@ -17,6 +17,8 @@ import (
 )
 
 func mcpCmd() *cobra.Command {
+	var backend string
+
 	cmd := &cobra.Command{
 		Use:   "mcp",
 		Short: "启动 MCP (Model Context Protocol) 服务器",
@@ -29,22 +31,26 @@ MCP 工具包括：
   - search: 搜索函数
   - list: 列出所有函数`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			db, err := storage.Open(DbPath)
+			db, store, err := openStore(backend)
 			if err != nil {
-				return fmt.Errorf("打开数据库失败: %w", err)
+				return err
 			}
 			defer db.Close()
 
-			server := mcp.NewServer(db)
+			server := mcp.NewServer(store)
 			return server.Run()
 		},
 	}
 
+	addBackendFlag(cmd, &backend)
+
 	return cmd
 }
 
 func watchCmd() *cobra.Command {
 	var debounceMs int
+	var includeModules []string
+	var externalIfaces []string
 
 	cmd := &cobra.Command{
 		Use:   "watch [project-path]",
@@ -69,7 +75,7 @@ func watchCmd() *cobra.Command {
 			}
 
 			fmt.Println("执行初始分析...")
-			nodeCount, edgeCount, err := runInitialAnalysis(projectPath, DbPath)
+			nodeCount, edgeCount, err := runInitialAnalysis(projectPath, DbPath, includeModules, externalIfaces)
 			if err != nil {
 				return fmt.Errorf("初始分析失败: %w", err)
 			}
@@ -85,6 +91,8 @@ func watchCmd() *cobra.Command {
 				projectPath,
 				DbPath,
 				watcher.WithDebounceDelay(time.Duration(debounceMs)*time.Millisecond),
+				watcher.WithIncludeModules(includeModules),
+				watcher.WithExternalInterfaces(externalIfaces),
 				watcher.WithOnAnalysisStart(func() {
 					fmt.Printf("[%s] 检测到变更，开始分析...\n", time.Now().Format("15:04:05"))
 				}),
@@ -113,12 +121,15 @@ func watchCmd() *cobra.Command {
 	}
 
 	cmd.Flags().IntVar(&debounceMs, "debounce", 500, "防抖延迟（毫秒）")
+	cmd.Flags().StringSliceVar(&includeModules, "include-module", nil, "将匹配的依赖模块包作为项目代码分析 (可重复)")
+	cmd.Flags().StringSliceVar(&externalIfaces, "external-iface", analyzer.DefaultExternalInterfaces, "检测项目类型是否实现的外部接口 (可重复)")
 
 	return cmd
 }
 
-func runInitialAnalysis(projectPath, dbPath string) (nodeCount, edgeCount int64, err error) {
-	pkgs, err := analyzer.LoadPackages(projectPath)
+func runInitialAnalysis(projectPath, dbPath string, includeModules, externalIfaces []string) (nodeCount, edgeCount int64, err error) {
+	start := time.Now()
+	pkgs, err := analyzer.LoadPackages(projectPath, includeModules...)
 	if err != nil {
 		return 0, 0, fmt.Errorf("加载包失败: %w", err)
 	}
@@ -141,7 +152,14 @@ func runInitialAnalysis(projectPath, dbPath string) (nodeCount, edgeCount int64,
 	}
 	defer db.Close()
 
-	if err := db.Clear(); err != nil {
+	// Write everything in one transaction; on failure the previous graph is kept
+	batch, err := db.BeginBatch(storage.BatchOptions{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("开始写入事务失败: %w", err)
+	}
+	defer batch.Rollback()
+
+	if err := batch.Clear(); err != nil {
 		return 0, 0, fmt.Errorf("清空数据库失败: %w", err)
 	}
 
@@ -149,19 +167,65 @@ func runInitialAnalysis(projectPath, dbPath string) (nodeCount, edgeCount int64,
 		prog.Fset,
 		pkgs,
 		projectPath,
-		db.InsertNode,
-		db.InsertEdge,
+		batch.InsertNode,
+		batch.InsertEdge,
 	)
 
+	builder.SetExternalCallFn(batch.InsertExternalCall)
+
 	if err := builder.Build(cg); err != nil {
 		return 0, 0, fmt.Errorf("构建图失败: %w", err)
 	}
 
 	interfaceAnalyzer := analyzer.NewInterfaceAnalyzer(pkgs, projectPath)
+	interfaceAnalyzer.SetExternalInterfaces(externalIfaces)
 	_, _, _, _ = interfaceAnalyzer.BuildInterfaceGraph(
-		db.InsertNode,
-		db.InsertEdge,
+		batch.InsertNode,
+		batch.InsertEdge,
+	)
+	_, _ = builder.BuildTypeAssertions(prog, interfaceAnalyzer.GetNamedTypeNodeMap())
+	_, _, _, _ = interfaceAnalyzer.BuildInterfaceMethodGraph(
+		batch.InsertNode,
+		batch.InsertEdge,
+		builder.GetNodeMap(),
+	)
+
+	varConstAnalyzer := analyzer.NewVarConstAnalyzer(pkgs, projectPath)
+	_, _, _, _ = varConstAnalyzer.BuildVarConstGraph(
+		batch.InsertNode,
+		batch.InsertEdge,
+		builder.GetNodeMap(),
 	)
+	_, _, _, _ = varConstAnalyzer.BuildEnumGraph(
+		batch.InsertNode,
+		batch.InsertEdge,
+		batch.InsertEnumSwitch,
+		builder.GetNodeMap(),
+	)
+
+	structTagAnalyzer := analyzer.NewStructTagAnalyzer(pkgs, projectPath)
+	_, _, _, _ = structTagAnalyzer.BuildStructTagGraph(
+		batch.InsertNode,
+		batch.InsertEdge,
+		batch.InsertFieldTag,
+		interfaceAnalyzer.GetTypeNodeMap(),
+		builder.GetNodeMap(),
+	)
+
+	// Record the source file hashes so that a later crag analyze -i can compare against them
+	if files, err := analyzer.ScanSourceFiles(projectPath, nil); err == nil {
+		analyzer.SetFilePackages(files, projectPath, pkgs)
+		_ = batch.SaveSourceFiles(files)
+	}
+
+	meta := analyzer.CollectMetadata(projectPath, pkgs, analyzer.AnalysisFlags(includeModules, externalIfaces))
+	meta.DurationMs = time.Since(start).Milliseconds()
+	meta.AnalyzedAt = time.Now()
+	_ = batch.SaveMetadata(meta)
+
+	if err := batch.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("提交写入事务失败: %w", err)
+	}
 
 	nodeCount, edgeCount, _ = db.GetStats()
 	return nodeCount, edgeCount, nil
@@ -169,6 +233,7 @@ func runInitialAnalysis(projectPath, dbPath string) (nodeCount, edgeCount int64,
 
 func viewCmd() *cobra.Command {
 	var port int
+	var backend string
 
 	cmd := &cobra.Command{
 		Use:   "view",
@@ -186,18 +251,19 @@ func viewCmd() *cobra.Command {
   crag view -p 3000      # 指定端口
   crag view -d my.db     # 指定数据库`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			db, err := storage.Open(DbPath)
+			db, store, err := openStore(backend)
 			if err != nil {
-				return fmt.Errorf("打开数据库失败: %w", err)
+				return err
 			}
 			defer db.Close()
 
-			server := web.NewServer(db, port)
+			server := web.NewServer(store, port)
 			return server.Run()
 		},
 	}
 
 	cmd.Flags().IntVarP(&port, "port", "p", 9998, "服务器端口")
+	addBackendFlag(cmd, &backend)
 
 	return cmd
 }
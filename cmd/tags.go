@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// tagMatch groups a tagged field with its owning struct and the struct's users
+type tagMatch struct {
+	Field  *graph.Node          `json:"field"`
+	Tag    *graph.FieldTag      `json:"tag"`
+	Struct *graph.Node          `json:"struct,omitempty"`
+	Users  []*storage.TypeUsage `json:"users,omitempty"`
+}
+
+func tagsCmd() *cobra.Command {
+	var key string
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "tags <wire-name>",
+		Short: "查询结构体字段标签 (json/db/yaml) 的影响范围",
+		Long: `根据结构体标签中的线上名称（如 json:"user_id"）查找对应的结构体字段，
+并列出构造或解码这些结构体的函数，用于评估线上格式变更的影响。
+
+示例：
+  crag tags --key json user_id   # 哪些结构体以 user_id 序列化
+  crag tags --key db created_at  # 哪些结构体映射 created_at 列
+  crag tags user_id              # 匹配所有标签键`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wireName := args[0]
+
+			db, err := storage.OpenReadOnly(DbPath)
+			if err != nil {
+				return fmt.Errorf("打开数据库失败: %w", err)
+			}
+			defer db.Close()
+
+			fields, err := db.FindFieldsByTag(key, wireName)
+			if err != nil {
+				return fmt.Errorf("查询失败: %w", err)
+			}
+
+			matches := make([]*tagMatch, 0, len(fields))
+			for _, f := range fields {
+				m := &tagMatch{Field: f.Field, Tag: f.Tag}
+				if owner, err := db.GetFieldOwner(f.Field.ID); err == nil {
+					m.Struct = owner
+					m.Users, err = db.GetStructUsers(owner.ID)
+					if err != nil {
+						return fmt.Errorf("查询结构体使用者失败: %w", err)
+					}
+				}
+				matches = append(matches, m)
+			}
+
+			if format == "json" {
+				return outputJSON(matches)
+			}
+
+			label := wireName
+			if key != "" {
+				label = fmt.Sprintf("%s:\"%s\"", key, wireName)
+			}
+
+			if len(matches) == 0 {
+				fmt.Printf("未找到标签 %s 对应的字段\n", label)
+				fmt.Println("\n💡 提示：请先运行 analyze 命令分析项目：")
+				fmt.Println("   crag analyze .")
+				return nil
+			}
+
+			fmt.Printf("标签 %s (共 %d 个字段)\n\n", label, len(matches))
+			for _, m := range matches {
+				fmt.Printf("  %s\n", display.ShortFuncName(m.Field.Name))
+				tagStr := fmt.Sprintf("%s:\"%s", m.Tag.Key, m.Tag.Name)
+				if m.Tag.Options != "" {
+					tagStr += "," + m.Tag.Options
+				}
+				tagStr += "\""
+				fmt.Printf("    标签: %s\n", tagStr)
+				fmt.Printf("    位置: %s:%d\n", m.Field.File, m.Field.Line)
+				if m.Struct == nil {
+					fmt.Println()
+					continue
+				}
+
+				fmt.Printf("    结构体: %s\n", display.ShortFuncName(m.Struct.Name))
+				if len(m.Users) == 0 {
+					fmt.Println("    └── (没有构造或解码此结构体的函数)")
+				}
+				for i, u := range m.Users {
+					prefix := "├──"
+					if i == len(m.Users)-1 {
+						prefix = "└──"
+					}
+					fmt.Printf("    %s %s %s  %s:%d\n", prefix, usageLabel(u.Kind), display.ShortFuncName(u.Func.Name), u.File, u.Line)
+				}
+				fmt.Println()
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&key, "key", "", "标签键，如 json/db/yaml (默认匹配所有)")
+	cmd.Flags().StringVar(&format, "format", "text", "输出格式 (text/json)")
+
+	return cmd
+}
+
+func usageLabel(kind graph.EdgeKind) string {
+	switch kind {
+	case graph.EdgeKindConstructs:
+		return "[构造]"
+	case graph.EdgeKindDecodes:
+		return "[解码]"
+	default:
+		return "[" + string(kind) + "]"
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/spf13/cobra"
 	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/owners"
 	"github.com/zheng/crag/internal/storage"
 )
 
@@ -15,6 +17,51 @@ func outputJSON(v any) error {
 	return enc.Encode(v)
 }
 
+// Query backends selectable with --backend
+const (
+	backendMemory = "memory"
+	backendSQLite = "sqlite"
+)
+
+// addBackendFlag registers the --backend flag for commands that query the graph
+func addBackendFlag(cmd *cobra.Command, backend *string) {
+	cmd.Flags().StringVar(backend, "backend", backendMemory, "查询后端 (memory: 载入内存后遍历, sqlite: 直接查询数据库)")
+}
+
+// openStore opens the database read-only and wraps it in the requested query
+// backend, so that long-running readers (mcp, view) never hold write locks while
+// analyze or watch rewrites the graph. The returned DB must be closed by the caller.
+func openStore(backend string) (*storage.DB, storage.Store, error) {
+	if backend != backendMemory && backend != backendSQLite {
+		return nil, nil, fmt.Errorf("未知的查询后端: %s (可选: %s, %s)", backend, backendMemory, backendSQLite)
+	}
+	db, err := storage.OpenReadOnly(DbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+	if backend == backendSQLite {
+		return db, db, nil
+	}
+	store, err := storage.NewMemStore(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("载入图谱失败: %w", err)
+	}
+	return db, store, nil
+}
+
+// loadOwners loads the CODEOWNERS file of the analyzed project. It returns nil
+// if there is none; a malformed file is reported as a warning.
+func loadOwners(store storage.Store) *owners.Ruleset {
+	meta, _ := store.GetMetadata()
+	rs, err := owners.LoadForGraph(meta)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  读取 CODEOWNERS 失败: %v\n", err)
+		return nil
+	}
+	return rs
+}
+
 // shortFilePath returns the file path as-is (already relative to project root)
 func shortFilePath(fullPath string) string {
 	return fullPath
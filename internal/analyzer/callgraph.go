@@ -7,6 +7,9 @@ import (
 	"golang.org/x/tools/go/ssa/ssautil"
 )
 
+// CallGraphAlgorithm is the call graph construction algorithm used by BuildCallGraph
+const CallGraphAlgorithm = "vta"
+
 // BuildCallGraph builds the call graph using VTA (Variable Type Analysis)
 // VTA is more precise than other algorithms for handling interface calls
 func BuildCallGraph(prog *ssa.Program) (*callgraph.Graph, error) {
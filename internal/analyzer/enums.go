@@ -0,0 +1,351 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// EnumInfo represents a named type whose package-level constants form an enumeration
+// (typically declared with iota)
+type EnumInfo struct {
+	Name       string        // Full type name: pkg.Type
+	Package    string        // Package path
+	File       string        // Source file (relative)
+	Line       int           // Line number
+	Underlying string        // Underlying basic type (int, string...)
+	Doc        string        // Type doc comment
+	Members    []*EnumMember // Members in declaration order
+}
+
+// EnumMember is a constant of an enum type
+type EnumMember struct {
+	Name  string // Full const name: pkg.Const
+	Value string // Exact constant value
+}
+
+// EnumSwitchInfo represents a switch statement over an enum type
+type EnumSwitchInfo struct {
+	FuncName   string   // Function containing the switch
+	EnumName   string   // Full enum type name
+	File       string   // Switch file (relative)
+	Line       int      // Switch line
+	Missing    []string // Short names of members without a case
+	HasDefault bool     // Whether the switch has a default clause
+}
+
+// minEnumMembers is the minimum number of constants for a type to be treated as an enum
+const minEnumMembers = 2
+
+// shouldInclude reports whether a package-level var/const gets a node.
+// Exported names always do; unexported constants are kept when they are enum members,
+// so that switch exhaustiveness can be reported against the full member list.
+func (a *VarConstAnalyzer) shouldInclude(obj types.Object) bool {
+	if obj.Exported() {
+		return true
+	}
+	c, ok := obj.(*types.Const)
+	if !ok {
+		return false
+	}
+	named, ok := types.Unalias(c.Type()).(*types.Named)
+	return ok && a.getEnumTypes()[named.Obj()]
+}
+
+// getEnumTypes returns the named project types that have enough constants to be enums
+func (a *VarConstAnalyzer) getEnumTypes() map[*types.TypeName]bool {
+	if a.enumTypes != nil {
+		return a.enumTypes
+	}
+
+	counts := make(map[*types.TypeName]int)
+	for _, pkg := range a.pkgs {
+		if pkg.Types == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			c, ok := scope.Lookup(name).(*types.Const)
+			if !ok {
+				continue
+			}
+			named, ok := types.Unalias(c.Type()).(*types.Named)
+			if !ok || named.Obj().Pkg() != pkg.Types {
+				continue
+			}
+			if _, ok := named.Underlying().(*types.Basic); !ok {
+				continue
+			}
+			counts[named.Obj()]++
+		}
+	}
+
+	a.enumTypes = make(map[*types.TypeName]bool)
+	for typeName, n := range counts {
+		if n >= minEnumMembers {
+			a.enumTypes[typeName] = true
+		}
+	}
+	return a.enumTypes
+}
+
+// AnalyzeEnums groups constants that share a named type into enums
+func (a *VarConstAnalyzer) AnalyzeEnums() []*EnumInfo {
+	enumTypes := a.getEnumTypes()
+	var results []*EnumInfo
+
+	for _, pkg := range a.pkgs {
+		if pkg.Types == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+
+		byType := make(map[*types.TypeName]*EnumInfo)
+		var order []*types.TypeName
+		var consts []*types.Const
+
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			switch obj := scope.Lookup(name).(type) {
+			case *types.TypeName:
+				if !enumTypes[obj] {
+					continue
+				}
+				pos := pkg.Fset.Position(obj.Pos())
+				byType[obj] = &EnumInfo{
+					Name:       pkg.PkgPath + "." + name,
+					Package:    pkg.PkgPath,
+					File:       a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename),
+					Line:       pos.Line,
+					Underlying: obj.Type().Underlying().String(),
+					Doc:        a.getTypeDoc(pkg, name),
+				}
+				order = append(order, obj)
+			case *types.Const:
+				consts = append(consts, obj)
+			}
+		}
+
+		// Members in declaration order so that iota enums read naturally
+		sort.Slice(consts, func(i, j int) bool { return consts[i].Pos() < consts[j].Pos() })
+		for _, c := range consts {
+			named, ok := types.Unalias(c.Type()).(*types.Named)
+			if !ok {
+				continue
+			}
+			enum, ok := byType[named.Obj()]
+			if !ok {
+				continue
+			}
+			enum.Members = append(enum.Members, &EnumMember{
+				Name:  pkg.PkgPath + "." + c.Name(),
+				Value: c.Val().ExactString(),
+			})
+		}
+
+		for _, typeName := range order {
+			results = append(results, byType[typeName])
+		}
+	}
+
+	return results
+}
+
+// FindEnumSwitches finds switch statements whose tag has an enum type and
+// records which members are not handled by any case
+func (a *VarConstAnalyzer) FindEnumSwitches() []*EnumSwitchInfo {
+	enumTypes := a.getEnumTypes()
+	if len(enumTypes) == 0 {
+		return nil
+	}
+
+	// Collect member values per enum type
+	type memberValue struct {
+		shortName string
+		value     string
+	}
+	members := make(map[*types.TypeName][]memberValue)
+	for _, pkg := range a.pkgs {
+		if pkg.Types == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			c, ok := scope.Lookup(name).(*types.Const)
+			if !ok {
+				continue
+			}
+			named, ok := types.Unalias(c.Type()).(*types.Named)
+			if !ok || !enumTypes[named.Obj()] {
+				continue
+			}
+			members[named.Obj()] = append(members[named.Obj()], memberValue{name, c.Val().ExactString()})
+		}
+	}
+
+	var results []*EnumSwitchInfo
+	for _, pkg := range a.pkgs {
+		if pkg.TypesInfo == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+
+		for _, astFile := range pkg.Syntax {
+			for _, decl := range astFile.Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Body == nil {
+					continue
+				}
+				funcName := funcFullName(pkg, funcDecl)
+
+				ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+					sw, ok := n.(*ast.SwitchStmt)
+					if !ok || sw.Tag == nil {
+						return true
+					}
+					named, ok := types.Unalias(pkg.TypesInfo.TypeOf(sw.Tag)).(*types.Named)
+					if !ok || !enumTypes[named.Obj()] {
+						return true
+					}
+
+					handled, hasDefault := switchCaseValues(pkg, sw)
+					info := &EnumSwitchInfo{
+						FuncName:   funcName,
+						EnumName:   named.Obj().Pkg().Path() + "." + named.Obj().Name(),
+						HasDefault: hasDefault,
+					}
+					for _, m := range members[named.Obj()] {
+						if !handled[m.value] {
+							info.Missing = append(info.Missing, m.shortName)
+						}
+					}
+					pos := pkg.Fset.Position(sw.Pos())
+					info.File = a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename)
+					info.Line = pos.Line
+					results = append(results, info)
+					return true
+				})
+			}
+		}
+	}
+
+	return results
+}
+
+// switchCaseValues returns the constant values handled by a switch's case clauses
+func switchCaseValues(pkg *packages.Package, sw *ast.SwitchStmt) (handled map[string]bool, hasDefault bool) {
+	handled = make(map[string]bool)
+	for _, stmt := range sw.Body.List {
+		clause, ok := stmt.(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		if clause.List == nil {
+			hasDefault = true
+			continue
+		}
+		for _, expr := range clause.List {
+			if tv, ok := pkg.TypesInfo.Types[expr]; ok && tv.Value != nil {
+				handled[tv.Value.ExactString()] = true
+			}
+		}
+	}
+	return handled, hasDefault
+}
+
+// getTypeDoc extracts the doc comment for a type declaration
+func (a *VarConstAnalyzer) getTypeDoc(pkg *packages.Package, name string) string {
+	for _, astFile := range pkg.Syntax {
+		for _, decl := range astFile.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok || typeSpec.Name.Name != name {
+					continue
+				}
+				if typeSpec.Doc != nil {
+					return strings.TrimSpace(typeSpec.Doc.Text())
+				}
+				if genDecl.Doc != nil {
+					return strings.TrimSpace(genDecl.Doc.Text())
+				}
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// BuildEnumGraph inserts enum nodes, their member edges, and switch records.
+// Must be called after BuildVarConstGraph so that member const nodes exist.
+func (a *VarConstAnalyzer) BuildEnumGraph(
+	insertNodeFn func(*graph.Node) (int64, error),
+	insertEdgeFn func(*graph.Edge) error,
+	insertSwitchFn func(*graph.EnumSwitch) error,
+	funcNodeMap map[string]int64,
+) (enumCount, switchCount, incompleteCount int, err error) {
+	enumIDs := make(map[string]int64)
+
+	for _, enum := range a.AnalyzeEnums() {
+		node := &graph.Node{
+			Kind:      graph.NodeKindEnum,
+			Name:      enum.Name,
+			Package:   enum.Package,
+			File:      enum.File,
+			Line:      enum.Line,
+			Signature: enum.Underlying,
+			Doc:       enum.Doc,
+		}
+		a.modules.Apply(node)
+		enumID, err := insertNodeFn(node)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		enumIDs[enum.Name] = enumID
+		enumCount++
+
+		for _, m := range enum.Members {
+			constID, ok := a.vcNodeIDs[m.Name]
+			if !ok {
+				continue
+			}
+			if err := insertEdgeFn(&graph.Edge{
+				FromID: enumID,
+				ToID:   constID,
+				Kind:   graph.EdgeKindHasMember,
+			}); err != nil {
+				return 0, 0, 0, err
+			}
+		}
+	}
+
+	for _, sw := range a.FindEnumSwitches() {
+		enumID, enumOK := enumIDs[sw.EnumName]
+		funcID, funcOK := funcNodeMap[sw.FuncName]
+		if !enumOK || !funcOK {
+			continue
+		}
+		if err := insertSwitchFn(&graph.EnumSwitch{
+			EnumID:     enumID,
+			FuncID:     funcID,
+			File:       sw.File,
+			Line:       sw.Line,
+			Missing:    sw.Missing,
+			HasDefault: sw.HasDefault,
+		}); err != nil {
+			return 0, 0, 0, err
+		}
+		switchCount++
+		if len(sw.Missing) > 0 {
+			incompleteCount++
+		}
+	}
+
+	return enumCount, switchCount, incompleteCount, nil
+}
@@ -0,0 +1,181 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zheng/crag/internal/graph"
+	"golang.org/x/tools/go/packages"
+)
+
+// ScanSourceFiles lists the Go source files (excluding tests) under projectPath with
+// their content hashes. Files whose size and mtime match the previous record reuse
+// its hash and package instead of being read again. Hidden, vendor and testdata
+// directories and nested modules are skipped, like ./... does.
+func ScanSourceFiles(projectPath string, previous []*graph.SourceFile) ([]*graph.SourceFile, error) {
+	root, err := filepath.Abs(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]*graph.SourceFile, len(previous))
+	for _, f := range previous {
+		known[f.Path] = f
+	}
+
+	var files []*graph.SourceFile
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		f := &graph.SourceFile{
+			Path:    filepath.ToSlash(rel),
+			ModTime: info.ModTime().UnixNano(),
+			Size:    info.Size(),
+		}
+		if old, ok := known[f.Path]; ok && old.ModTime == f.ModTime && old.Size == f.Size {
+			f.Hash, f.Package = old.Hash, old.Package
+		} else if f.Hash, err = hashFile(path); err != nil {
+			return err
+		}
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SetFilePackages records which loaded package each file belongs to.
+// Files that no package compiled (e.g. excluded by build tags) get an empty package.
+func SetFilePackages(files []*graph.SourceFile, projectPath string, pkgs []*packages.Package) {
+	root, _ := filepath.Abs(projectPath)
+	owner := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, goFile := range pkg.GoFiles {
+			if rel, err := filepath.Rel(root, goFile); err == nil {
+				owner[filepath.ToSlash(rel)] = pkg.PkgPath
+			}
+		}
+	}
+	for _, f := range files {
+		f.Package = owner[f.Path]
+	}
+}
+
+// ChangedSourceFiles returns the paths of files that were added, removed or whose
+// content hash differs between previous and current, sorted
+func ChangedSourceFiles(previous, current []*graph.SourceFile) []string {
+	old := make(map[string]string, len(previous))
+	for _, f := range previous {
+		old[f.Path] = f.Hash
+	}
+
+	var changed []string
+	for _, f := range current {
+		if hash, ok := old[f.Path]; !ok || hash != f.Hash {
+			changed = append(changed, f.Path)
+		}
+		delete(old, f.Path)
+	}
+	for path := range old {
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// ChangedPackages maps changed files to their packages. Current files take the
+// package they were loaded into; removed files take the package recorded before.
+func ChangedPackages(changed []string, previous, current []*graph.SourceFile) []string {
+	pkgOf := make(map[string]string)
+	for _, f := range previous {
+		pkgOf[f.Path] = f.Package
+	}
+	for _, f := range current {
+		pkgOf[f.Path] = f.Package
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, path := range changed {
+		if pkg := pkgOf[path]; pkg != "" && !seen[pkg] {
+			seen[pkg] = true
+			result = append(result, pkg)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ReverseDependents returns the given packages plus every loaded package that
+// imports one of them, directly or transitively, sorted
+func ReverseDependents(pkgs []*packages.Package, changed []string) []string {
+	importers := make(map[string][]string)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for path := range pkg.Imports {
+			importers[path] = append(importers[path], pkg.PkgPath)
+		}
+	})
+
+	seen := make(map[string]bool)
+	queue := append([]string(nil), changed...)
+	for _, p := range changed {
+		seen[p] = true
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, importer := range importers[cur] {
+			if !seen[importer] {
+				seen[importer] = true
+				queue = append(queue, importer)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(seen))
+	for p := range seen {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -119,3 +120,114 @@ func GetRemoteTrackingBranch(projectPath string) (string, error) {
 	return branch, nil
 }
 
+
+// CreateWorktree checks out ref into a temporary, detached git worktree.
+// projectPath may be a subdirectory of the repository; the returned path points at
+// the same subdirectory inside the worktree. The cleanup function removes the worktree.
+func CreateWorktree(projectPath, ref string) (string, func(), error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = projectPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("不是 git 仓库: %w", err)
+	}
+	repoRoot, err := filepath.EvalSymlinks(strings.TrimSpace(string(output)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	absProject, err := filepath.Abs(projectPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(absProject); err == nil {
+		absProject = resolved
+	}
+	relPath, err := filepath.Rel(repoRoot, absProject)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "crag-worktree-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cmd = exec.Command("git", "worktree", "add", "--detach", tmpDir, ref)
+	cmd.Dir = repoRoot
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", nil, fmt.Errorf("创建 worktree 失败 (%s): %s", ref, strings.TrimSpace(string(out)))
+	}
+
+	cleanup := func() {
+		cmd := exec.Command("git", "worktree", "remove", "--force", tmpDir)
+		cmd.Dir = repoRoot
+		_ = cmd.Run()
+		os.RemoveAll(tmpDir)
+	}
+
+	return filepath.Join(tmpDir, relPath), cleanup, nil
+}
+
+// GetGitHead returns the HEAD commit of the repository containing projectPath and
+// whether the working tree under projectPath has uncommitted changes
+func GetGitHead(projectPath string) (commit string, dirty bool, err error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = projectPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", false, fmt.Errorf("无法获取 git HEAD: %w", err)
+	}
+	commit = strings.TrimSpace(string(output))
+
+	cmd = exec.Command("git", "status", "--porcelain", "--", ".")
+	cmd.Dir = projectPath
+	output, err = cmd.Output()
+	if err != nil {
+		return commit, false, fmt.Errorf("无法获取 git 状态: %w", err)
+	}
+	return commit, len(bytes.TrimSpace(output)) > 0, nil
+}
+
+// ResolveGitRef returns the commit a ref (branch, tag, commit) points to,
+// or an error if ref is not a valid git ref in the repository
+func ResolveGitRef(projectPath, ref string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	cmd.Dir = projectPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("无效的 git ref: %s", ref)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GitDiff returns the unified diff of the Go files under projectPath between base
+// (HEAD if empty) and the working tree, without context lines and with paths
+// relative to projectPath
+func GitDiff(projectPath, base string) ([]byte, error) {
+	if base == "" {
+		base = "HEAD"
+	}
+	cmd := exec.Command("git", "diff", "--no-color", "--no-ext-diff", "-U0", "--relative", base, "--", "*.go")
+	cmd.Dir = projectPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s 失败: %s", base, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// GitPrefix returns the path of projectPath relative to the root of its
+// repository, with a trailing slash ("" at the root)
+func GitPrefix(projectPath string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-prefix")
+	cmd.Dir = projectPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
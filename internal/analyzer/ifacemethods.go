@@ -0,0 +1,216 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// DispatchInfo represents a call (or method value) through an interface method
+type DispatchInfo struct {
+	FuncName   string // Calling function
+	MethodName string // Interface method node name: (pkg.Iface).Method
+	File       string // Call site file
+	Line       int    // Call site line
+}
+
+// interfaceMethodName builds the node name of an interface method, e.g. "(pkg.Storage).Save".
+// The builtin error interface yields "(error).Error".
+func interfaceMethodName(ifaceName, method string) string {
+	return "(" + ifaceName + ")." + method
+}
+
+// BuildInterfaceMethodGraph creates a node per interface method, links each concrete
+// method that satisfies it with an implements edge, and adds calls edges from functions
+// that dispatch through the interface method.
+// Must be called after BuildInterfaceGraph; funcNodeMap maps SSA function names to node IDs.
+func (a *InterfaceAnalyzer) BuildInterfaceMethodGraph(
+	insertNodeFn func(*graph.Node) (int64, error),
+	insertEdgeFn func(*graph.Edge) error,
+	funcNodeMap map[string]int64,
+) (methodCount, implCount, dispatchCount int, err error) {
+	methodIDs := make(map[string]int64)
+
+	// Interface method nodes (declared methods only; embedded ones belong to their own interface)
+	for _, iface := range a.interfaces {
+		if iface.typ == nil {
+			continue
+		}
+		for i := 0; i < iface.typ.NumExplicitMethods(); i++ {
+			m := iface.typ.ExplicitMethod(i)
+			name := interfaceMethodName(iface.Name, m.Name())
+			if _, ok := methodIDs[name]; ok {
+				continue
+			}
+
+			node := &graph.Node{
+				Kind:      graph.NodeKindFunc,
+				Name:      name,
+				Package:   iface.Package,
+				Signature: m.Type().String(),
+			}
+			if m.Pos().IsValid() && len(a.pkgs) > 0 {
+				pos := a.pkgs[0].Fset.Position(m.Pos())
+				node.File = a.sourceFile(iface.Package, pos.Filename)
+				node.Line = pos.Line
+			}
+			a.modules.Apply(node)
+			id, err := insertNodeFn(node)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			methodIDs[name] = id
+			methodCount++
+		}
+	}
+
+	// Concrete method -> interface method
+	implSet := make(map[[2]int64]bool)
+	for _, impl := range a.impls {
+		named := a.findNamedType(impl.Type.Name)
+		if named == nil || impl.Interface.typ == nil {
+			continue
+		}
+		var recv types.Type = named
+		if impl.IsPointer {
+			recv = types.NewPointer(named)
+		}
+
+		for i := 0; i < impl.Interface.typ.NumMethods(); i++ {
+			m := impl.Interface.typ.Method(i)
+			ifaceID, ok := methodIDs[interfaceMethodKey(m)]
+			if !ok {
+				continue
+			}
+			obj, _, _ := types.LookupFieldOrMethod(recv, true, m.Pkg(), m.Name())
+			fn, ok := obj.(*types.Func)
+			if !ok || fn.Pkg() == nil {
+				continue
+			}
+			concreteID, ok := funcNodeMap[typesFuncName(fn)]
+			if !ok || implSet[[2]int64{concreteID, ifaceID}] {
+				continue
+			}
+			implSet[[2]int64{concreteID, ifaceID}] = true
+
+			if err := insertEdgeFn(&graph.Edge{
+				FromID: concreteID,
+				ToID:   ifaceID,
+				Kind:   graph.EdgeKindImplements,
+			}); err != nil {
+				return 0, 0, 0, err
+			}
+			implCount++
+		}
+	}
+
+	// Callers dispatching through interface methods
+	for _, d := range a.FindDispatches() {
+		callerID, callerOK := funcNodeMap[d.FuncName]
+		methodID, methodOK := methodIDs[d.MethodName]
+		if !callerOK || !methodOK {
+			continue
+		}
+		if err := insertEdgeFn(&graph.Edge{
+			FromID:       callerID,
+			ToID:         methodID,
+			Kind:         graph.EdgeKindCalls,
+			CallSiteFile: d.File,
+			CallSiteLine: d.Line,
+		}); err != nil {
+			return 0, 0, 0, err
+		}
+		dispatchCount++
+	}
+
+	return methodCount, implCount, dispatchCount, nil
+}
+
+// interfaceMethodKey returns the interface method node name for a method object
+// obtained from an interface type (the method's receiver is its declaring interface)
+func interfaceMethodKey(m *types.Func) string {
+	sig, ok := m.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return ""
+	}
+	named, ok := types.Unalias(sig.Recv().Type()).(*types.Named)
+	if !ok {
+		return ""
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil {
+		return interfaceMethodName(obj.Name(), m.Name()) // builtin error
+	}
+	return interfaceMethodName(obj.Pkg().Path()+"."+obj.Name(), m.Name())
+}
+
+// FindDispatches finds calls and method values that go through an interface method.
+// Closures are attributed to their enclosing function.
+func (a *InterfaceAnalyzer) FindDispatches() []*DispatchInfo {
+	var results []*DispatchInfo
+	seen := make(map[string]bool) // dedup: "funcName->methodName"
+
+	for _, pkg := range a.pkgs {
+		if pkg.TypesInfo == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+		for _, astFile := range pkg.Syntax {
+			for _, decl := range astFile.Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Body == nil {
+					continue
+				}
+				funcName := funcFullName(pkg, funcDecl)
+				a.walkDispatches(pkg, funcDecl.Body, funcName, seen, &results)
+			}
+		}
+	}
+
+	return results
+}
+
+// walkDispatches walks a function body looking for interface method selections
+func (a *InterfaceAnalyzer) walkDispatches(
+	pkg *packages.Package,
+	body *ast.BlockStmt,
+	funcName string,
+	seen map[string]bool,
+	results *[]*DispatchInfo,
+) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		selection := pkg.TypesInfo.Selections[sel]
+		if selection == nil || selection.Kind() != types.MethodVal || !types.IsInterface(selection.Recv()) {
+			return true
+		}
+		fn, ok := selection.Obj().(*types.Func)
+		if !ok {
+			return true
+		}
+		methodName := interfaceMethodKey(fn)
+		if methodName == "" {
+			return true
+		}
+
+		key := funcName + "->" + methodName
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+
+		pos := pkg.Fset.Position(sel.Sel.Pos())
+		*results = append(*results, &DispatchInfo{
+			FuncName:   funcName,
+			MethodName: methodName,
+			File:       a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename),
+			Line:       pos.Line,
+		})
+		return true
+	})
+}
@@ -3,6 +3,7 @@ package analyzer
 import (
 	"go/types"
 	"path/filepath"
+	"strings"
 
 	"golang.org/x/tools/go/packages"
 
@@ -17,6 +18,8 @@ type InterfaceInfo struct {
 	Line       int      // Line number
 	Methods    []string // Method signatures
 	MethodsStr string   // Methods as string for display
+
+	typ *types.Interface // resolved interface type
 }
 
 // TypeInfo represents a named type (struct, etc.)
@@ -34,11 +37,34 @@ type Implementation struct {
 	IsPointer bool // Whether *T implements I (vs T implements I)
 }
 
+// DefaultExternalInterfaces is the default set of stdlib interfaces checked
+// against project types. Names are "importpath.Name"; "error" is the builtin.
+var DefaultExternalInterfaces = []string{
+	"error",
+	"fmt.Stringer",
+	"io.Reader",
+	"io.Writer",
+	"io.Closer",
+	"net/http.Handler",
+	"database/sql.Scanner",
+	"database/sql/driver.Valuer",
+	"encoding/json.Marshaler",
+	"encoding/json.Unmarshaler",
+}
+
 // InterfaceAnalyzer analyzes interface implementations
 type InterfaceAnalyzer struct {
-	pkgs        []*packages.Package
-	projectRoot string
-	projectPkgs map[string]bool
+	pkgs           []*packages.Package
+	projectRoot    string
+	projectPkgs    map[string]bool
+	modules        graph.ModuleIndex
+	externalIfaces []string         // external (stdlib/dependency) interfaces to check
+	typeIDs        map[string]int64 // type name -> node ID, filled by BuildInterfaceGraph
+	interfaceIDs   map[string]int64 // interface name -> node ID, filled by BuildInterfaceGraph
+
+	// Filled by BuildInterfaceGraph for BuildInterfaceMethodGraph
+	interfaces []*InterfaceInfo
+	impls      []*Implementation
 }
 
 // NewInterfaceAnalyzer creates a new interface analyzer
@@ -53,12 +79,21 @@ func NewInterfaceAnalyzer(pkgs []*packages.Package, projectRoot string) *Interfa
 	absRoot, _ := filepath.Abs(projectRoot)
 
 	return &InterfaceAnalyzer{
-		pkgs:        pkgs,
-		projectRoot: absRoot,
-		projectPkgs: projectPkgs,
+		pkgs:           pkgs,
+		projectRoot:    absRoot,
+		projectPkgs:    projectPkgs,
+		modules:        graph.NewModuleIndex(pkgs),
+		externalIfaces: DefaultExternalInterfaces,
 	}
 }
 
+// SetExternalInterfaces sets the external interfaces (e.g. "io.Reader",
+// "github.com/foo/bar.Store") checked against project types.
+// Interfaces from packages the project does not import are ignored.
+func (a *InterfaceAnalyzer) SetExternalInterfaces(names []string) {
+	a.externalIfaces = names
+}
+
 // Analyze extracts interfaces, types, and their implementation relationships
 func (a *InterfaceAnalyzer) Analyze() (interfaces []*InterfaceInfo, typInfos []*TypeInfo, impls []*Implementation) {
 	// Collect all interfaces and types from the project
@@ -81,12 +116,7 @@ func (a *InterfaceAnalyzer) Analyze() (interfaces []*InterfaceInfo, typInfos []*
 
 			// Get position info
 			pos := pkg.Fset.Position(obj.Pos())
-			file := pos.Filename
-			if a.projectRoot != "" && file != "" {
-				if rel, err := filepath.Rel(a.projectRoot, file); err == nil {
-					file = rel
-				}
-			}
+			file := a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename)
 
 			typeName, ok := obj.(*types.TypeName)
 			if !ok {
@@ -102,11 +132,7 @@ func (a *InterfaceAnalyzer) Analyze() (interfaces []*InterfaceInfo, typInfos []*
 
 			// Check if it's an interface
 			if iface, ok := underlying.(*types.Interface); ok {
-				methods := make([]string, iface.NumMethods())
-				for i := 0; i < iface.NumMethods(); i++ {
-					m := iface.Method(i)
-					methods[i] = m.Name() + m.Type().(*types.Signature).String()[4:] // Remove "func" prefix
-				}
+				methods := interfaceMethods(iface)
 
 				interfaces = append(interfaces, &InterfaceInfo{
 					Name:       pkg.PkgPath + "." + name,
@@ -115,6 +141,7 @@ func (a *InterfaceAnalyzer) Analyze() (interfaces []*InterfaceInfo, typInfos []*
 					Line:       pos.Line,
 					Methods:    methods,
 					MethodsStr: formatMethods(methods),
+					typ:        iface,
 				})
 			} else {
 				// It's a named type (struct, etc.)
@@ -134,32 +161,161 @@ func (a *InterfaceAnalyzer) Analyze() (interfaces []*InterfaceInfo, typInfos []*
 		if ifaceType == nil {
 			continue
 		}
+		impls = append(impls, a.findImplementations(iface, ifaceType, typInfos)...)
+	}
 
-		for _, typ := range typInfos {
-			namedType := a.findNamedType(typ.Name)
-			if namedType == nil {
-				continue
-			}
+	return
+}
 
-			// Check if T implements I
-			if types.Implements(namedType, ifaceType) {
-				impls = append(impls, &Implementation{
-					Type:      typ,
-					Interface: iface,
-					IsPointer: false,
-				})
-			} else if types.Implements(types.NewPointer(namedType), ifaceType) {
-				// Check if *T implements I
-				impls = append(impls, &Implementation{
-					Type:      typ,
-					Interface: iface,
-					IsPointer: true,
-				})
-			}
+// AnalyzeExternal checks the configured external interfaces against project types.
+// Only external interfaces with at least one implementation are returned.
+func (a *InterfaceAnalyzer) AnalyzeExternal(typInfos []*TypeInfo) (interfaces []*InterfaceInfo, impls []*Implementation) {
+	if len(a.externalIfaces) == 0 {
+		return nil, nil
+	}
+
+	// All packages reachable from the project, by import path
+	imported := make(map[string]*packages.Package)
+	packages.Visit(a.pkgs, nil, func(pkg *packages.Package) {
+		imported[pkg.PkgPath] = pkg
+	})
+	depModules := graph.NewModuleIndex(packagesOf(imported))
+
+	seen := make(map[string]bool)
+	for _, name := range a.externalIfaces {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		iface, ifaceType := a.resolveExternalInterface(name, imported, depModules)
+		if iface == nil {
+			continue
+		}
+		found := a.findImplementations(iface, ifaceType, typInfos)
+		if len(found) == 0 {
+			continue
 		}
+		interfaces = append(interfaces, iface)
+		impls = append(impls, found...)
 	}
+	return interfaces, impls
+}
 
-	return
+// resolveExternalInterface looks up an external interface by "importpath.Name"
+func (a *InterfaceAnalyzer) resolveExternalInterface(
+	name string,
+	imported map[string]*packages.Package,
+	depModules graph.ModuleIndex,
+) (*InterfaceInfo, *types.Interface) {
+	if obj := types.Universe.Lookup(name); obj != nil {
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok {
+			return nil, nil
+		}
+		methods := interfaceMethods(iface)
+		return &InterfaceInfo{
+			Name:       name,
+			Methods:    methods,
+			MethodsStr: formatMethods(methods),
+			typ:        iface,
+		}, iface
+	}
+
+	idx := strings.LastIndex(name, ".")
+	if idx <= 0 {
+		return nil, nil
+	}
+	pkgPath, typeName := name[:idx], name[idx+1:]
+	// Project interfaces are already handled by Analyze
+	if a.projectPkgs[pkgPath] {
+		return nil, nil
+	}
+	pkg, ok := imported[pkgPath]
+	if !ok || pkg.Types == nil {
+		return nil, nil
+	}
+	obj, ok := pkg.Types.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, nil
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, nil
+	}
+
+	if mod := depModules.Lookup(pkgPath); mod != nil {
+		a.modules[pkgPath] = mod // so that the interface node gets its module fields
+	}
+
+	methods := interfaceMethods(iface)
+	pos := pkg.Fset.Position(obj.Pos())
+	return &InterfaceInfo{
+		Name:       name,
+		Package:    pkgPath,
+		File:       a.sourceFile(pkgPath, pos.Filename),
+		Line:       pos.Line,
+		Methods:    methods,
+		MethodsStr: formatMethods(methods),
+		typ:        iface,
+	}, iface
+}
+
+// sourceFile converts a source file path into the path stored in the database.
+// Stdlib files are stored as "importpath/file.go" (e.g. "io/io.go").
+func (a *InterfaceAnalyzer) sourceFile(pkgPath, filename string) string {
+	if a.projectPkgs[pkgPath] || a.modules.Lookup(pkgPath) != nil {
+		return a.modules.RelPath(a.projectRoot, pkgPath, filename)
+	}
+	return pkgPath + "/" + filepath.Base(filename)
+}
+
+// findImplementations returns the types (or pointers to them) that implement an interface
+func (a *InterfaceAnalyzer) findImplementations(iface *InterfaceInfo, ifaceType *types.Interface, typInfos []*TypeInfo) (impls []*Implementation) {
+	for _, typ := range typInfos {
+		namedType := a.findNamedType(typ.Name)
+		if namedType == nil {
+			continue
+		}
+
+		// Check if T implements I
+		if types.Implements(namedType, ifaceType) {
+			impls = append(impls, &Implementation{
+				Type:      typ,
+				Interface: iface,
+				IsPointer: false,
+			})
+		} else if types.Implements(types.NewPointer(namedType), ifaceType) {
+			// Check if *T implements I
+			impls = append(impls, &Implementation{
+				Type:      typ,
+				Interface: iface,
+				IsPointer: true,
+			})
+		}
+	}
+
+	return impls
+}
+
+// interfaceMethods returns the method signatures of an interface
+func interfaceMethods(iface *types.Interface) []string {
+	methods := make([]string, iface.NumMethods())
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		methods[i] = m.Name() + m.Type().(*types.Signature).String()[4:] // Remove "func" prefix
+	}
+	return methods
+}
+
+// packagesOf returns the values of a package map as a slice
+func packagesOf(m map[string]*packages.Package) []*packages.Package {
+	result := make([]*packages.Package, 0, len(m))
+	for _, pkg := range m {
+		result = append(result, pkg)
+	}
+	return result
 }
 
 // findInterface finds an interface type by full name
@@ -237,10 +393,16 @@ func (a *InterfaceAnalyzer) BuildInterfaceGraph(
 	insertEdgeFn func(*graph.Edge) error,
 ) (interfaceCount, typeCount, implCount int, err error) {
 	interfaces, typInfos, impls := a.Analyze()
+	extInterfaces, extImpls := a.AnalyzeExternal(typInfos)
+	interfaces = append(interfaces, extInterfaces...)
+	impls = append(impls, extImpls...)
+	a.interfaces, a.impls = interfaces, impls
 
 	// Maps for tracking node IDs
 	interfaceIDs := make(map[string]int64)
 	typeIDs := make(map[string]int64)
+	a.typeIDs = typeIDs
+	a.interfaceIDs = interfaceIDs
 
 	// Insert interfaces as nodes
 	for _, iface := range interfaces {
@@ -252,6 +414,7 @@ func (a *InterfaceAnalyzer) BuildInterfaceGraph(
 			Line:      iface.Line,
 			Signature: iface.MethodsStr,
 		}
+		a.modules.Apply(node)
 		id, err := insertNodeFn(node)
 		if err != nil {
 			return 0, 0, 0, err
@@ -269,6 +432,7 @@ func (a *InterfaceAnalyzer) BuildInterfaceGraph(
 			File:    typ.File,
 			Line:    typ.Line,
 		}
+		a.modules.Apply(node)
 		id, err := insertNodeFn(node)
 		if err != nil {
 			return 0, 0, 0, err
@@ -298,3 +462,23 @@ func (a *InterfaceAnalyzer) BuildInterfaceGraph(
 
 	return interfaceCount, typeCount, implCount, nil
 }
+
+// GetTypeNodeMap returns a copy of the type name -> node ID mapping.
+// Used by other analyzers that need to create edges to existing type nodes.
+func (a *InterfaceAnalyzer) GetTypeNodeMap() map[string]int64 {
+	result := make(map[string]int64, len(a.typeIDs))
+	for k, v := range a.typeIDs {
+		result[k] = v
+	}
+	return result
+}
+
+// GetNamedTypeNodeMap returns the type and interface name -> node ID mapping.
+// Used for edges that may point at either kind, such as type assertions.
+func (a *InterfaceAnalyzer) GetNamedTypeNodeMap() map[string]int64 {
+	result := a.GetTypeNodeMap()
+	for k, v := range a.interfaceIDs {
+		result[k] = v
+	}
+	return result
+}
@@ -6,8 +6,10 @@ import (
 	"golang.org/x/tools/go/packages"
 )
 
-// LoadPackages loads all Go packages from the given project path
-func LoadPackages(projectPath string) ([]*packages.Package, error) {
+// LoadPackages loads all Go packages from the given project path.
+// includeModules are extra package patterns (e.g. "github.com/ourorg/...") of
+// dependency modules that should be analyzed as part of the project.
+func LoadPackages(projectPath string, includeModules ...string) ([]*packages.Package, error) {
 	cfg := &packages.Config{
 		Mode: packages.NeedName |
 			packages.NeedFiles |
@@ -15,11 +17,13 @@ func LoadPackages(projectPath string) ([]*packages.Package, error) {
 			packages.NeedTypes |
 			packages.NeedTypesInfo |
 			packages.NeedDeps |
-			packages.NeedImports,
+			packages.NeedImports |
+			packages.NeedModule,
 		Dir: projectPath,
 	}
 
-	pkgs, err := packages.Load(cfg, "./...")
+	patterns := append([]string{"./..."}, includeModules...)
+	pkgs, err := packages.Load(cfg, patterns...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load packages: %w", err)
 	}
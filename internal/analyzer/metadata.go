@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/version"
+)
+
+// CollectMetadata records the environment of an analysis run. The caller fills in
+// Incremental, DurationMs and AnalyzedAt once the graph has been written.
+func CollectMetadata(projectPath string, pkgs []*packages.Package, flags []string) *graph.Metadata {
+	meta := &graph.Metadata{
+		ProjectRoot:  projectPath,
+		GoVersion:    goVersion(projectPath),
+		CragVersion:  version.String(),
+		Algorithm:    CallGraphAlgorithm,
+		Flags:        strings.Join(flags, " "),
+		PackageCount: len(pkgs),
+	}
+	if abs, err := filepath.Abs(projectPath); err == nil {
+		meta.ProjectRoot = abs
+	}
+	for _, pkg := range pkgs {
+		if pkg.Module != nil && pkg.Module.Main {
+			meta.ModulePath = pkg.Module.Path
+			break
+		}
+	}
+	if commit, dirty, err := GetGitHead(projectPath); err == nil {
+		meta.GitCommit = commit
+		meta.GitDirty = dirty
+	}
+	return meta
+}
+
+// AnalysisFlags formats the options that change analysis results, omitting defaults
+func AnalysisFlags(includeModules, externalIfaces []string) []string {
+	var flags []string
+	if len(includeModules) > 0 {
+		flags = append(flags, "--include-module="+strings.Join(includeModules, ","))
+	}
+	if externalIfaces != nil && !slices.Equal(externalIfaces, DefaultExternalInterfaces) {
+		flags = append(flags, "--external-iface="+strings.Join(externalIfaces, ","))
+	}
+	return flags
+}
+
+// CheckStale compares the recorded metadata with the current state of the project
+// and returns a warning for each sign that the graph may be out of date
+func CheckStale(meta *graph.Metadata) []string {
+	var warnings []string
+
+	if meta.GitDirty {
+		warnings = append(warnings, "分析时工作区有未提交的变更")
+	}
+	// Snapshots are historical by design; only a live graph can fall behind HEAD
+	if meta.GitCommit != "" && meta.Snapshot == "" {
+		commit, dirty, err := GetGitHead(meta.ProjectRoot)
+		switch {
+		case err != nil:
+			// Project not available on this machine; nothing to compare against
+		case commit != meta.GitCommit:
+			warnings = append(warnings, fmt.Sprintf("当前 HEAD 为 %s，与分析时的 %s 不同", shortCommit(commit), shortCommit(meta.GitCommit)))
+		case dirty && !meta.GitDirty:
+			warnings = append(warnings, "当前工作区有未提交的变更")
+		}
+	}
+	if v := version.String(); meta.CragVersion != "" && meta.CragVersion != v {
+		warnings = append(warnings, fmt.Sprintf("由 crag %s 生成，当前为 %s", meta.CragVersion, v))
+	}
+	if !meta.AnalyzedAt.IsZero() && time.Since(meta.AnalyzedAt) > 7*24*time.Hour {
+		warnings = append(warnings, fmt.Sprintf("分析已超过 %d 天", int(time.Since(meta.AnalyzedAt).Hours()/24)))
+	}
+
+	return warnings
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// goVersion returns the Go toolchain version used to load the project
+func goVersion(projectPath string) string {
+	cmd := exec.Command("go", "env", "GOVERSION")
+	cmd.Dir = projectPath
+	if output, err := cmd.Output(); err == nil {
+		if v := strings.TrimSpace(string(output)); v != "" {
+			return v
+		}
+	}
+	return runtime.Version()
+}
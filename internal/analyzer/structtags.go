@@ -0,0 +1,532 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// StructFieldInfo represents a field of a named struct type
+type StructFieldInfo struct {
+	Name       string            // Full name: pkg.Struct.Field
+	StructName string            // Full struct name: pkg.Struct
+	Package    string            // Package path
+	File       string            // Source file (relative)
+	Line       int               // Line number
+	TypeStr    string            // Field type as string
+	RawTag     string            // Raw struct tag
+	Tags       []*graph.FieldTag // Parsed tag keys
+	Doc        string            // Field doc comment
+}
+
+// StructUsageInfo represents a function constructing or decoding a struct
+type StructUsageInfo struct {
+	FuncName   string         // Full function name
+	StructName string         // Full struct name
+	Kind       graph.EdgeKind // constructs or decodes
+	File       string         // Usage site file
+	Line       int            // Usage site line
+}
+
+// decodeFuncs are the functions known to fill their pointer arguments from a
+// wire format, by types.Func.FullName with the major version suffix of the
+// package path removed (see unversionedPath), so every major version of a
+// library matches one entry
+var decodeFuncs = map[string]bool{
+	"encoding/json.Unmarshal":                    true,
+	"(*encoding/json.Decoder).Decode":            true,
+	"encoding/xml.Unmarshal":                     true,
+	"(*encoding/xml.Decoder).Decode":             true,
+	"(*encoding/xml.Decoder).DecodeElement":      true,
+	"(*encoding/gob.Decoder).Decode":             true,
+	"(*database/sql.Row).Scan":                   true,
+	"(*database/sql.Rows).Scan":                  true,
+	"gopkg.in/yaml.Unmarshal":                    true,
+	"gopkg.in/yaml.UnmarshalStrict":              true,
+	"(*gopkg.in/yaml.Decoder).Decode":            true,
+	"sigs.k8s.io/yaml.Unmarshal":                 true,
+	"sigs.k8s.io/yaml.UnmarshalStrict":           true,
+	"github.com/BurntSushi/toml.Unmarshal":       true,
+	"github.com/BurntSushi/toml.Decode":          true,
+	"github.com/BurntSushi/toml.DecodeFile":      true,
+	"google.golang.org/protobuf/proto.Unmarshal": true,
+	"github.com/mitchellh/mapstructure.Decode":   true,
+	"(github.com/jackc/pgx.Row).Scan":            true,
+	"(github.com/jackc/pgx.Rows).Scan":           true,
+	"(*github.com/jmoiron/sqlx.Row).StructScan":  true,
+	"(*github.com/jmoiron/sqlx.Rows).StructScan": true,
+	"(*github.com/jmoiron/sqlx.DB).Get":          true,
+	"(*github.com/jmoiron/sqlx.DB).Select":       true,
+
+	// Request binding of web frameworks
+	"(*github.com/gin-gonic/gin.Context).Bind":               true,
+	"(*github.com/gin-gonic/gin.Context).BindJSON":           true,
+	"(*github.com/gin-gonic/gin.Context).BindXML":            true,
+	"(*github.com/gin-gonic/gin.Context).BindYAML":           true,
+	"(*github.com/gin-gonic/gin.Context).BindQuery":          true,
+	"(*github.com/gin-gonic/gin.Context).BindUri":            true,
+	"(*github.com/gin-gonic/gin.Context).BindHeader":         true,
+	"(*github.com/gin-gonic/gin.Context).BindWith":           true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBind":         true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindJSON":     true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindXML":      true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindYAML":     true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindQuery":    true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindUri":      true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindHeader":   true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindWith":     true,
+	"(*github.com/gin-gonic/gin.Context).ShouldBindBodyWith": true,
+	"(github.com/labstack/echo.Context).Bind":                true,
+	"(*github.com/gofiber/fiber.Ctx).BodyParser":             true,
+	"(*github.com/gofiber/fiber.Ctx).QueryParser":            true,
+	"(*github.com/gofiber/fiber.Ctx).ParamsParser":           true,
+	"(*github.com/gofiber/fiber.Ctx).ReqHeaderParser":        true,
+}
+
+// StructTagAnalyzer extracts struct fields with their tags and finds the
+// functions that construct or decode those structs
+type StructTagAnalyzer struct {
+	pkgs        []*packages.Package
+	projectRoot string
+	projectPkgs map[string]bool
+	modules     graph.ModuleIndex
+}
+
+// NewStructTagAnalyzer creates a new struct tag analyzer
+func NewStructTagAnalyzer(pkgs []*packages.Package, projectRoot string) *StructTagAnalyzer {
+	projectPkgs := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if pkg.PkgPath != "" {
+			projectPkgs[pkg.PkgPath] = true
+		}
+	}
+
+	absRoot, _ := filepath.Abs(projectRoot)
+
+	return &StructTagAnalyzer{
+		pkgs:        pkgs,
+		projectRoot: absRoot,
+		projectPkgs: projectPkgs,
+		modules:     graph.NewModuleIndex(pkgs),
+	}
+}
+
+// Analyze collects the fields of all named struct types in the project
+func (a *StructTagAnalyzer) Analyze() []*StructFieldInfo {
+	var results []*StructFieldInfo
+
+	for _, pkg := range a.pkgs {
+		if pkg.Types == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+
+		fieldDocs := a.collectFieldDocs(pkg)
+
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || typeName.IsAlias() {
+				continue
+			}
+			st, ok := typeName.Type().Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+
+			structName := pkg.PkgPath + "." + name
+			for i := 0; i < st.NumFields(); i++ {
+				field := st.Field(i)
+				rawTag := st.Tag(i)
+
+				pos := pkg.Fset.Position(field.Pos())
+				info := &StructFieldInfo{
+					Name:       structName + "." + field.Name(),
+					StructName: structName,
+					Package:    pkg.PkgPath,
+					File:       a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename),
+					Line:       pos.Line,
+					TypeStr:    field.Type().String(),
+					RawTag:     rawTag,
+					Tags:       ParseStructTag(rawTag),
+					Doc:        fieldDocs[field.Pos()],
+				}
+				results = append(results, info)
+			}
+		}
+	}
+
+	return results
+}
+
+// collectFieldDocs maps field positions to their doc comments
+func (a *StructTagAnalyzer) collectFieldDocs(pkg *packages.Package) map[token.Pos]string {
+	docs := make(map[token.Pos]string)
+	for _, astFile := range pkg.Syntax {
+		ast.Inspect(astFile, func(n ast.Node) bool {
+			field, ok := n.(*ast.Field)
+			if !ok {
+				return true
+			}
+			doc := field.Doc
+			if doc == nil {
+				doc = field.Comment
+			}
+			if doc == nil {
+				return true
+			}
+			text := strings.TrimSpace(doc.Text())
+			if len(field.Names) == 0 {
+				// Embedded field: position is the type expression
+				docs[field.Type.Pos()] = text
+			}
+			for _, ident := range field.Names {
+				docs[ident.Pos()] = text
+			}
+			return true
+		})
+	}
+	return docs
+}
+
+// ParseStructTag splits a raw struct tag into its key:"name,options" parts.
+// It follows the conventions of reflect.StructTag.
+func ParseStructTag(tag string) []*graph.FieldTag {
+	var result []*graph.FieldTag
+	for tag != "" {
+		// Skip leading space
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		// Scan to colon
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		// Scan quoted string to find value
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		quoted := tag[:i+1]
+		tag = tag[i+1:]
+
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			break
+		}
+		name, options, _ := strings.Cut(value, ",")
+		result = append(result, &graph.FieldTag{
+			Key:     key,
+			Name:    name,
+			Options: options,
+		})
+	}
+	return result
+}
+
+// FindUsages finds functions that construct or decode the given structs
+func (a *StructTagAnalyzer) FindUsages() []*StructUsageInfo {
+	// Named struct types of the project -> full name
+	structObjs := make(map[*types.TypeName]string)
+	for _, pkg := range a.pkgs {
+		if pkg.Types == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || typeName.IsAlias() {
+				continue
+			}
+			if _, ok := typeName.Type().Underlying().(*types.Struct); ok {
+				structObjs[typeName] = pkg.PkgPath + "." + name
+			}
+		}
+	}
+
+	if len(structObjs) == 0 {
+		return nil
+	}
+
+	var usages []*StructUsageInfo
+	seen := make(map[string]bool) // dedup: "kind:funcName->structName"
+
+	for _, pkg := range a.pkgs {
+		if pkg.TypesInfo == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
+		}
+
+		for _, astFile := range pkg.Syntax {
+			for _, decl := range astFile.Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Body == nil {
+					continue
+				}
+				funcName := funcFullName(pkg, funcDecl)
+
+				record := func(kind graph.EdgeKind, structName string, pos token.Pos) {
+					key := string(kind) + ":" + funcName + "->" + structName
+					if seen[key] {
+						return
+					}
+					seen[key] = true
+					p := pkg.Fset.Position(pos)
+					usages = append(usages, &StructUsageInfo{
+						FuncName:   funcName,
+						StructName: structName,
+						Kind:       kind,
+						File:       a.modules.RelPath(a.projectRoot, pkg.PkgPath, p.Filename),
+						Line:       p.Line,
+					})
+				}
+
+				ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+					switch node := n.(type) {
+					case *ast.CompositeLit:
+						if name, ok := structObjs[namedStructOf(pkg.TypesInfo.TypeOf(node))]; ok {
+							record(graph.EdgeKindConstructs, name, node.Pos())
+						}
+					case *ast.CallExpr:
+						if isBuiltinNew(pkg, node) {
+							if name, ok := structObjs[namedStructOf(pkg.TypesInfo.TypeOf(node))]; ok {
+								record(graph.EdgeKindConstructs, name, node.Pos())
+							}
+							return true
+						}
+						if !isDecodeCall(pkg, node) {
+							return true
+						}
+						for _, arg := range node.Args {
+							// Decoders fill what they are given a pointer to
+							ptr, ok := types.Unalias(pkg.TypesInfo.TypeOf(arg)).(*types.Pointer)
+							if !ok {
+								continue
+							}
+							named, ok := types.Unalias(ptr.Elem()).(*types.Named)
+							if !ok {
+								continue
+							}
+							if name, ok := structObjs[named.Origin().Obj()]; ok {
+								record(graph.EdgeKindDecodes, name, arg.Pos())
+							}
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	return usages
+}
+
+// namedStructOf unwraps pointers, slices, arrays and maps down to a named type
+func namedStructOf(t types.Type) *types.TypeName {
+	for t != nil {
+		switch typ := types.Unalias(t).(type) {
+		case *types.Pointer:
+			t = typ.Elem()
+		case *types.Slice:
+			t = typ.Elem()
+		case *types.Array:
+			t = typ.Elem()
+		case *types.Map:
+			t = typ.Elem()
+		case *types.Named:
+			return typ.Origin().Obj()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isBuiltinNew checks whether a call is the builtin new(T)
+func isBuiltinNew(pkg *packages.Package, call *ast.CallExpr) bool {
+	ident, ok := call.Fun.(*ast.Ident)
+	if !ok || ident.Name != "new" {
+		return false
+	}
+	_, isBuiltin := pkg.TypesInfo.Uses[ident].(*types.Builtin)
+	return isBuiltin
+}
+
+// isDecodeCall checks whether a call is to one of the known decodeFuncs
+func isDecodeCall(pkg *packages.Package, call *ast.CallExpr) bool {
+	var ident *ast.Ident
+	switch fn := ast.Unparen(call.Fun).(type) {
+	case *ast.SelectorExpr:
+		ident = fn.Sel
+	case *ast.Ident:
+		ident = fn
+	default:
+		return false
+	}
+	fn, ok := pkg.TypesInfo.Uses[ident].(*types.Func)
+	if !ok {
+		return false
+	}
+	fn = fn.Origin()
+	name := fn.FullName()
+	if fn.Pkg() != nil {
+		path := fn.Pkg().Path()
+		name = strings.Replace(name, path, unversionedPath(path), 1)
+	}
+	return decodeFuncs[name]
+}
+
+// unversionedPath removes the major version element of a package path:
+// github.com/jackc/pgx/v5/pgxpool → github.com/jackc/pgx/pgxpool,
+// gopkg.in/yaml.v3 → gopkg.in/yaml
+func unversionedPath(path string) string {
+	elems := strings.Split(path, "/")
+	gopkgIn := elems[0] == "gopkg.in"
+	kept := elems[:0]
+	for i, elem := range elems {
+		if i > 0 && isMajorVersion(elem) {
+			continue
+		}
+		if gopkgIn {
+			if dot := strings.LastIndex(elem, ".v"); dot > 0 && isMajorVersion(elem[dot+1:]) {
+				elem = elem[:dot]
+			}
+		}
+		kept = append(kept, elem)
+	}
+	return strings.Join(kept, "/")
+}
+
+// isMajorVersion reports whether s is a module major version suffix, v2 or later
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' || s[1] == '0' {
+		return false
+	}
+	n, err := strconv.Atoi(s[1:])
+	return err == nil && n >= 2 && strings.TrimLeft(s[1:], "0123456789") == ""
+}
+
+// funcFullName builds the fully qualified function name as used by SSA,
+// e.g. "pkg.Func", "(pkg.T).Method" or "(*pkg.T).Method"
+func funcFullName(pkg *packages.Package, funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return pkg.PkgPath + "." + funcDecl.Name.Name
+	}
+
+	typExpr := funcDecl.Recv.List[0].Type
+	pointer := false
+	if star, ok := typExpr.(*ast.StarExpr); ok {
+		pointer = true
+		typExpr = star.X
+	}
+	// Unwrap generic receivers like T[K] or T[K, V]
+	switch idx := typExpr.(type) {
+	case *ast.IndexExpr:
+		typExpr = idx.X
+	case *ast.IndexListExpr:
+		typExpr = idx.X
+	}
+	ident, ok := typExpr.(*ast.Ident)
+	if !ok {
+		return pkg.PkgPath + "." + funcDecl.Name.Name
+	}
+	if pointer {
+		return "(*" + pkg.PkgPath + "." + ident.Name + ")." + funcDecl.Name.Name
+	}
+	return "(" + pkg.PkgPath + "." + ident.Name + ")." + funcDecl.Name.Name
+}
+
+// BuildStructTagGraph inserts field nodes, their tags, and struct usage edges
+func (a *StructTagAnalyzer) BuildStructTagGraph(
+	insertNodeFn func(*graph.Node) (int64, error),
+	insertEdgeFn func(*graph.Edge) error,
+	insertTagFn func(*graph.FieldTag) error,
+	structNodeMap map[string]int64,
+	funcNodeMap map[string]int64,
+) (fieldCount, tagCount, usageCount int, err error) {
+	fields := a.Analyze()
+
+	for _, f := range fields {
+		sig := f.TypeStr
+		if f.RawTag != "" {
+			sig += " `" + f.RawTag + "`"
+		}
+		node := &graph.Node{
+			Kind:      graph.NodeKindField,
+			Name:      f.Name,
+			Package:   f.Package,
+			File:      f.File,
+			Line:      f.Line,
+			Signature: sig,
+			Doc:       f.Doc,
+		}
+		a.modules.Apply(node)
+		fieldID, err := insertNodeFn(node)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		fieldCount++
+
+		if structID, ok := structNodeMap[f.StructName]; ok {
+			if err := insertEdgeFn(&graph.Edge{
+				FromID: structID,
+				ToID:   fieldID,
+				Kind:   graph.EdgeKindHasField,
+			}); err != nil {
+				return 0, 0, 0, err
+			}
+		}
+
+		for _, tag := range f.Tags {
+			tag.FieldID = fieldID
+			if err := insertTagFn(tag); err != nil {
+				return 0, 0, 0, err
+			}
+			tagCount++
+		}
+	}
+
+	for _, u := range a.FindUsages() {
+		funcID, funcOK := funcNodeMap[u.FuncName]
+		structID, structOK := structNodeMap[u.StructName]
+		if !funcOK || !structOK {
+			continue
+		}
+		if err := insertEdgeFn(&graph.Edge{
+			FromID:       funcID,
+			ToID:         structID,
+			Kind:         u.Kind,
+			CallSiteFile: u.File,
+			CallSiteLine: u.Line,
+		}); err != nil {
+			return 0, 0, 0, err
+		}
+		usageCount++
+	}
+
+	return fieldCount, tagCount, usageCount, nil
+}
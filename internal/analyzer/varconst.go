@@ -18,8 +18,10 @@ type VarConstInfo struct {
 	Package string         // Package path
 	File    string         // Source file (relative)
 	Line    int            // Line number
+	EndLine int            // Last line of the declaration
 	Kind    graph.NodeKind // var or const
 	TypeStr string         // Type as string
+	Value   string         // Constant value (const only)
 	Doc     string         // Documentation comment
 }
 
@@ -31,12 +33,24 @@ type ReferenceInfo struct {
 	Line         int    // Reference site line
 }
 
+// InitInfo represents a package-level var/const whose initializer depends on
+// another var/const or on a function (e.g. var X = computeY(), const B = A * 2)
+type InitInfo struct {
+	Name       string         // Full name of the initialized var/const
+	Dependency string         // Full name of the var/const or function it depends on
+	DepKind    graph.NodeKind // var, const or func
+	File       string         // Reference site file
+	Line       int            // Reference site line
+}
+
 // VarConstAnalyzer analyzes package-level variables and constants
 type VarConstAnalyzer struct {
 	pkgs        []*packages.Package
 	projectRoot string
 	projectPkgs map[string]bool
-	targetPkgs  map[string]bool // target packages for incremental mode (nil means all)
+	modules     graph.ModuleIndex
+	enumTypes   map[*types.TypeName]bool
+	vcNodeIDs   map[string]int64 // var/const name -> node ID, filled by BuildVarConstGraph
 }
 
 // NewVarConstAnalyzer creates a new var/const analyzer
@@ -54,30 +68,10 @@ func NewVarConstAnalyzer(pkgs []*packages.Package, projectRoot string) *VarConst
 		pkgs:        pkgs,
 		projectRoot: absRoot,
 		projectPkgs: projectPkgs,
+		modules:     graph.NewModuleIndex(pkgs),
 	}
 }
 
-// SetTargetPackages sets the target packages for incremental mode.
-// Only var/const in these packages will be inserted as nodes.
-func (a *VarConstAnalyzer) SetTargetPackages(pkgPaths []string) {
-	if len(pkgPaths) == 0 {
-		a.targetPkgs = nil
-		return
-	}
-	a.targetPkgs = make(map[string]bool)
-	for _, path := range pkgPaths {
-		a.targetPkgs[path] = true
-	}
-}
-
-// isTargetPackage checks if a package should have its var/const nodes inserted
-func (a *VarConstAnalyzer) isTargetPackage(pkgPath string) bool {
-	if a.targetPkgs == nil {
-		return true
-	}
-	return a.targetPkgs[pkgPath]
-}
-
 // Analyze collects all package-level vars and consts
 func (a *VarConstAnalyzer) Analyze() []*VarConstInfo {
 	var results []*VarConstInfo
@@ -89,10 +83,8 @@ func (a *VarConstAnalyzer) Analyze() []*VarConstInfo {
 		if !a.projectPkgs[pkg.PkgPath] {
 			continue
 		}
-		if !a.isTargetPackage(pkg.PkgPath) {
-			continue
-		}
 
+		endLines := specEndLines(pkg)
 		scope := pkg.Types.Scope()
 		for _, name := range scope.Names() {
 			obj := scope.Lookup(name)
@@ -100,18 +92,13 @@ func (a *VarConstAnalyzer) Analyze() []*VarConstInfo {
 				continue
 			}
 
-			// Skip unexported names
-			if !obj.Exported() {
+			// Skip unexported names (except enum members)
+			if !a.shouldInclude(obj) {
 				continue
 			}
 
 			pos := pkg.Fset.Position(obj.Pos())
-			file := pos.Filename
-			if a.projectRoot != "" && file != "" {
-				if rel, err := filepath.Rel(a.projectRoot, file); err == nil {
-					file = rel
-				}
-			}
+			file := a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename)
 
 			switch o := obj.(type) {
 			case *types.Var:
@@ -120,6 +107,7 @@ func (a *VarConstAnalyzer) Analyze() []*VarConstInfo {
 					Package: pkg.PkgPath,
 					File:    file,
 					Line:    pos.Line,
+					EndLine: endLines[obj.Pos()],
 					Kind:    graph.NodeKindVar,
 					TypeStr: o.Type().String(),
 					Doc:     a.getVarConstDoc(pkg, name),
@@ -130,8 +118,10 @@ func (a *VarConstAnalyzer) Analyze() []*VarConstInfo {
 					Package: pkg.PkgPath,
 					File:    file,
 					Line:    pos.Line,
+					EndLine: endLines[obj.Pos()],
 					Kind:    graph.NodeKindConst,
 					TypeStr: o.Type().String(),
+					Value:   o.Val().ExactString(),
 					Doc:     a.getVarConstDoc(pkg, name),
 				})
 			}
@@ -140,9 +130,8 @@ func (a *VarConstAnalyzer) Analyze() []*VarConstInfo {
 	return results
 }
 
-// FindReferences finds which functions reference each var/const
-func (a *VarConstAnalyzer) FindReferences(varConsts []*VarConstInfo) []*ReferenceInfo {
-	// Build a set of var/const objects for lookup
+// varConstObjects maps the var/const objects that get nodes to their full names
+func (a *VarConstAnalyzer) varConstObjects() map[types.Object]string {
 	varConstObjs := make(map[types.Object]string) // obj -> full name
 
 	for _, pkg := range a.pkgs {
@@ -152,7 +141,7 @@ func (a *VarConstAnalyzer) FindReferences(varConsts []*VarConstInfo) []*Referenc
 		scope := pkg.Types.Scope()
 		for _, name := range scope.Names() {
 			obj := scope.Lookup(name)
-			if obj == nil || !obj.Exported() {
+			if obj == nil || !a.shouldInclude(obj) {
 				continue
 			}
 			switch obj.(type) {
@@ -163,6 +152,12 @@ func (a *VarConstAnalyzer) FindReferences(varConsts []*VarConstInfo) []*Referenc
 		}
 	}
 
+	return varConstObjs
+}
+
+// FindReferences finds which functions reference each var/const
+func (a *VarConstAnalyzer) FindReferences(varConsts []*VarConstInfo) []*ReferenceInfo {
+	varConstObjs := a.varConstObjects()
 	if len(varConstObjs) == 0 {
 		return nil
 	}
@@ -181,7 +176,7 @@ func (a *VarConstAnalyzer) FindReferences(varConsts []*VarConstInfo) []*Referenc
 				if !ok || funcDecl.Body == nil {
 					continue
 				}
-				funcName := a.getFuncFullName(pkg, funcDecl)
+				funcName := funcFullName(pkg, funcDecl)
 				a.walkFuncBody(pkg, funcDecl.Body, funcName, varConstObjs, refSet, &refs)
 			}
 		}
@@ -190,7 +185,8 @@ func (a *VarConstAnalyzer) FindReferences(varConsts []*VarConstInfo) []*Referenc
 	return refs
 }
 
-// walkFuncBody walks a function body looking for var/const references
+// walkFuncBody walks a function body looking for var/const references.
+// Closures are part of the body, so their references are attributed to the enclosing function.
 func (a *VarConstAnalyzer) walkFuncBody(
 	pkg *packages.Package,
 	body *ast.BlockStmt,
@@ -223,12 +219,7 @@ func (a *VarConstAnalyzer) walkFuncBody(
 		refSet[key] = true
 
 		pos := pkg.Fset.Position(ident.Pos())
-		file := pos.Filename
-		if a.projectRoot != "" && file != "" {
-			if rel, err := filepath.Rel(a.projectRoot, file); err == nil {
-				file = rel
-			}
-		}
+		file := a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename)
 
 		*refs = append(*refs, &ReferenceInfo{
 			FuncName:     funcName,
@@ -241,20 +232,147 @@ func (a *VarConstAnalyzer) walkFuncBody(
 	})
 }
 
-// getFuncFullName builds the fully qualified function name
-func (a *VarConstAnalyzer) getFuncFullName(pkg *packages.Package, funcDecl *ast.FuncDecl) string {
-	if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
-		recv := funcDecl.Recv.List[0]
-		typExpr := recv.Type
-		// Unwrap pointer receiver
-		if star, ok := typExpr.(*ast.StarExpr); ok {
-			typExpr = star.X
+// FindInitializers finds package-level var/const initializers that depend on other
+// vars/consts or on functions. Function literals and method values inside an
+// initializer are attributed to the var being initialized.
+func (a *VarConstAnalyzer) FindInitializers() []*InitInfo {
+	varConstObjs := a.varConstObjects()
+	if len(varConstObjs) == 0 {
+		return nil
+	}
+
+	var inits []*InitInfo
+	initSet := make(map[string]bool) // dedup: "name->dependency"
+
+	for _, pkg := range a.pkgs {
+		if pkg.TypesInfo == nil || !a.projectPkgs[pkg.PkgPath] {
+			continue
 		}
-		if ident, ok := typExpr.(*ast.Ident); ok {
-			return "(" + pkg.PkgPath + "." + ident.Name + ")." + funcDecl.Name.Name
+
+		for _, astFile := range pkg.Syntax {
+			for _, decl := range astFile.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || (genDecl.Tok != token.VAR && genDecl.Tok != token.CONST) {
+					continue
+				}
+
+				// Constant specs without values repeat the previous expression list
+				var lastValues []ast.Expr
+				for _, spec := range genDecl.Specs {
+					valueSpec, ok := spec.(*ast.ValueSpec)
+					if !ok {
+						continue
+					}
+					values := valueSpec.Values
+					if genDecl.Tok == token.CONST {
+						if len(values) == 0 {
+							values = lastValues
+						} else {
+							lastValues = values
+						}
+					}
+
+					for i, ident := range valueSpec.Names {
+						name, ok := varConstObjs[pkg.TypesInfo.Defs[ident]]
+						if !ok {
+							continue
+						}
+						// var a, b = f() shares a single initializer
+						var exprs []ast.Expr
+						switch {
+						case len(values) == len(valueSpec.Names):
+							exprs = values[i : i+1]
+						case len(values) == 1:
+							exprs = values
+						}
+						for _, expr := range exprs {
+							a.walkInitializer(pkg, expr, name, varConstObjs, initSet, &inits)
+						}
+					}
+				}
+			}
 		}
 	}
-	return pkg.PkgPath + "." + funcDecl.Name.Name
+
+	return inits
+}
+
+// walkInitializer walks an initializer expression looking for vars/consts and functions it depends on
+func (a *VarConstAnalyzer) walkInitializer(
+	pkg *packages.Package,
+	expr ast.Expr,
+	name string,
+	varConstObjs map[types.Object]string,
+	initSet map[string]bool,
+	inits *[]*InitInfo,
+) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		var dep string
+		var depKind graph.NodeKind
+		switch obj := pkg.TypesInfo.Uses[ident].(type) {
+		case *types.Var, *types.Const:
+			dep, ok = varConstObjs[obj]
+			if !ok {
+				return true
+			}
+			depKind = graph.NodeKindVar
+			if _, isConst := obj.(*types.Const); isConst {
+				depKind = graph.NodeKindConst
+			}
+		case *types.Func:
+			// Calls (computeY()), function values and method values (s.Handle)
+			if obj.Pkg() == nil || !a.projectPkgs[obj.Pkg().Path()] {
+				return true
+			}
+			dep = typesFuncName(obj)
+			depKind = graph.NodeKindFunc
+		default:
+			return true
+		}
+
+		key := name + "->" + dep
+		if dep == name || initSet[key] {
+			return true
+		}
+		initSet[key] = true
+
+		pos := pkg.Fset.Position(ident.Pos())
+		*inits = append(*inits, &InitInfo{
+			Name:       name,
+			Dependency: dep,
+			DepKind:    depKind,
+			File:       a.modules.RelPath(a.projectRoot, pkg.PkgPath, pos.Filename),
+			Line:       pos.Line,
+		})
+		return true
+	})
+}
+
+// typesFuncName builds the SSA-style full name of a function object,
+// e.g. "pkg.Func", "(pkg.T).Method" or "(*pkg.T).Method"
+func typesFuncName(fn *types.Func) string {
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return fn.Pkg().Path() + "." + fn.Name()
+	}
+
+	recv := sig.Recv().Type()
+	prefix := "("
+	if ptr, ok := recv.(*types.Pointer); ok {
+		prefix = "(*"
+		recv = ptr.Elem()
+	}
+	named, ok := types.Unalias(recv).(*types.Named)
+	if !ok {
+		return fn.Pkg().Path() + "." + fn.Name()
+	}
+	obj := named.Obj()
+	return prefix + obj.Pkg().Path() + "." + obj.Name() + ")." + fn.Name()
 }
 
 // getVarConstDoc extracts doc comment for a var/const declaration
@@ -290,6 +408,31 @@ func (a *VarConstAnalyzer) getVarConstDoc(pkg *packages.Package, name string) st
 	return ""
 }
 
+// specEndLines maps the position of each package-level var/const name to the
+// last line of its spec, so "x = func() {...}" spans its whole initializer
+func specEndLines(pkg *packages.Package) map[token.Pos]int {
+	endLines := make(map[token.Pos]int)
+	for _, astFile := range pkg.Syntax {
+		for _, decl := range astFile.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || (genDecl.Tok != token.VAR && genDecl.Tok != token.CONST) {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				valueSpec, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				end := pkg.Fset.Position(valueSpec.End()).Line
+				for _, ident := range valueSpec.Names {
+					endLines[ident.Pos()] = end
+				}
+			}
+		}
+	}
+	return endLines
+}
+
 // BuildVarConstGraph builds the var/const reference graph
 func (a *VarConstAnalyzer) BuildVarConstGraph(
 	insertNodeFn func(*graph.Node) (int64, error),
@@ -304,6 +447,7 @@ func (a *VarConstAnalyzer) BuildVarConstGraph(
 
 	// Insert var/const nodes
 	vcNodeIDs := make(map[string]int64)
+	a.vcNodeIDs = vcNodeIDs
 	for _, vc := range varConsts {
 		node := &graph.Node{
 			Kind:      vc.Kind,
@@ -311,9 +455,12 @@ func (a *VarConstAnalyzer) BuildVarConstGraph(
 			Package:   vc.Package,
 			File:      vc.File,
 			Line:      vc.Line,
+			EndLine:   vc.EndLine,
 			Signature: vc.TypeStr,
 			Doc:       vc.Doc,
+			Value:     vc.Value,
 		}
+		a.modules.Apply(node)
 		id, err := insertNodeFn(node)
 		if err != nil {
 			return 0, 0, 0, err
@@ -348,5 +495,32 @@ func (a *VarConstAnalyzer) BuildVarConstGraph(
 		refCount++
 	}
 
+	// Find and insert initializer dependencies (var X = computeY(), const B = A * 2)
+	for _, init := range a.FindInitializers() {
+		fromID, fromOK := vcNodeIDs[init.Name]
+		var toID int64
+		var toOK bool
+		if init.DepKind == graph.NodeKindFunc {
+			toID, toOK = existingNodeMap[init.Dependency]
+		} else {
+			toID, toOK = vcNodeIDs[init.Dependency]
+		}
+		if !fromOK || !toOK {
+			continue
+		}
+
+		edge := &graph.Edge{
+			FromID:       fromID,
+			ToID:         toID,
+			Kind:         graph.EdgeKindInitializes,
+			CallSiteFile: init.File,
+			CallSiteLine: init.Line,
+		}
+		if err := insertEdgeFn(edge); err != nil {
+			return 0, 0, 0, err
+		}
+		refCount++
+	}
+
 	return varCount, constCount, refCount, nil
 }
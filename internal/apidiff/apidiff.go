@@ -0,0 +1,362 @@
+package apidiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// ChangeType describes how an API element changed between two refs
+type ChangeType string
+
+const (
+	ChangeRemoved ChangeType = "removed"
+	ChangeAdded   ChangeType = "added"
+	ChangeChanged ChangeType = "changed"
+)
+
+// Change represents a single exported API change
+type Change struct {
+	Name         string         `json:"name"`
+	Kind         graph.NodeKind `json:"kind"`
+	Change       ChangeType     `json:"change"`
+	Breaking     bool           `json:"breaking"`
+	Reason       string         `json:"reason"`
+	OldSignature string         `json:"old_signature,omitempty"`
+	NewSignature string         `json:"new_signature,omitempty"`
+	File         string         `json:"file"`
+	Line         int            `json:"line"`
+
+	// Filled for breaking changes from the base graph
+	Callers         []*graph.Node `json:"callers,omitempty"`         // 直接使用者
+	Dependents      []*graph.Node `json:"dependents,omitempty"`      // 间接依赖者 (调用者的上游)
+	Implementations []*graph.Node `json:"implementations,omitempty"` // 需要同步修改的接口实现
+}
+
+// Report is the result of comparing the exported API of two graphs
+type Report struct {
+	Breaking   []*Change `json:"breaking"`
+	Compatible []*Change `json:"compatible"`
+}
+
+// maxDependentDepth bounds the upstream walk from direct callers
+const maxDependentDepth = 20
+
+// apiKinds are the node kinds that make up the exported API
+var apiKinds = []graph.NodeKind{
+	graph.NodeKindFunc,
+	graph.NodeKindInterface,
+	graph.NodeKindStruct,
+	graph.NodeKindField,
+	graph.NodeKindVar,
+	graph.NodeKindConst,
+}
+
+// Compare compares the exported API of the base graph against the head graph.
+// Callers and dependents of breaking changes are looked up in the base graph,
+// where the old API is still in use.
+func Compare(base, head *storage.DB) (*Report, error) {
+	baseNodes, err := exportedNodes(base)
+	if err != nil {
+		return nil, fmt.Errorf("读取基准 API 失败: %w", err)
+	}
+	headNodes, err := exportedNodes(head)
+	if err != nil {
+		return nil, fmt.Errorf("读取当前 API 失败: %w", err)
+	}
+
+	report := &Report{}
+	add := func(c *Change) {
+		if c.Breaking {
+			report.Breaking = append(report.Breaking, c)
+		} else {
+			report.Compatible = append(report.Compatible, c)
+		}
+	}
+
+	for name, old := range baseNodes {
+		cur, ok := headNodes[name]
+		if !ok {
+			add(&Change{
+				Name:         name,
+				Kind:         old.Kind,
+				Change:       ChangeRemoved,
+				Breaking:     true,
+				Reason:       "已删除",
+				OldSignature: old.Signature,
+				File:         old.File,
+				Line:         old.Line,
+			})
+			continue
+		}
+		if c := compareNode(old, cur); c != nil {
+			add(c)
+		}
+	}
+
+	for name, cur := range headNodes {
+		if _, ok := baseNodes[name]; ok {
+			continue
+		}
+		c := &Change{
+			Name:         name,
+			Kind:         cur.Kind,
+			Change:       ChangeAdded,
+			Reason:       "新增",
+			NewSignature: cur.Signature,
+			File:         cur.File,
+			Line:         cur.Line,
+		}
+		// A new method on an existing interface breaks every implementation
+		if iface := interfaceOfMethod(name); iface != "" {
+			if n, ok := baseNodes[iface]; ok && n.Kind == graph.NodeKindInterface {
+				c.Breaking = true
+				c.Reason = "接口新增方法，已有实现需要补充"
+			}
+		}
+		add(c)
+	}
+
+	sortChanges(report.Breaking)
+	sortChanges(report.Compatible)
+
+	for _, c := range report.Breaking {
+		if err := fillUsages(base, baseNodes, c); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// compareNode compares an API element present in both graphs; returns nil if unchanged
+func compareNode(old, cur *graph.Node) *Change {
+	c := &Change{
+		Name:         cur.Name,
+		Kind:         cur.Kind,
+		Change:       ChangeChanged,
+		OldSignature: old.Signature,
+		NewSignature: cur.Signature,
+		File:         cur.File,
+		Line:         cur.Line,
+	}
+
+	if old.Kind != cur.Kind {
+		c.Breaking = true
+		c.Reason = fmt.Sprintf("类型由 %s 变为 %s", old.Kind, cur.Kind)
+		return c
+	}
+
+	switch cur.Kind {
+	case graph.NodeKindField:
+		oldType, oldTag := splitFieldSignature(old.Signature)
+		curType, curTag := splitFieldSignature(cur.Signature)
+		if oldType != curType {
+			c.Breaking = true
+			c.Reason = "字段类型变更"
+			return c
+		}
+		if oldTag != curTag {
+			c.Reason = "字段标签变更 (可能影响序列化格式)"
+			return c
+		}
+		return nil
+	case graph.NodeKindConst:
+		if old.Signature != cur.Signature {
+			c.Breaking = true
+			c.Reason = "常量类型变更"
+			return c
+		}
+		if old.Value != cur.Value {
+			c.Reason = fmt.Sprintf("常量值变更: %s → %s", old.Value, cur.Value)
+			return c
+		}
+		return nil
+	case graph.NodeKindInterface:
+		// Interface method changes are reported per method node
+		return nil
+	default:
+		if old.Signature != cur.Signature {
+			c.Breaking = true
+			c.Reason = "签名变更"
+			return c
+		}
+		return nil
+	}
+}
+
+// fillUsages looks up who uses a breaking API element in the base graph
+func fillUsages(base *storage.DB, baseNodes map[string]*graph.Node, c *Change) error {
+	var err error
+
+	target, ok := baseNodes[c.Name]
+	if !ok {
+		// Added interface method: every implementation of the interface must change
+		if iface, ok := baseNodes[interfaceOfMethod(c.Name)]; ok {
+			c.Implementations, err = base.GetImplementations(iface.ID)
+			return err
+		}
+		return nil
+	}
+
+	switch target.Kind {
+	case graph.NodeKindFunc:
+		if c.Callers, err = base.GetDirectCallers(target.ID); err != nil {
+			return err
+		}
+		// Interface methods: concrete methods must follow the signature
+		if c.Implementations, err = base.GetImplementations(target.ID); err != nil {
+			return err
+		}
+	case graph.NodeKindVar, graph.NodeKindConst:
+		if c.Callers, err = base.GetReferencingFunctions(target.ID); err != nil {
+			return err
+		}
+	case graph.NodeKindInterface:
+		if c.Callers, err = base.GetTypeAssertingFunctions(target.ID); err != nil {
+			return err
+		}
+		if c.Implementations, err = base.GetImplementations(target.ID); err != nil {
+			return err
+		}
+	case graph.NodeKindStruct:
+		if c.Callers, err = structUsers(base, target.ID); err != nil {
+			return err
+		}
+	case graph.NodeKindField:
+		owner, err := base.GetFieldOwner(target.ID)
+		if err == nil {
+			if c.Callers, err = structUsers(base, owner.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.Dependents, err = dependents(base, target.ID, c.Callers)
+	return err
+}
+
+// structUsers returns functions that construct, decode or type-assert a struct
+func structUsers(db *storage.DB, structID int64) ([]*graph.Node, error) {
+	usages, err := db.GetStructUsers(structID)
+	if err != nil {
+		return nil, err
+	}
+	asserts, err := db.GetTypeAssertingFunctions(structID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	var result []*graph.Node
+	for _, u := range usages {
+		if !seen[u.Func.ID] {
+			seen[u.Func.ID] = true
+			result = append(result, u.Func)
+		}
+	}
+	for _, n := range asserts {
+		if !seen[n.ID] {
+			seen[n.ID] = true
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
+// dependents returns the transitive upstream callers of the direct callers
+func dependents(db *storage.DB, targetID int64, callers []*graph.Node) ([]*graph.Node, error) {
+	seen := map[int64]bool{targetID: true}
+	for _, c := range callers {
+		seen[c.ID] = true
+	}
+
+	var result []*graph.Node
+	for _, c := range callers {
+		upstream, err := db.GetUpstreamCallers(c.ID, maxDependentDepth)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range upstream {
+			if !seen[n.ID] {
+				seen[n.ID] = true
+				result = append(result, n)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// exportedNodes returns the exported API nodes of a graph keyed by name
+func exportedNodes(db *storage.DB) (map[string]*graph.Node, error) {
+	nodes, err := db.GetNodesByKinds(apiKinds...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*graph.Node)
+	for _, n := range nodes {
+		if isExportedName(n.Name) {
+			result[n.Name] = n
+		}
+	}
+	return result, nil
+}
+
+// isExportedName reports whether every identifier after the package path of a
+// qualified name is exported, e.g. "pkg.T.Field" or "(*pkg.T).Method"
+func isExportedName(name string) bool {
+	if strings.HasPrefix(name, "(") {
+		end := strings.Index(name, ").")
+		if end < 0 {
+			return false
+		}
+		recv := strings.TrimPrefix(name[1:end], "*")
+		return isExportedName(recv) && isExportedIdent(name[end+2:])
+	}
+
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, p := range parts[1:] {
+		if !isExportedIdent(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func isExportedIdent(s string) bool {
+	return s != "" && s[0] >= 'A' && s[0] <= 'Z'
+}
+
+// interfaceOfMethod returns "pkg.I" for a method name "(pkg.I).M", or ""
+func interfaceOfMethod(name string) string {
+	if !strings.HasPrefix(name, "(") || strings.HasPrefix(name, "(*") {
+		return ""
+	}
+	end := strings.Index(name, ").")
+	if end < 0 {
+		return ""
+	}
+	return name[1:end]
+}
+
+// splitFieldSignature splits a field signature "type `tag`" into type and tag
+func splitFieldSignature(sig string) (typ, tag string) {
+	if idx := strings.Index(sig, " `"); idx >= 0 {
+		return sig[:idx], sig[idx+1:]
+	}
+	return sig, ""
+}
+
+func sortChanges(changes []*Change) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+}
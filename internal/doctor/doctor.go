@@ -0,0 +1,437 @@
+// Package doctor validates a crag database: its schema, the integrity of the
+// stored graph and whether the graph still matches the project's source.
+//
+// Check only reads. Every problem it reports carries the command that fixes
+// it and how `crag doctor --repair` handles it: storage-level problems are
+// fixed in place (Cleanup), problems of the graph itself need the project to
+// be analyzed again, incrementally when the changed files explain them.
+package doctor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zheng/crag/internal/analyzer"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// Repair is how `crag doctor --repair` fixes a problem
+type Repair string
+
+const (
+	RepairNone        Repair = ""            // 需要手动处理
+	RepairMigrate     Repair = "migrate"     // 应用待执行的迁移
+	RepairCleanup     Repair = "cleanup"     // 删除孤立记录并重建可达性索引
+	RepairIncremental Repair = "incremental" // 增量重新分析
+	RepairFull        Repair = "full"        // 全量重新分析
+)
+
+// maxDetails bounds the examples listed for one problem
+const maxDetails = 10
+
+// Result is the outcome of one check
+type Result struct {
+	Check   string   `json:"check"`
+	Title   string   `json:"title"`
+	OK      bool     `json:"ok"`
+	Skipped string   `json:"skipped,omitempty"` // 跳过的原因
+	Message string   `json:"message,omitempty"`
+	Details []string `json:"details,omitempty"`
+	Fix     string   `json:"fix,omitempty"`
+	Repair  Repair   `json:"repair,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Database string           `json:"database"`
+	Metadata *graph.Metadata  `json:"metadata,omitempty"`
+	Results  []*Result        `json:"results"`
+	Orphans  *storage.Orphans `json:"orphans,omitempty"`
+}
+
+// Problems returns the failed checks
+func (r *Report) Problems() []*Result {
+	var problems []*Result
+	for _, res := range r.Results {
+		if !res.OK && res.Skipped == "" {
+			problems = append(problems, res)
+		}
+	}
+	return problems
+}
+
+// Needs reports whether fixing a problem requires the given repair
+func (r *Report) Needs(repair Repair) bool {
+	for _, res := range r.Problems() {
+		if res.Repair == repair {
+			return true
+		}
+	}
+	return false
+}
+
+// Check runs all checks against a database opened without migrating it.
+// When migrations are pending, the graph checks are skipped: they rely on
+// the current schema.
+func Check(db *storage.DB, path string) (*Report, error) {
+	r := &Report{Database: path}
+
+	schema, current, err := checkSchema(db)
+	if err != nil {
+		return nil, err
+	}
+	r.Results = append(r.Results, schema)
+	if !current {
+		for _, c := range []struct{ check, title string }{
+			{"orphans", "孤立记录"}, {"duplicates", "重名节点"}, {"reach_index", "可达性索引"},
+			{"metadata", "分析元数据"}, {"changes", "源码变更"}, {"files", "源文件"}, {"lines", "函数位置"},
+		} {
+			r.Results = append(r.Results, &Result{Check: c.check, Title: c.title, Skipped: "schema 不是最新版本"})
+		}
+		return r, nil
+	}
+
+	if r.Metadata, err = db.GetMetadata(); err != nil {
+		return nil, fmt.Errorf("读取元数据失败: %w", err)
+	}
+	for _, check := range []func(*storage.DB, *Report) (*Result, error){
+		checkOrphans, checkDuplicates, checkReachIndex, checkMetadata,
+	} {
+		res, err := check(db, r)
+		if err != nil {
+			return nil, err
+		}
+		r.Results = append(r.Results, res)
+	}
+
+	source, err := checkSource(db, r.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	r.Results = append(r.Results, source...)
+	return r, nil
+}
+
+// checkSchema reports whether the database has the schema this build writes
+func checkSchema(db *storage.DB) (*Result, bool, error) {
+	res := &Result{Check: "schema", Title: "schema 版本"}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, false, fmt.Errorf("读取 schema 版本失败: %w", err)
+	}
+	pending, err := db.PendingMigrations()
+	if tooNew, ok := err.(*storage.ErrSchemaTooNew); ok {
+		res.Message = tooNew.Error()
+		res.Fix = "升级 crag"
+		return res, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(pending) == 0 {
+		res.OK = true
+		res.Message = fmt.Sprintf("版本 %d", version)
+		return res, true, nil
+	}
+
+	res.Message = fmt.Sprintf("版本 %d，有 %d 个待应用的迁移 (最新为 %d)", version, len(pending), storage.LatestSchemaVersion())
+	for _, m := range pending {
+		res.Details = append(res.Details, fmt.Sprintf("%d  %s", m.Version, m.Description))
+	}
+	res.Fix = "crag db migrate"
+	res.Repair = RepairMigrate
+	return res, false, nil
+}
+
+func checkOrphans(db *storage.DB, r *Report) (*Result, error) {
+	res := &Result{Check: "orphans", Title: "孤立记录"}
+	o, err := db.CountOrphans()
+	if err != nil {
+		return nil, fmt.Errorf("统计孤立记录失败: %w", err)
+	}
+	r.Orphans = o
+	if o.Total() == 0 {
+		res.OK = true
+		return res, nil
+	}
+
+	res.Message = fmt.Sprintf("%d 条记录引用了不存在的节点", o.Total())
+	for _, c := range []struct {
+		name  string
+		count int64
+	}{{"边", o.Edges}, {"字段标签", o.FieldTags}, {"枚举 switch", o.EnumSwitches}, {"外部调用", o.ExternalCalls}} {
+		if c.count > 0 {
+			res.Details = append(res.Details, fmt.Sprintf("%s: %d", c.name, c.count))
+		}
+	}
+	res.Fix = "crag doctor --repair (删除孤立记录)"
+	res.Repair = RepairCleanup
+	return res, nil
+}
+
+func checkDuplicates(db *storage.DB, r *Report) (*Result, error) {
+	res := &Result{Check: "duplicates", Title: "重名节点"}
+	dups, err := db.FindDuplicateNames()
+	if err != nil {
+		return nil, fmt.Errorf("查找重名节点失败: %w", err)
+	}
+	if len(dups) == 0 {
+		res.OK = true
+		return res, nil
+	}
+
+	res.Message = fmt.Sprintf("%d 个名称对应多个节点，按名称查询时结果不确定", len(dups))
+	for i, d := range dups {
+		if i == maxDetails {
+			res.Details = append(res.Details, fmt.Sprintf("... 另有 %d 个", len(dups)-maxDetails))
+			break
+		}
+		res.Details = append(res.Details, fmt.Sprintf("%s (%d 个)", d.Name, d.Count))
+	}
+	res.Fix, res.Repair = analyzeFix(r.Metadata, false)
+	return res, nil
+}
+
+func checkReachIndex(db *storage.DB, r *Report) (*Result, error) {
+	res := &Result{Check: "reach_index", Title: "可达性索引"}
+	missing, err := db.CountUnindexedFuncs()
+	if err != nil {
+		return nil, fmt.Errorf("检查可达性索引失败: %w", err)
+	}
+	if missing == 0 {
+		res.OK = true
+		return res, nil
+	}
+	res.Message = fmt.Sprintf("%d 个函数不在索引中，风险与可达性查询结果不准确", missing)
+	res.Fix = "crag doctor --repair (重建索引)"
+	res.Repair = RepairCleanup
+	return res, nil
+}
+
+func checkMetadata(db *storage.DB, r *Report) (*Result, error) {
+	res := &Result{Check: "metadata", Title: "分析元数据"}
+	if r.Metadata == nil {
+		res.Message = "没有分析元数据 (数据库由旧版本 crag 生成或尚未分析)"
+		res.Fix = "crag analyze <项目路径>"
+		return res, nil
+	}
+	res.OK = true
+	res.Message = r.Metadata.ProjectRoot
+	return res, nil
+}
+
+// checkSource compares the graph with the project's source files: whether
+// they changed since the analysis, and whether the stored nodes still point
+// at existing files and function declarations
+func checkSource(db *storage.DB, meta *graph.Metadata) ([]*Result, error) {
+	changes := &Result{Check: "changes", Title: "源码变更"}
+	files := &Result{Check: "files", Title: "源文件"}
+	lines := &Result{Check: "lines", Title: "函数位置"}
+	results := []*Result{changes, files, lines}
+
+	var skip string
+	switch {
+	case meta == nil:
+		skip = "没有分析元数据"
+	case meta.Snapshot != "":
+		skip = "快照图谱对应历史提交"
+	case meta.ProjectRoot == "":
+		skip = "图谱不对应单个项目 (如 crag merge 的结果)"
+	default:
+		if info, err := os.Stat(meta.ProjectRoot); err != nil || !info.IsDir() {
+			skip = "项目目录在本机不可访问: " + meta.ProjectRoot
+		}
+	}
+	if skip != "" {
+		for _, res := range results {
+			res.Skipped = skip
+		}
+		return results, nil
+	}
+	root := meta.ProjectRoot
+
+	// Files whose content differs from the last analysis
+	previous, err := db.GetSourceFiles()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件记录失败: %w", err)
+	}
+	changed := make(map[string]bool)
+	warnings := analyzer.CheckStale(meta)
+	if len(previous) > 0 {
+		current, err := analyzer.ScanSourceFiles(root, previous)
+		if err != nil {
+			return nil, fmt.Errorf("扫描源文件失败: %w", err)
+		}
+		for _, f := range analyzer.ChangedSourceFiles(previous, current) {
+			changed[f] = true
+		}
+	}
+	switch {
+	case len(changed) > 0:
+		changes.Message = fmt.Sprintf("%d 个文件在分析后有变更", len(changed))
+		changes.Details = append(limitDetails(sortedKeys(changed)), warnings...)
+		changes.Fix, changes.Repair = analyzeFix(meta, true)
+	case len(previous) == 0 && len(warnings) > 0:
+		// No file hashes recorded (older crag): the git state is all there is
+		changes.Message = "图谱可能已过期"
+		changes.Details = warnings
+		changes.Fix, changes.Repair = analyzeFix(meta, false)
+	default:
+		// With identical file contents, a new HEAD or an old analysis is only a hint
+		changes.OK = true
+		changes.Details = warnings
+	}
+
+	nodes, err := db.GetAllNodes()
+	if err != nil {
+		return nil, fmt.Errorf("读取节点失败: %w", err)
+	}
+
+	// Only nodes of the main module live in the project directory
+	missing := make(map[string]int) // file -> nodes
+	var moved []string
+	explained := true // every problem is in a file changed since the analysis
+	sources := make(map[string][]string)
+	for _, n := range nodes {
+		if n.File == "" || filepath.IsAbs(n.File) || n.Module != meta.ModulePath || n.ModuleVersion != "" {
+			continue
+		}
+		text, ok := sources[n.File]
+		if !ok {
+			text, _ = readLines(filepath.Join(root, filepath.FromSlash(n.File)))
+			sources[n.File] = text
+		}
+		if text == nil {
+			missing[n.File]++
+			explained = explained && changed[n.File]
+			continue
+		}
+		if n.Kind == graph.NodeKindFunc && !declaresFunc(text, n) {
+			moved = append(moved, fmt.Sprintf("%s  %s:%d", n.Name, n.File, n.Line))
+			explained = explained && changed[n.File]
+		}
+	}
+
+	fix, repair := analyzeFix(meta, explained && len(previous) > 0)
+	if len(missing) == 0 {
+		files.OK = true
+	} else {
+		count := 0
+		var details []string
+		for _, f := range sortedKeys(missing) {
+			count += missing[f]
+			details = append(details, fmt.Sprintf("%s (%d 个节点)", f, missing[f]))
+		}
+		files.Message = fmt.Sprintf("%d 个节点所在的 %d 个文件已不存在", count, len(missing))
+		files.Details = limitDetails(details)
+		files.Fix, files.Repair = fix, repair
+	}
+	if len(moved) == 0 {
+		lines.OK = true
+	} else {
+		sort.Strings(moved)
+		lines.Message = fmt.Sprintf("%d 个函数的记录行号处不再是其声明", len(moved))
+		lines.Details = limitDetails(moved)
+		lines.Fix, lines.Repair = fix, repair
+	}
+	return results, nil
+}
+
+// analyzeFix returns the command that brings the graph up to date with the project
+func analyzeFix(meta *graph.Metadata, incremental bool) (string, Repair) {
+	if meta == nil || meta.ProjectRoot == "" {
+		return "crag analyze <项目路径>", RepairNone
+	}
+	fix := "crag analyze " + strings.Join(AnalyzeArgs(meta, incremental), " ")
+	if incremental {
+		return fix, RepairIncremental
+	}
+	return fix, RepairFull
+}
+
+// AnalyzeArgs returns the crag analyze arguments that rebuild the graph of a
+// project with the options it was analyzed with. Recorded mode flags such as
+// --incremental and --base do not change the result and are dropped.
+func AnalyzeArgs(meta *graph.Metadata, incremental bool) []string {
+	args := []string{meta.ProjectRoot}
+	for _, f := range strings.Fields(meta.Flags) {
+		if strings.HasPrefix(f, "--include-module=") || strings.HasPrefix(f, "--external-iface=") {
+			args = append(args, f)
+		}
+	}
+	if incremental {
+		args = append(args, "-i")
+	}
+	return args
+}
+
+// declaresFunc reports whether the recorded line of a function node holds its
+// declaration: "func Name(", "func (r T) Name[" or, for interface methods, "Name("
+func declaresFunc(text []string, n *graph.Node) bool {
+	if n.Line < 1 || n.Line > len(text) {
+		return false
+	}
+	name := n.Name[strings.LastIndex(n.Name, ".")+1:]
+	if strings.ContainsAny(name, "$[") {
+		return true // 闭包与泛型实例，没有自己的声明行
+	}
+	line := strings.TrimSpace(text[n.Line-1])
+	if !strings.HasPrefix(line, "func") && !strings.HasPrefix(line, name) {
+		return false
+	}
+	return strings.Contains(line, name+"(") || strings.Contains(line, name+"[")
+}
+
+// readLines returns the lines of a file, or nil if it cannot be read
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func limitDetails(details []string) []string {
+	if len(details) > maxDetails {
+		more := fmt.Sprintf("... 另有 %d 个", len(details)-maxDetails)
+		return append(details[:maxDetails:maxDetails], more)
+	}
+	return details
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Cleanup deletes the orphaned rows and rebuilds the reachability index, which
+// every batch commit does, leaving the rest of the graph as it is
+func Cleanup(db *storage.DB) (*storage.Orphans, error) {
+	batch, err := db.BeginBatch(storage.BatchOptions{})
+	if err != nil {
+		return nil, err
+	}
+	o, err := batch.DeleteOrphans()
+	if err != nil {
+		batch.Rollback()
+		return nil, err
+	}
+	return o, batch.Commit()
+}
@@ -0,0 +1,364 @@
+// Package dump serializes a stored graph to a portable NDJSON file and loads it back.
+//
+// # Format (crag-graph, version 1)
+//
+// A dump is UTF-8 NDJSON: one JSON object per line, each with a "type" and a
+// "data" member. The first line is the header; the other records follow in the
+// order below, each sorted so that dumps of the same graph are byte-identical
+// and diff cleanly in review:
+//
+//	header         {"format":"crag-graph","version":1,"schema_version":11,"crag_version":"…","counts":{…}}
+//	metadata       graph.Metadata: how and when the graph was built (at most one)
+//	node           graph.Node, every kind, by id. IDs are stable (kind + qualified name)
+//	edge           {"from","to","kind","call_site_file","call_site_line"}, by from/to/kind/site
+//	field_tag      graph.FieldTag, by field_id/key
+//	enum_switch    graph.EnumSwitch, by enum_id/func_id/position
+//	file           graph.SourceFile, by path
+//	external_call  graph.ExternalCall, by from_id/callee/site
+//	note           {"node_name","text","created_at"}, by node_name, oldest first
+//
+// Edges, tags and external calls refer to nodes by ID, notes by qualified name.
+// Readers reject dumps with a different format name or a newer version, and
+// skip record types they do not know, so new record types can be added without
+// a version bump. Changing the meaning of an existing field requires a new
+// version.
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+	"github.com/zheng/crag/internal/version"
+)
+
+const (
+	// FormatName identifies crag graph dumps
+	FormatName = "crag-graph"
+	// FormatVersion is the dump format version this build writes and reads
+	FormatVersion = 1
+)
+
+// Record types
+const (
+	TypeHeader     = "header"
+	TypeMetadata   = "metadata"
+	TypeNode       = "node"
+	TypeEdge       = "edge"
+	TypeFieldTag   = "field_tag"
+	TypeEnumSwitch = "enum_switch"
+	TypeFile       = "file"
+	TypeNote       = "note"
+	TypeExternal   = "external_call"
+)
+
+// Header is the first record of a dump
+type Header struct {
+	Format        string         `json:"format"`
+	Version       int            `json:"version"`
+	SchemaVersion int            `json:"schema_version"` // 写出 dump 的数据库 schema 版本
+	CragVersion   string         `json:"crag_version"`
+	Counts        map[string]int `json:"counts"` // 各类记录数量
+}
+
+// Edge is the dump form of an edge. Edge row IDs are not stable, so they are left out.
+type Edge struct {
+	From         int64          `json:"from"`
+	To           int64          `json:"to"`
+	Kind         graph.EdgeKind `json:"kind"`
+	CallSiteFile string         `json:"call_site_file,omitempty"`
+	CallSiteLine int            `json:"call_site_line,omitempty"`
+}
+
+// Note is the dump form of a note. Note IDs are local to a database, so they are left out.
+type Note struct {
+	NodeName  string    `json:"node_name"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type record struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type rawRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Write dumps the graph, its metadata, source files and notes stored in db
+func Write(db *storage.DB, w io.Writer) (*Header, error) {
+	schemaVersion, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	meta, err := db.GetMetadata()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := db.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	edges, err := db.GetAllEdges()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := db.GetAllFieldTags()
+	if err != nil {
+		return nil, err
+	}
+	switches, err := db.GetAllEnumSwitches()
+	if err != nil {
+		return nil, err
+	}
+	files, err := db.GetSourceFiles()
+	if err != nil {
+		return nil, err
+	}
+	notes, err := db.GetAllNotes()
+	if err != nil {
+		return nil, err
+	}
+	externals, err := db.GetAllExternalCalls()
+	if err != nil {
+		return nil, err
+	}
+
+	dumpEdges := make([]*Edge, 0, len(edges))
+	for _, e := range edges {
+		dumpEdges = append(dumpEdges, &Edge{From: e.FromID, To: e.ToID, Kind: e.Kind, CallSiteFile: e.CallSiteFile, CallSiteLine: e.CallSiteLine})
+	}
+	sort.Slice(dumpEdges, func(i, j int) bool {
+		a, b := dumpEdges[i], dumpEdges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.CallSiteFile != b.CallSiteFile {
+			return a.CallSiteFile < b.CallSiteFile
+		}
+		return a.CallSiteLine < b.CallSiteLine
+	})
+
+	header := &Header{
+		Format:        FormatName,
+		Version:       FormatVersion,
+		SchemaVersion: schemaVersion,
+		CragVersion:   version.String(),
+		Counts: map[string]int{
+			TypeNode:       len(nodes),
+			TypeEdge:       len(dumpEdges),
+			TypeFieldTag:   len(tags),
+			TypeEnumSwitch: len(switches),
+			TypeFile:       len(files),
+			TypeNote:       len(notes),
+			TypeExternal:   len(externals),
+		},
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	put := func(typ string, data any) error {
+		return enc.Encode(record{Type: typ, Data: data})
+	}
+
+	if err := put(TypeHeader, header); err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		if err := put(TypeMetadata, meta); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range nodes {
+		if err := put(TypeNode, n); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range dumpEdges {
+		if err := put(TypeEdge, e); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range tags {
+		if err := put(TypeFieldTag, t); err != nil {
+			return nil, err
+		}
+	}
+	for _, sw := range switches {
+		if err := put(TypeEnumSwitch, sw); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range files {
+		if err := put(TypeFile, f); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range externals {
+		if err := put(TypeExternal, c); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range notes {
+		if err := put(TypeNote, &Note{NodeName: n.NodeName, Text: n.Text, CreatedAt: n.CreatedAt}); err != nil {
+			return nil, err
+		}
+	}
+	return header, bw.Flush()
+}
+
+// LoadStats counts the records a Read applied
+type LoadStats struct {
+	Header       *Header
+	Nodes        int
+	Edges        int
+	FieldTags    int
+	EnumSwitches int
+	Files        int
+	External     int
+	Notes        int // 新增的注释 (已存在的相同注释不重复添加)
+	Skipped      int // 未知类型的记录
+}
+
+// Read loads a dump into a batch, replacing the graph, metadata and source
+// files. Notes are merged into the existing ones. Nothing is visible until the
+// caller commits the batch.
+func Read(r io.Reader, batch *storage.Batch) (*LoadStats, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	stats := &LoadStats{}
+	var files []*graph.SourceFile
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec rawRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", lineNo, err)
+		}
+		if stats.Header == nil {
+			if rec.Type != TypeHeader {
+				return nil, fmt.Errorf("不是 crag 图谱 dump: 第一行应为 header 记录")
+			}
+			var h Header
+			if err := json.Unmarshal(rec.Data, &h); err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", lineNo, err)
+			}
+			if h.Format != FormatName {
+				return nil, fmt.Errorf("不是 crag 图谱 dump (format=%q)", h.Format)
+			}
+			if h.Version > FormatVersion {
+				return nil, fmt.Errorf("dump 格式版本为 %d，高于当前 crag 支持的版本 %d，请升级 crag", h.Version, FormatVersion)
+			}
+			stats.Header = &h
+			if err := batch.Clear(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := apply(batch, rec, stats, &files); err != nil {
+			return nil, fmt.Errorf("第 %d 行 (%s): %w", lineNo, rec.Type, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if stats.Header == nil {
+		return nil, fmt.Errorf("dump 为空")
+	}
+
+	if err := batch.SaveSourceFiles(files); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func apply(batch *storage.Batch, rec rawRecord, stats *LoadStats, files *[]*graph.SourceFile) error {
+	switch rec.Type {
+	case TypeMetadata:
+		var meta graph.Metadata
+		if err := json.Unmarshal(rec.Data, &meta); err != nil {
+			return err
+		}
+		if meta.AnalyzedAt.IsZero() {
+			meta.AnalyzedAt = time.Now()
+		}
+		return batch.SaveMetadata(&meta)
+	case TypeNode:
+		var n graph.Node
+		if err := json.Unmarshal(rec.Data, &n); err != nil {
+			return err
+		}
+		stats.Nodes++
+		return batch.RestoreNode(&n)
+	case TypeEdge:
+		var e Edge
+		if err := json.Unmarshal(rec.Data, &e); err != nil {
+			return err
+		}
+		stats.Edges++
+		return batch.InsertEdge(&graph.Edge{FromID: e.From, ToID: e.To, Kind: e.Kind, CallSiteFile: e.CallSiteFile, CallSiteLine: e.CallSiteLine})
+	case TypeFieldTag:
+		var t graph.FieldTag
+		if err := json.Unmarshal(rec.Data, &t); err != nil {
+			return err
+		}
+		stats.FieldTags++
+		return batch.InsertFieldTag(&t)
+	case TypeEnumSwitch:
+		var sw graph.EnumSwitch
+		if err := json.Unmarshal(rec.Data, &sw); err != nil {
+			return err
+		}
+		stats.EnumSwitches++
+		return batch.InsertEnumSwitch(&sw)
+	case TypeFile:
+		var f graph.SourceFile
+		if err := json.Unmarshal(rec.Data, &f); err != nil {
+			return err
+		}
+		stats.Files++
+		*files = append(*files, &f)
+		return nil
+	case TypeExternal:
+		var c graph.ExternalCall
+		if err := json.Unmarshal(rec.Data, &c); err != nil {
+			return err
+		}
+		stats.External++
+		return batch.InsertExternalCall(&c)
+	case TypeNote:
+		var n Note
+		if err := json.Unmarshal(rec.Data, &n); err != nil {
+			return err
+		}
+		added, err := batch.RestoreNote(&graph.Note{NodeName: n.NodeName, Text: n.Text, CreatedAt: n.CreatedAt})
+		if added {
+			stats.Notes++
+		}
+		return err
+	default:
+		stats.Skipped++
+		return nil
+	}
+}
@@ -14,11 +14,11 @@ import (
 
 // Exporter generates RAG documentation from the call graph database
 type Exporter struct {
-	db *storage.DB
+	db storage.Store
 }
 
 // NewExporter creates a new exporter
-func NewExporter(db *storage.DB) *Exporter {
+func NewExporter(db storage.Store) *Exporter {
 	return &Exporter{db: db}
 }
 
@@ -72,12 +72,41 @@ func (e *Exporter) Export(w io.Writer, opts ExportOptions) error {
 		e.writePackageSection(w, pkg, functions, opts)
 	}
 
+	// User notes
+	if err := e.writeNotes(w); err != nil {
+		return err
+	}
+
 	// Impact reference table
 	e.writeImpactTable(w, funcs)
 
 	return nil
 }
 
+// writeNotes writes the user notes of all nodes, grouped by node
+func (e *Exporter) writeNotes(w io.Writer) error {
+	notes, err := e.db.GetAllNotes()
+	if err != nil {
+		return fmt.Errorf("failed to get notes: %w", err)
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(w, "---\n\n## 注释与设计决策\n\n")
+	for i, n := range notes {
+		if i == 0 || n.NodeName != notes[i-1].NodeName {
+			if i > 0 {
+				fmt.Fprintf(w, "\n")
+			}
+			fmt.Fprintf(w, "#### `%s`\n\n", getShortDisplayName(n.NodeName))
+		}
+		fmt.Fprintf(w, "- %s (%s)\n", n.Text, n.CreatedAt.Local().Format("2006-01-02"))
+	}
+	fmt.Fprintf(w, "\n")
+	return nil
+}
+
 // writeProjectStructure writes the project directory structure
 func (e *Exporter) writeProjectStructure(w io.Writer, pkgFuncs map[string][]*graph.Node) {
 	fmt.Fprintf(w, "## 项目结构\n\n```\n")
@@ -343,6 +372,12 @@ func (e *Exporter) ExportIncremental(w io.Writer, changedPackages []string, opts
 
 		fmt.Fprintf(w, "### ⚠️ `%s`\n\n", shortName)
 		fmt.Fprintf(w, "**位置**: `%s:%d`\n\n", getRelativePath(fn.File), fn.Line)
+		if notes, _ := e.db.GetNotes(fn.Name); len(notes) > 0 {
+			for _, n := range notes {
+				fmt.Fprintf(w, "> 📝 %s\n", n.Text)
+			}
+			fmt.Fprintf(w, "\n")
+		}
 		fmt.Fprintf(w, "**以下 %d 个函数调用了此函数，可能需要检查：**\n\n", len(callers))
 		fmt.Fprintf(w, "| 调用者 | 文件 | 行号 |\n")
 		fmt.Fprintf(w, "|--------|------|------|\n")
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
 )
 
 // Builder builds the code graph from SSA and call graph
@@ -18,11 +21,12 @@ type Builder struct {
 	pkgs          []*packages.Package
 	projectRoot   string            // project root directory for relative paths
 	projectPkgs   map[string]bool   // project package paths (to filter out dependencies)
-	targetPkgs    map[string]bool   // target packages to insert (nil means all)
+	modules       ModuleIndex       // package path -> module info
 	nodeMap       map[string]int64  // maps function name to node ID
 	closureParent map[string]string // maps closure name to parent function name
 	insertFn      func(*Node) (int64, error)
 	edgeFn        func(*Edge) error
+	externalFn    func(*ExternalCall) error // records calls into other modules (nil: skipped)
 }
 
 // NewBuilder creates a new graph builder
@@ -49,7 +53,7 @@ func NewBuilder(
 		pkgs:          pkgs,
 		projectRoot:   absRoot,
 		projectPkgs:   projectPkgs,
-		targetPkgs:    nil, // nil means insert all packages
+		modules:       NewModuleIndex(pkgs),
 		nodeMap:       make(map[string]int64),
 		closureParent: make(map[string]string),
 		insertFn:      insertFn,
@@ -57,17 +61,11 @@ func NewBuilder(
 	}
 }
 
-// SetTargetPackages sets the target packages for incremental mode
-// Only functions in these packages will be inserted into the database
-func (b *Builder) SetTargetPackages(pkgPaths []string) {
-	if len(pkgPaths) == 0 {
-		b.targetPkgs = nil
-		return
-	}
-	b.targetPkgs = make(map[string]bool)
-	for _, path := range pkgPaths {
-		b.targetPkgs[path] = true
-	}
+// SetExternalCallFn sets the function that records calls from project functions
+// to functions of other (non-standard-library) modules, which are not part of
+// the graph. crag merge resolves them against the graphs of other repositories.
+func (b *Builder) SetExternalCallFn(fn func(*ExternalCall) error) {
+	b.externalFn = fn
 }
 
 // isProjectFunction checks if a function belongs to the project (not a dependency)
@@ -79,20 +77,6 @@ func (b *Builder) isProjectFunction(fn *ssa.Function) bool {
 	return b.projectPkgs[pkgPath]
 }
 
-// isTargetFunction checks if a function should be inserted into the database
-// In incremental mode, only functions in target packages are inserted
-func (b *Builder) isTargetFunction(fn *ssa.Function) bool {
-	if b.targetPkgs == nil {
-		// No filter, insert all project functions
-		return b.isProjectFunction(fn)
-	}
-	if fn.Pkg == nil {
-		return false
-	}
-	pkgPath := fn.Pkg.Pkg.Path()
-	return b.targetPkgs[pkgPath]
-}
-
 // isClosure checks if a function is a closure (anonymous function)
 // Closures in SSA are named with $N suffix, e.g., "indexCmd$1"
 func (b *Builder) isClosure(fn *ssa.Function) bool {
@@ -137,11 +121,21 @@ func (b *Builder) Build(cg *callgraph.Graph) error {
 		}
 	}
 
-	// Second pass: create function nodes (skip closures)
+	// Second pass: create function nodes (skip closures), in a fixed order so
+	// that functions sharing a name or an ID hash get the same IDs every run
+	fns := make([]*ssa.Function, 0, len(cg.Nodes))
 	for fn, node := range cg.Nodes {
-		if fn == nil || node == nil {
-			continue
+		if fn != nil && node != nil {
+			fns = append(fns, fn)
+		}
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		if a, b := fns[i].String(), fns[j].String(); a != b {
+			return a < b
 		}
+		return fns[i].Pos() < fns[j].Pos()
+	})
+	for _, fn := range fns {
 
 		// Skip synthetic functions (init, etc.) unless they have position info
 		if fn.Synthetic != "" && fn.Pos() == token.NoPos {
@@ -158,11 +152,6 @@ func (b *Builder) Build(cg *callgraph.Graph) error {
 			continue
 		}
 
-		// In incremental mode, only insert functions in target packages
-		if !b.isTargetFunction(fn) {
-			continue
-		}
-
 		nodeID, err := b.createFunctionNode(fn)
 		if err != nil {
 			return fmt.Errorf("failed to create node for %s: %w", fn.String(), err)
@@ -173,6 +162,7 @@ func (b *Builder) Build(cg *callgraph.Graph) error {
 	// Third pass: create call edges (merging closure edges to parents)
 	// Use a set to deduplicate edges
 	edgeSet := make(map[string]bool)
+	externalSet := make(map[string]bool)
 
 	for fn, node := range cg.Nodes {
 		if fn == nil || node == nil {
@@ -195,6 +185,9 @@ func (b *Builder) Build(cg *callgraph.Graph) error {
 			calleeName := b.resolveToParent(edge.Callee.Func.String())
 			toID, ok := b.nodeMap[calleeName]
 			if !ok {
+				if err := b.recordExternalCall(fromID, edge, externalSet); err != nil {
+					return err
+				}
 				continue
 			}
 
@@ -235,6 +228,120 @@ func (b *Builder) Build(cg *callgraph.Graph) error {
 	return nil
 }
 
+// recordExternalCall records a call to a function of another module.
+// Calls are deduplicated per caller and callee, like edges.
+func (b *Builder) recordExternalCall(fromID int64, edge *callgraph.Edge, seen map[string]bool) error {
+	callee := edge.Callee.Func
+	if b.externalFn == nil || b.isProjectFunction(callee) || callee.Pkg == nil {
+		return nil
+	}
+	// Generic instantiations are recorded under their generic function
+	if origin := callee.Origin(); origin != nil {
+		callee = origin
+	}
+	pkgPath := callee.Pkg.Pkg.Path()
+	if IsStdlibPackage(pkgPath) {
+		return nil
+	}
+	name := callee.String()
+	if idx := strings.Index(name, "$"); idx != -1 {
+		name = name[:idx]
+	}
+
+	key := fmt.Sprintf("%d->%s", fromID, name)
+	if seen[key] {
+		return nil
+	}
+	seen[key] = true
+
+	call := &ExternalCall{FromID: fromID, Callee: name, Package: pkgPath}
+	if edge.Site != nil && edge.Site.Pos() != token.NoPos {
+		pos := b.fset.Position(edge.Site.Pos())
+		call.CallSiteFile = pos.Filename
+		call.CallSiteLine = pos.Line
+	}
+	if err := b.externalFn(call); err != nil {
+		return fmt.Errorf("failed to record external call: %w", err)
+	}
+	return nil
+}
+
+// BuildTypeAssertions adds asserts_type edges from functions to the types they check
+// with type assertions (v.(*T)) or type switches, both of which are TypeAssert
+// instructions in SSA. Closures are attributed to their enclosing function.
+// typeNodeMap maps type names (pkg.T) to node IDs; must be called after Build.
+func (b *Builder) BuildTypeAssertions(prog *ssa.Program, typeNodeMap map[string]int64) (int, error) {
+	edgeSet := make(map[[2]int64]bool)
+	count := 0
+
+	for fn := range ssautil.AllFunctions(prog) {
+		if !b.isProjectFunction(fn) {
+			continue
+		}
+		top := fn
+		for top.Parent() != nil {
+			top = top.Parent()
+		}
+		fromID, ok := b.nodeMap[top.String()]
+		if !ok {
+			continue
+		}
+
+		for _, block := range fn.Blocks {
+			for _, instr := range block.Instrs {
+				assert, ok := instr.(*ssa.TypeAssert)
+				if !ok {
+					continue
+				}
+				// SSA emits x.(I) with x already of type I as a nil check for
+				// interface method values; that is not a type check in the source
+				if types.Identical(assert.X.Type(), assert.AssertedType) {
+					continue
+				}
+				toID, ok := typeNodeMap[assertedTypeName(assert.AssertedType)]
+				if !ok || edgeSet[[2]int64{fromID, toID}] {
+					continue
+				}
+				edgeSet[[2]int64{fromID, toID}] = true
+
+				var siteFile string
+				var siteLine int
+				if assert.Pos() != token.NoPos {
+					pos := b.fset.Position(assert.Pos())
+					siteFile = b.modules.RelPath(b.projectRoot, fn.Pkg.Pkg.Path(), pos.Filename)
+					siteLine = pos.Line
+				}
+
+				if err := b.edgeFn(&Edge{
+					FromID:       fromID,
+					ToID:         toID,
+					Kind:         EdgeKindAssertsType,
+					CallSiteFile: siteFile,
+					CallSiteLine: siteLine,
+				}); err != nil {
+					return count, fmt.Errorf("failed to create edge: %w", err)
+				}
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// assertedTypeName returns the "pkg.T" name of an asserted type (pointers unwrapped),
+// or "" if it is not a named type
+func assertedTypeName(t types.Type) string {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+	return named.Obj().Pkg().Path() + "." + named.Obj().Name()
+}
+
 // createFunctionNode creates a node for a function
 func (b *Builder) createFunctionNode(fn *ssa.Function) (int64, error) {
 	pos := b.fset.Position(fn.Pos())
@@ -255,12 +362,7 @@ func (b *Builder) createFunctionNode(fn *ssa.Function) (int64, error) {
 	name := fn.String()
 
 	// Convert file path to relative path
-	filePath := pos.Filename
-	if b.projectRoot != "" && filePath != "" {
-		if rel, err := filepath.Rel(b.projectRoot, filePath); err == nil {
-			filePath = rel
-		}
-	}
+	filePath := b.modules.RelPath(b.projectRoot, pkgPath, pos.Filename)
 
 	node := &Node{
 		Kind:      NodeKindFunc,
@@ -271,6 +373,10 @@ func (b *Builder) createFunctionNode(fn *ssa.Function) (int64, error) {
 		Signature: sig,
 		Doc:       doc,
 	}
+	if syntax := fn.Syntax(); syntax != nil {
+		node.EndLine = b.fset.Position(syntax.End()).Line
+	}
+	b.modules.Apply(node)
 
 	return b.insertFn(node)
 }
@@ -4,9 +4,15 @@ package graph
 type EdgeKind string
 
 const (
-	EdgeKindCalls      EdgeKind = "calls"
-	EdgeKindImplements EdgeKind = "implements"
-	EdgeKindReferences EdgeKind = "references"
+	EdgeKindCalls       EdgeKind = "calls"
+	EdgeKindImplements  EdgeKind = "implements"
+	EdgeKindReferences  EdgeKind = "references"
+	EdgeKindHasField    EdgeKind = "has_field"    // struct -> field
+	EdgeKindConstructs  EdgeKind = "constructs"   // func -> struct (composite literal / new)
+	EdgeKindDecodes     EdgeKind = "decodes"      // func -> struct (Unmarshal/Decode/Scan target)
+	EdgeKindHasMember   EdgeKind = "has_member"   // enum -> const
+	EdgeKindInitializes EdgeKind = "initializes"  // var/const -> var/const/func its initializer depends on
+	EdgeKindAssertsType EdgeKind = "asserts_type" // func -> type (type assertion / type switch)
 )
 
 // Edge represents a relationship between two nodes
@@ -0,0 +1,21 @@
+package graph
+
+import "strings"
+
+// ExternalCall is a call from a project function to a function of another
+// module that is not part of the graph. crag merge resolves these against the
+// graphs of other repositories.
+type ExternalCall struct {
+	FromID       int64  `json:"from_id"`
+	Callee       string `json:"callee"`  // 被调用函数的完整限定名
+	Package      string `json:"package"` // 被调用函数的包路径
+	CallSiteFile string `json:"call_site_file,omitempty"`
+	CallSiteLine int    `json:"call_site_line,omitempty"`
+}
+
+// IsStdlibPackage reports whether a package path belongs to the standard
+// library, whose first path element has no dot (fmt, net/http)
+func IsStdlibPackage(pkgPath string) bool {
+	first, _, _ := strings.Cut(pkgPath, "/")
+	return !strings.Contains(first, ".")
+}
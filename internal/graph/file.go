@@ -0,0 +1,10 @@
+package graph
+
+// SourceFile is a Go source file the graph was built from
+type SourceFile struct {
+	Path    string `json:"path"`    // 相对项目根目录的路径 (/ 分隔)
+	Package string `json:"package"` // 所属包路径，未被加载的文件 (如构建标签排除) 为空
+	Hash    string `json:"hash"`    // 内容的 SHA-256
+	ModTime int64  `json:"mtime"`   // 修改时间 (Unix 纳秒)
+	Size    int64  `json:"size"`    // 文件大小
+}
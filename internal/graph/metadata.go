@@ -0,0 +1,20 @@
+package graph
+
+import "time"
+
+// Metadata describes how and when a graph was built
+type Metadata struct {
+	ProjectRoot  string    `json:"project_root"`         // 项目绝对路径
+	ModulePath   string    `json:"module_path"`          // 主模块路径
+	GitCommit    string    `json:"git_commit,omitempty"` // 分析时的 HEAD
+	GitDirty     bool      `json:"git_dirty"`            // 工作区是否有未提交的变更
+	GoVersion    string    `json:"go_version"`           // 加载包使用的 Go 版本
+	CragVersion  string    `json:"crag_version"`         // crag 版本
+	Algorithm    string    `json:"algorithm"`            // 调用图算法
+	Flags        string    `json:"flags,omitempty"`      // 影响结果的分析参数
+	Incremental  bool      `json:"incremental"`          // 是否为增量分析
+	Snapshot     string    `json:"snapshot,omitempty"`   // 快照名称 (crag analyze --snapshot)
+	PackageCount int       `json:"package_count"`        // 分析的包数量
+	DurationMs   int64     `json:"duration_ms"`          // 分析耗时 (毫秒)
+	AnalyzedAt   time.Time `json:"analyzed_at"`          // 分析完成时间
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ModuleInfo describes the Go module a package belongs to
+type ModuleInfo struct {
+	Path    string // 模块路径
+	Version string // 模块版本 (主模块为空)
+	Dir     string // 模块根目录
+	Main    bool   // 是否为主模块
+}
+
+// ModuleIndex maps package paths to the modules they belong to
+type ModuleIndex map[string]*ModuleInfo
+
+// NewModuleIndex builds a module index from loaded packages.
+// Packages loaded without module information (GOPATH mode) are skipped.
+func NewModuleIndex(pkgs []*packages.Package) ModuleIndex {
+	index := make(ModuleIndex)
+	for _, pkg := range pkgs {
+		if pkg.PkgPath == "" || pkg.Module == nil {
+			continue
+		}
+		mod := pkg.Module
+		// Respect replace directives so that Dir points at the real source
+		if mod.Replace != nil && mod.Replace.Dir != "" {
+			index[pkg.PkgPath] = &ModuleInfo{
+				Path:    mod.Path,
+				Version: mod.Replace.Version,
+				Dir:     mod.Replace.Dir,
+				Main:    mod.Main,
+			}
+			continue
+		}
+		index[pkg.PkgPath] = &ModuleInfo{
+			Path:    mod.Path,
+			Version: mod.Version,
+			Dir:     mod.Dir,
+			Main:    mod.Main,
+		}
+	}
+	return index
+}
+
+// Lookup returns the module of a package, or nil if unknown
+func (m ModuleIndex) Lookup(pkgPath string) *ModuleInfo {
+	if m == nil {
+		return nil
+	}
+	return m[pkgPath]
+}
+
+// RelPath converts an absolute source file path into the path stored in the database.
+// Files inside the project root are stored relative to it. Files of included
+// dependency modules live outside the project (e.g. in the module cache), so they
+// are stored as "module@version/relative/path.go" instead of "../../go/pkg/mod/...".
+func (m ModuleIndex) RelPath(projectRoot, pkgPath, file string) string {
+	if file == "" {
+		return file
+	}
+	if projectRoot != "" {
+		if rel, err := filepath.Rel(projectRoot, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+
+	mod := m.Lookup(pkgPath)
+	if mod == nil || mod.Main || mod.Dir == "" {
+		if projectRoot != "" {
+			if rel, err := filepath.Rel(projectRoot, file); err == nil {
+				return rel
+			}
+		}
+		return file
+	}
+
+	rel, err := filepath.Rel(mod.Dir, file)
+	if err != nil {
+		return file
+	}
+	prefix := mod.Path
+	if mod.Version != "" {
+		prefix += "@" + mod.Version
+	}
+	return prefix + "/" + filepath.ToSlash(rel)
+}
+
+// Apply fills in the module fields of a node from its package
+func (m ModuleIndex) Apply(node *Node) {
+	if mod := m.Lookup(node.Package); mod != nil {
+		node.Module = mod.Path
+		node.ModuleVersion = mod.Version
+	}
+}
@@ -10,6 +10,8 @@ const (
 	NodeKindPackage   NodeKind = "package"
 	NodeKindVar       NodeKind = "var"
 	NodeKindConst     NodeKind = "const"
+	NodeKindField     NodeKind = "field"
+	NodeKindEnum      NodeKind = "enum"
 )
 
 // Node represents a code element in the call graph
@@ -22,5 +24,27 @@ type Node struct {
 	Line      int      `json:"line"`      // 起始行号
 	Signature string   `json:"signature"` // 函数签名
 	Doc       string   `json:"doc"`       // 文档注释
+
+	Module        string `json:"module,omitempty"`         // 所属模块路径
+	ModuleVersion string `json:"module_version,omitempty"` // 所属模块版本 (主模块为空)
+	Value         string `json:"value,omitempty"`          // 常量值 (仅 const)
+	EndLine       int    `json:"end_line,omitempty"`       // 结束行号 (func/var/const，0 表示未记录)
+}
+
+// FieldTag represents one key of a struct field tag, e.g. json:"user_id,omitempty"
+type FieldTag struct {
+	FieldID int64  `json:"field_id"`
+	Key     string `json:"key"`     // 标签键 (json, db, yaml...)
+	Name    string `json:"name"`    // 逗号前的名称 (user_id)
+	Options string `json:"options"` // 逗号后的选项 (omitempty)
 }
 
+// EnumSwitch represents a switch statement over an enum type
+type EnumSwitch struct {
+	EnumID     int64    `json:"enum_id"`
+	FuncID     int64    `json:"func_id"`
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	Missing    []string `json:"missing"`     // 未处理的成员
+	HasDefault bool     `json:"has_default"` // 是否有 default 分支
+}
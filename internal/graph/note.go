@@ -0,0 +1,12 @@
+package graph
+
+import "time"
+
+// Note is a user annotation attached to a node. It is keyed by the node's
+// qualified name rather than its ID, so it survives re-analysis.
+type Note struct {
+	ID        int64     `json:"id"`
+	NodeName  string    `json:"node_name"`  // 节点完整名称
+	Text      string    `json:"text"`       // 注释内容
+	CreatedAt time.Time `json:"created_at"` // 添加时间
+}
@@ -5,16 +5,17 @@ import (
 	"strings"
 
 	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/owners"
 	"github.com/zheng/crag/internal/storage"
 )
 
 // Analyzer performs impact analysis on the code graph
 type Analyzer struct {
-	db *storage.DB
+	db storage.Store
 }
 
 // NewAnalyzer creates a new impact analyzer
-func NewAnalyzer(db *storage.DB) *Analyzer {
+func NewAnalyzer(db storage.Store) *Analyzer {
 	return &Analyzer{db: db}
 }
 
@@ -25,6 +26,14 @@ type ImpactReport struct {
 	IndirectCallers []*graph.Node `json:"indirect_callers"`
 	DirectCallees   []*graph.Node `json:"direct_callees"`
 	IndirectCallees []*graph.Node `json:"indirect_callees"`
+	DerivedValues   []*graph.Node `json:"derived_values,omitempty"`  // vars/consts initialized from the target
+	Implementations []*graph.Node `json:"implementations,omitempty"` // concrete methods of an interface method target
+	TypeAssertions  []*graph.Node `json:"type_assertions,omitempty"` // functions type-checking against a type target
+	Notes           []*graph.Note `json:"notes,omitempty"`           // user notes attached to the target
+
+	// Filled by GroupByOwner when the project has a CODEOWNERS file
+	TargetOwners []string        `json:"target_owners,omitempty"`
+	OwnerGroups  []*owners.Group `json:"owner_groups,omitempty"` // all callers, grouped by owner
 }
 
 // AnalyzeImpact analyzes the impact of changing a function
@@ -53,6 +62,10 @@ func (a *Analyzer) AnalyzeImpact(funcName string, upstreamDepth, downstreamDepth
 	report := &ImpactReport{
 		Target: target,
 	}
+	report.Notes, err = a.db.GetNotes(target.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
 
 	// For var/const targets, find referencing functions instead of callers
 	if target.Kind == graph.NodeKindVar || target.Kind == graph.NodeKindConst {
@@ -60,10 +73,21 @@ func (a *Analyzer) AnalyzeImpact(funcName string, upstreamDepth, downstreamDepth
 		if err != nil {
 			return nil, fmt.Errorf("failed to get referencing functions: %w", err)
 		}
+		if err := a.addDerivedValues(report); err != nil {
+			return nil, err
+		}
 		// var/const don't call other functions
 		return report, nil
 	}
 
+	// For types, find functions that type-assert or type-switch on them
+	if target.Kind == graph.NodeKindStruct || target.Kind == graph.NodeKindInterface {
+		report.TypeAssertions, err = a.db.GetTypeAssertingFunctions(target.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get type assertions: %w", err)
+		}
+	}
+
 	// Get direct callers
 	report.DirectCallers, err = a.db.GetDirectCallers(target.ID)
 	if err != nil {
@@ -112,9 +136,207 @@ func (a *Analyzer) AnalyzeImpact(funcName string, upstreamDepth, downstreamDepth
 		}
 	}
 
+	// Concrete methods, if the target is an interface method
+	report.Implementations, err = a.db.GetImplementations(target.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get implementations: %w", err)
+	}
+
+	// Package-level vars initialized by calling the function
+	report.DerivedValues, err = a.db.GetDerivedVarConsts(target.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get derived vars/consts: %w", err)
+	}
+
 	return report, nil
 }
 
+// addDerivedValues fills in vars/consts derived from a var/const target, and treats
+// functions referencing those derived values as indirect callers
+func (a *Analyzer) addDerivedValues(report *ImpactReport) error {
+	derived, err := a.db.GetDerivedVarConsts(report.Target.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get derived vars/consts: %w", err)
+	}
+	report.DerivedValues = derived
+
+	seen := make(map[int64]bool)
+	for _, c := range report.DirectCallers {
+		seen[c.ID] = true
+	}
+	for _, d := range derived {
+		refs, err := a.db.GetReferencingFunctions(d.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get referencing functions: %w", err)
+		}
+		for _, r := range refs {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				report.IndirectCallers = append(report.IndirectCallers, r)
+			}
+		}
+	}
+	return nil
+}
+
+// FormatNotes formats the user notes attached to the target.
+// Returns "" if there are none.
+func (r *ImpactReport) FormatNotes() string {
+	return FormatNotes(r.Notes)
+}
+
+// FormatNotes formats notes as a tree, oldest first. Returns "" if there are none.
+func FormatNotes(notes []*graph.Note) string {
+	if len(notes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 注释 (共 %d 条)\n", len(notes)))
+	for i, n := range notes {
+		prefix := "├──"
+		if i == len(notes)-1 {
+			prefix = "└──"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s  (%s)\n", prefix, n.Text, n.CreatedAt.Local().Format("2006-01-02")))
+	}
+	return sb.String()
+}
+
+// GroupByOwner assigns the target and its callers to their CODEOWNERS owners.
+// It does nothing if rs is nil.
+func (r *ImpactReport) GroupByOwner(rs *owners.Ruleset) {
+	if rs == nil {
+		return
+	}
+	r.TargetOwners = rs.Owners(r.Target.File)
+	callers := append(append([]*graph.Node{}, r.DirectCallers...), r.IndirectCallers...)
+	callers = append(callers, r.TypeAssertions...)
+	if len(callers) > 0 {
+		r.OwnerGroups = rs.GroupByOwner(callers)
+	}
+}
+
+// FormatOwners formats the callers grouped by owner. Returns "" if they were not grouped.
+func (r *ImpactReport) FormatOwners() string {
+	if len(r.OwnerGroups) == 0 {
+		return ""
+	}
+	result := ""
+	if len(r.TargetOwners) > 0 {
+		result = fmt.Sprintf("👤 负责人: %s\n", strings.Join(r.TargetOwners, " "))
+	}
+	return result + FormatOwnerGroups("👥 需要通知的负责人", r.OwnerGroups)
+}
+
+// maxOwnerGroupNames is the number of node names listed per owner group
+const maxOwnerGroupNames = 5
+
+// FormatOwnerGroups formats nodes grouped by owner as a tree, listing the first
+// few names of each group. Returns "" if there are no groups.
+func FormatOwnerGroups(title string, groups []*owners.Group) string {
+	if len(groups) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (共 %d 个)\n", title, len(groups)))
+	for i, g := range groups {
+		prefix, indent := "├──", "│   "
+		if i == len(groups)-1 {
+			prefix, indent = "└──", "    "
+		}
+		sb.WriteString(fmt.Sprintf("%s %s  %d 个函数\n", prefix, owners.OwnerLabel(g.Owner), len(g.Nodes)))
+
+		var names []string
+		for _, n := range g.Nodes {
+			if len(names) == maxOwnerGroupNames {
+				break
+			}
+			names = append(names, shortName(n.Name))
+		}
+		line := strings.Join(names, ", ")
+		if len(g.Nodes) > maxOwnerGroupNames {
+			line += fmt.Sprintf(" 等 %d 个", len(g.Nodes))
+		}
+		sb.WriteString(indent + line + "\n")
+	}
+	return sb.String()
+}
+
+// FormatImplementations formats the concrete methods of an interface method target.
+// Returns "" if there are none.
+func (r *ImpactReport) FormatImplementations() string {
+	if len(r.Implementations) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧩 接口方法实现 (共 %d 个)\n", len(r.Implementations)))
+	for i, impl := range r.Implementations {
+		prefix := "├──"
+		if i == len(r.Implementations)-1 {
+			prefix = "└──"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s  %s:%d\n", prefix, shortName(impl.Name), impl.File, impl.Line))
+	}
+	return sb.String()
+}
+
+// FormatTypeAssertions formats the functions that type-check against a type target.
+// Returns "" if there are none.
+func (r *ImpactReport) FormatTypeAssertions() string {
+	if len(r.TypeAssertions) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔍 类型断言/类型分支 (共 %d 个函数)\n", len(r.TypeAssertions)))
+	for i, fn := range r.TypeAssertions {
+		prefix := "├──"
+		if i == len(r.TypeAssertions)-1 {
+			prefix = "└──"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s  %s:%d\n", prefix, shortName(fn.Name), fn.File, fn.Line))
+	}
+	return sb.String()
+}
+
+// FormatDerived formats derived vars/consts and the functions that reach the
+// target only through them. Returns "" if there are none.
+func (r *ImpactReport) FormatDerived() string {
+	if len(r.DerivedValues) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔗 由此派生的变量/常量 (共 %d 个)\n", len(r.DerivedValues)))
+	for i, d := range r.DerivedValues {
+		prefix := "├──"
+		if i == len(r.DerivedValues)-1 {
+			prefix = "└──"
+		}
+		line := fmt.Sprintf("%s %s  %s:%d", prefix, shortName(d.Name), d.File, d.Line)
+		if d.Value != "" {
+			line += "  = " + d.Value
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	isVarConst := r.Target.Kind == graph.NodeKindVar || r.Target.Kind == graph.NodeKindConst
+	if isVarConst && len(r.IndirectCallers) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⬆️ 通过派生值间接引用的函数 (共 %d 个)\n", len(r.IndirectCallers)))
+		for i, c := range r.IndirectCallers {
+			prefix := "├──"
+			if i == len(r.IndirectCallers)-1 {
+				prefix = "└──"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s  %s:%d\n", prefix, shortName(c.Name), c.File, c.Line))
+		}
+	}
+	return sb.String()
+}
+
 // shortName simplifies a fully qualified function name
 // e.g., "(*github.com/foo/bar/pkg.Type).Method" -> "(*pkg.Type).Method"
 func shortName(fullName string) string {
@@ -152,6 +374,32 @@ func (r *ImpactReport) FormatMarkdown() string {
 		sb.WriteString(fmt.Sprintf("**文档:** %s\n\n", r.Target.Doc))
 	}
 
+	if len(r.Notes) > 0 {
+		sb.WriteString("### 注释\n\n")
+		for _, n := range r.Notes {
+			sb.WriteString(fmt.Sprintf("- %s (%s)\n", n.Text, n.CreatedAt.Local().Format("2006-01-02")))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Callers grouped by owner
+	if len(r.OwnerGroups) > 0 {
+		sb.WriteString("### 需要通知的负责人\n\n")
+		if len(r.TargetOwners) > 0 {
+			sb.WriteString(fmt.Sprintf("本函数负责人: %s\n\n", strings.Join(r.TargetOwners, " ")))
+		}
+		sb.WriteString("| 负责人 | 调用者数 | 调用者 |\n")
+		sb.WriteString("|--------|----------|--------|\n")
+		for _, g := range r.OwnerGroups {
+			var names []string
+			for _, n := range g.Nodes {
+				names = append(names, shortName(n.Name))
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %d | %s |\n", owners.OwnerLabel(g.Owner), len(g.Nodes), strings.Join(names, ", ")))
+		}
+		sb.WriteString("\n")
+	}
+
 	// Direct callers
 	sb.WriteString("### 直接调用者 (需检查是否需要同步修改)\n\n")
 	if len(r.DirectCallers) == 0 {
@@ -165,6 +413,39 @@ func (r *ImpactReport) FormatMarkdown() string {
 		sb.WriteString("\n")
 	}
 
+	// Implementations of an interface method
+	if len(r.Implementations) > 0 {
+		sb.WriteString("### 接口方法实现 (签名变更需同步修改)\n\n")
+		sb.WriteString("| 方法 | 文件 | 行号 |\n")
+		sb.WriteString("|------|------|------|\n")
+		for _, impl := range r.Implementations {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d |\n", shortName(impl.Name), impl.File, impl.Line))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Type assertions / type switches against a type target
+	if len(r.TypeAssertions) > 0 {
+		sb.WriteString("### 类型断言/类型分支 (重命名或删除类型需检查)\n\n")
+		sb.WriteString("| 函数 | 文件 | 行号 |\n")
+		sb.WriteString("|------|------|------|\n")
+		for _, fn := range r.TypeAssertions {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d |\n", shortName(fn.Name), fn.File, fn.Line))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Derived vars/consts
+	if len(r.DerivedValues) > 0 {
+		sb.WriteString("### 派生变量/常量 (初始化依赖于此)\n\n")
+		sb.WriteString("| 名称 | 文件 | 行号 |\n")
+		sb.WriteString("|------|------|------|\n")
+		for _, d := range r.DerivedValues {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d |\n", shortName(d.Name), d.File, d.Line))
+		}
+		sb.WriteString("\n")
+	}
+
 	// Indirect callers
 	if len(r.IndirectCallers) > 0 {
 		sb.WriteString("### 间接调用者 (可能受影响)\n\n")
@@ -232,6 +513,9 @@ func (r *ImpactReport) FormatTree() string {
 		sb.WriteString(fmt.Sprintf("   %s\n", r.Target.Signature))
 	}
 	sb.WriteString("\n")
+	if notes := r.FormatNotes(); notes != "" {
+		sb.WriteString(notes + "\n")
+	}
 
 	// Upstream callers
 	callerCount := len(allCallers)
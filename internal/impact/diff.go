@@ -0,0 +1,602 @@
+package impact
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/owners"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// LineRange is an inclusive range of line numbers
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// FileDiff holds the lines a unified diff changes in one file, on both sides.
+// Lines added on one side are marked on the other at the line following the
+// insertion point, so a change always touches the symbol it is made in.
+type FileDiff struct {
+	OldPath string      `json:"old_path,omitempty"` // 新增文件为空
+	NewPath string      `json:"new_path,omitempty"` // 删除文件为空
+	Old     []LineRange `json:"old"`
+	New     []LineRange `json:"new"`
+}
+
+// Path returns the path of the file after the change, or before it if it was deleted
+func (f *FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// ParseDiff parses a unified diff as written by git diff or diff -u. Paths
+// lose their a/ and b/ prefixes.
+func ParseDiff(r io.Reader) ([]*FileDiff, error) {
+	var files []*FileDiff
+	var cur *FileDiff
+	var oldLines, newLines []int
+	oldLine, newLine := 0, 0
+	oldLeft, newLeft := 0, 0 // 当前 hunk 剩余的行数
+	added, removed := false, false
+
+	// endBlock marks the insertion point of a run of only added or only removed lines
+	endBlock := func() {
+		if added && !removed {
+			oldLines = append(oldLines, oldLine)
+		}
+		if removed && !added {
+			newLines = append(newLines, newLine)
+		}
+		added, removed = false, false
+	}
+	finish := func() {
+		if cur == nil {
+			return
+		}
+		endBlock()
+		cur.Old, cur.New = toRanges(oldLines), toRanges(newLines)
+		files = append(files, cur)
+		cur, oldLines, newLines = nil, nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLines = append(oldLines, oldLine)
+				oldLine++
+				oldLeft--
+				removed = true
+			case strings.HasPrefix(line, "+"):
+				newLines = append(newLines, newLine)
+				newLine++
+				newLeft--
+				added = true
+			case strings.HasPrefix(line, `\`):
+				// \ No newline at end of file
+			default:
+				// Context; some tools strip the space of empty lines
+				endBlock()
+				oldLine++
+				newLine++
+				oldLeft--
+				newLeft--
+			}
+			if oldLeft <= 0 && newLeft <= 0 {
+				endBlock()
+				oldLeft, newLeft = 0, 0
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			finish()
+			cur = &FileDiff{}
+			if a, b, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				cur.OldPath, cur.NewPath = strings.TrimPrefix(a, "a/"), b
+			}
+		case strings.HasPrefix(line, "--- "):
+			// Without diff --git lines (diff -u), each file starts here
+			if cur == nil || len(oldLines) > 0 || len(newLines) > 0 {
+				finish()
+				cur = &FileDiff{}
+			}
+			cur.OldPath = diffPath(line[4:], "a/")
+		case strings.HasPrefix(line, "+++ "):
+			if cur == nil {
+				cur = &FileDiff{}
+			}
+			cur.NewPath = diffPath(line[4:], "b/")
+		case strings.HasPrefix(line, "@@ "):
+			if cur == nil {
+				return nil, fmt.Errorf("第 %d 行: hunk 之前没有文件头", lineNo)
+			}
+			var err error
+			if oldLine, oldLeft, newLine, newLeft, err = parseHunkHeader(line); err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", lineNo, err)
+			}
+		}
+		// Anything else is index, mode, rename or similarity lines, or text
+		// around the patch such as a commit message
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	return files, nil
+}
+
+// diffPath extracts the path of a ---/+++ line: no timestamp, no a/ or b/ prefix,
+// "" for /dev/null
+func diffPath(s, prefix string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@" into the first line and line count
+// of each side. An empty side (s = 0) names the line before the change; the
+// next line is returned.
+func parseHunkHeader(line string) (oldStart, oldCount, newStart, newCount int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, 0, fmt.Errorf("无效的 hunk 头: %s", line)
+	}
+	side := func(s string) (start, count int, err error) {
+		first, n, hasCount := strings.Cut(s[1:], ",")
+		count = 1
+		if start, err = strconv.Atoi(first); err == nil && hasCount {
+			count, err = strconv.Atoi(n)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("无效的 hunk 头: %s", line)
+		}
+		if count == 0 {
+			start++
+		}
+		return start, count, nil
+	}
+	if oldStart, oldCount, err = side(fields[1]); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	newStart, newCount, err = side(fields[2])
+	return oldStart, oldCount, newStart, newCount, err
+}
+
+// toRanges merges sorted line numbers into ranges of consecutive lines
+func toRanges(lines []int) []LineRange {
+	sort.Ints(lines)
+	var ranges []LineRange
+	for _, l := range lines {
+		if l < 1 {
+			l = 1
+		}
+		if n := len(ranges); n > 0 && l <= ranges[n-1].End+1 {
+			if l > ranges[n-1].End {
+				ranges[n-1].End = l
+			}
+			continue
+		}
+		ranges = append(ranges, LineRange{l, l})
+	}
+	return ranges
+}
+
+// ChangedSymbol is a function, var or const a diff touches
+type ChangedSymbol struct {
+	Node          *graph.Node `json:"node"`
+	Lines         []LineRange `json:"lines"` // 与该符号重叠的变更行
+	DirectCallers int         `json:"direct_callers"`
+	TotalCallers  int         `json:"total_callers"`
+	RiskLevel     string      `json:"risk_level"`
+}
+
+// FileLines are changed lines of a file
+type FileLines struct {
+	File  string      `json:"file"`
+	Lines []LineRange `json:"lines"`
+}
+
+// DiffReport is the impact of a whole diff: every symbol it touches, the
+// union of their callers, and the highest risk among them
+type DiffReport struct {
+	Base      string                   `json:"base,omitempty"`
+	Files     int                      `json:"files"`
+	Changed   []*ChangedSymbol         `json:"changed"`
+	Callers   []*storage.NodeWithDepth `json:"callers"`            // 受影响的调用者 (不含变更的符号)，按深度排序
+	Unmapped  []*FileLines             `json:"unmapped,omitempty"` // 不在任何函数、变量、常量内的变更 (import、类型、注释、未记录的未导出声明或未分析的新代码)
+	RiskLevel string                   `json:"risk_level"`
+
+	// Filled by GroupByOwner when the project has a CODEOWNERS file
+	OwnerGroups []*owners.Group `json:"owner_groups,omitempty"`
+}
+
+// DiffOptions configures AnalyzeDiff
+type DiffOptions struct {
+	Base          string
+	UpstreamDepth int
+	// Current reports whether the graph was built from a file after the change,
+	// so the file's new line numbers apply. Otherwise the old ones do.
+	Current func(path string) bool
+}
+
+// AnalyzeDiff maps the changed lines of a diff to the functions, vars and
+// consts containing them, using the line ranges stored in the graph, and
+// aggregates their callers and risk into one report
+func (a *Analyzer) AnalyzeDiff(files []*FileDiff, opts DiffOptions) (*DiffReport, error) {
+	report := &DiffReport{Base: opts.Base, Changed: []*ChangedSymbol{}, Callers: []*storage.NodeWithDepth{}}
+
+	// The side of each file that matches the graph
+	type side struct {
+		path  string
+		lines []LineRange
+	}
+	var sides []side
+	var paths []string
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path(), ".go") {
+			continue
+		}
+		report.Files++
+		s := side{f.OldPath, f.Old}
+		if f.OldPath == "" || (f.NewPath != "" && opts.Current != nil && opts.Current(f.NewPath)) {
+			s = side{f.NewPath, f.New}
+		}
+		if s.path != "" && len(s.lines) > 0 {
+			sides = append(sides, s)
+			paths = append(paths, s.path)
+		}
+	}
+
+	nodes, err := a.db.GetNodesInFiles(paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes in files: %w", err)
+	}
+	byFile := make(map[string][]*graph.Node)
+	for _, n := range nodes {
+		if isDiffSymbol(n) {
+			byFile[n.File] = append(byFile[n.File], n)
+		}
+	}
+	ends := symbolEnds(nodes)
+
+	changed := make(map[int64]*ChangedSymbol)
+	for _, s := range sides {
+		var unmapped []LineRange
+		for _, r := range s.lines {
+			hit := false
+			for _, n := range byFile[s.path] {
+				end := ends[n.ID]
+				if r.Start > end || r.End < n.Line {
+					continue
+				}
+				hit = true
+				cs, ok := changed[n.ID]
+				if !ok {
+					cs = &ChangedSymbol{Node: n}
+					changed[n.ID] = cs
+					report.Changed = append(report.Changed, cs)
+				}
+				cs.Lines = append(cs.Lines, LineRange{max(r.Start, n.Line), min(r.End, end)})
+			}
+			if !hit {
+				unmapped = append(unmapped, r)
+			}
+		}
+		if len(unmapped) > 0 {
+			report.Unmapped = append(report.Unmapped, &FileLines{File: s.path, Lines: unmapped})
+		}
+	}
+
+	if err := a.addDiffCallers(report, changed, opts.UpstreamDepth); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// symbolEnds returns the last line of each node. Graphs built before end lines
+// were recorded have none; a function then extends to the line before the
+// next declaration in its file, and a var or const covers its first line.
+func symbolEnds(nodes []*graph.Node) map[int64]int {
+	ends := make(map[int64]int, len(nodes))
+	for i, n := range nodes {
+		end := n.EndLine
+		if end == 0 && n.Kind == graph.NodeKindFunc {
+			end = math.MaxInt
+			for _, next := range nodes[i+1:] {
+				if next.File != n.File {
+					break
+				}
+				if next.Line > n.Line && !strings.Contains(next.Name, "$") {
+					end = next.Line - 1
+					break
+				}
+			}
+		}
+		ends[n.ID] = max(end, n.Line)
+	}
+	return ends
+}
+
+// isDiffSymbol reports whether changes to a node are attributed to it.
+// Closures are covered by the function they are declared in.
+func isDiffSymbol(n *graph.Node) bool {
+	switch n.Kind {
+	case graph.NodeKindFunc:
+		return !strings.Contains(n.Name, "$")
+	case graph.NodeKindVar, graph.NodeKindConst:
+		return true
+	}
+	return false
+}
+
+// addDiffCallers collects the callers of the changed symbols, rates each symbol
+// and the diff as a whole. Functions referencing a changed var/const count as
+// its direct callers.
+func (a *Analyzer) addDiffCallers(report *DiffReport, changed map[int64]*ChangedSymbol, depth int) error {
+	callers := make(map[int64]*storage.NodeWithDepth)
+	direct := make(map[int64]bool)
+	add := func(n *graph.Node, d int) {
+		if _, self := changed[n.ID]; self {
+			return
+		}
+		if d == 1 {
+			direct[n.ID] = true
+		}
+		if c, ok := callers[n.ID]; !ok || d < c.Depth {
+			callers[n.ID] = &storage.NodeWithDepth{Node: n, Depth: d}
+		}
+	}
+
+	report.RiskLevel = "low"
+	for _, cs := range report.Changed {
+		if cs.Node.Kind == graph.NodeKindFunc {
+			risk, err := a.db.GetRiskScore(cs.Node.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get risk score: %w", err)
+			}
+			cs.DirectCallers, cs.TotalCallers, cs.RiskLevel = risk.DirectCallers, risk.TotalCallers, risk.RiskLevel
+
+			up, err := a.db.GetUpstreamCallersWithDepth(cs.Node.ID, depth)
+			if err != nil {
+				return fmt.Errorf("failed to get upstream callers: %w", err)
+			}
+			for _, c := range up {
+				add(c.Node, c.Depth)
+			}
+		} else {
+			refs, err := a.db.GetReferencingFunctions(cs.Node.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get referencing functions: %w", err)
+			}
+			own := make(map[int64]bool)
+			for _, ref := range refs {
+				own[ref.ID] = true
+				add(ref, 1)
+				if depth == 1 {
+					continue
+				}
+				up, err := a.db.GetUpstreamCallersWithDepth(ref.ID, depth-1)
+				if err != nil {
+					return fmt.Errorf("failed to get upstream callers: %w", err)
+				}
+				for _, c := range up {
+					own[c.ID] = true
+					add(c.Node, c.Depth+1)
+				}
+			}
+			cs.DirectCallers, cs.TotalCallers = len(refs), len(own)
+			cs.RiskLevel = storage.CalculateRiskLevel(cs.DirectCallers, cs.TotalCallers)
+		}
+		report.RiskLevel = higherRisk(report.RiskLevel, cs.RiskLevel)
+	}
+
+	for _, c := range callers {
+		report.Callers = append(report.Callers, c)
+	}
+	sort.Slice(report.Callers, func(i, j int) bool {
+		if report.Callers[i].Depth != report.Callers[j].Depth {
+			return report.Callers[i].Depth < report.Callers[j].Depth
+		}
+		return report.Callers[i].Name < report.Callers[j].Name
+	})
+	sort.SliceStable(report.Changed, func(i, j int) bool {
+		return riskOrder[report.Changed[i].RiskLevel] > riskOrder[report.Changed[j].RiskLevel]
+	})
+
+	// Many symbols with few callers each can still add up
+	overall := storage.CalculateRiskLevel(len(direct), len(callers))
+	report.RiskLevel = higherRisk(report.RiskLevel, overall)
+	return nil
+}
+
+var riskOrder = map[string]int{"low": 0, "medium": 1, "high": 2, "critical": 3}
+
+func higherRisk(a, b string) string {
+	if riskOrder[b] > riskOrder[a] {
+		return b
+	}
+	return a
+}
+
+// GroupByOwner assigns the affected callers to their CODEOWNERS owners.
+// It does nothing if rs is nil.
+func (r *DiffReport) GroupByOwner(rs *owners.Ruleset) {
+	if rs == nil || len(r.Callers) == 0 {
+		return
+	}
+	nodes := make([]*graph.Node, len(r.Callers))
+	for i, c := range r.Callers {
+		nodes[i] = c.Node
+	}
+	r.OwnerGroups = rs.GroupByOwner(nodes)
+}
+
+// maxDiffCallers is the number of callers listed per depth in text output
+const maxDiffCallers = 10
+
+// FormatText formats the report for the terminal
+func (r *DiffReport) FormatText() string {
+	var sb strings.Builder
+	base := r.Base
+	if base == "" {
+		base = "补丁"
+	}
+	sb.WriteString(fmt.Sprintf("📝 变更 (%s): %d 个文件，%d 个符号，%d 个受影响的调用者\n", base, r.Files, len(r.Changed), len(r.Callers)))
+	sb.WriteString(fmt.Sprintf("   整体风险: %s %s\n\n", riskIcon(r.RiskLevel), r.RiskLevel))
+
+	if len(r.Changed) == 0 {
+		sb.WriteString("没有变更落在已分析的函数、变量或常量内\n")
+	} else {
+		sb.WriteString("✏️ 变更的符号\n")
+		for i, cs := range r.Changed {
+			prefix := "├──"
+			if i == len(r.Changed)-1 {
+				prefix = "└──"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s %-8s %s  %s:%s  调用者: 直接 %d / 总 %d\n",
+				prefix, riskIcon(cs.RiskLevel), cs.RiskLevel, shortName(cs.Node.Name),
+				shortPath(cs.Node.File), formatRanges(cs.Lines), cs.DirectCallers, cs.TotalCallers))
+		}
+	}
+
+	if len(r.Callers) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⬆️ 受影响的调用者 (共 %d 个)\n", len(r.Callers)))
+		byDepth := make(map[int][]*storage.NodeWithDepth)
+		var depths []int
+		for _, c := range r.Callers {
+			if _, ok := byDepth[c.Depth]; !ok {
+				depths = append(depths, c.Depth)
+			}
+			byDepth[c.Depth] = append(byDepth[c.Depth], c)
+		}
+		for _, d := range depths {
+			var names []string
+			for _, c := range byDepth[d] {
+				if len(names) == maxDiffCallers {
+					break
+				}
+				names = append(names, shortName(c.Name))
+			}
+			line := strings.Join(names, ", ")
+			if len(byDepth[d]) > maxDiffCallers {
+				line += fmt.Sprintf(" 等 %d 个", len(byDepth[d]))
+			}
+			sb.WriteString(fmt.Sprintf("  深度 %d: %s\n", d, line))
+		}
+	}
+
+	if len(r.Unmapped) > 0 {
+		sb.WriteString("\n📄 未归属到符号的变更 (import、类型、注释、未记录的未导出声明或未分析的新代码)\n")
+		for _, f := range r.Unmapped {
+			sb.WriteString(fmt.Sprintf("  %s:%s\n", shortPath(f.File), formatRanges(f.Lines)))
+		}
+	}
+
+	if len(r.OwnerGroups) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(FormatOwnerGroups("👥 需要通知的负责人", r.OwnerGroups))
+	}
+	return sb.String()
+}
+
+// FormatMarkdown formats the report as markdown, e.g. for a PR comment
+func (r *DiffReport) FormatMarkdown() string {
+	var sb strings.Builder
+	sb.WriteString("## 变更影响分析\n\n")
+	if r.Base != "" {
+		sb.WriteString(fmt.Sprintf("**对比:** %s\n\n", r.Base))
+	}
+	sb.WriteString(fmt.Sprintf("**整体风险:** %s %s\n\n", riskIcon(r.RiskLevel), r.RiskLevel))
+	sb.WriteString(fmt.Sprintf("**范围:** %d 个文件，%d 个符号，%d 个受影响的调用者\n\n", r.Files, len(r.Changed), len(r.Callers)))
+
+	if len(r.Changed) > 0 {
+		sb.WriteString("### 变更的符号\n\n")
+		sb.WriteString("| 风险 | 符号 | 位置 | 直接调用者 | 总调用者 |\n")
+		sb.WriteString("|------|------|------|------------|----------|\n")
+		for _, cs := range r.Changed {
+			sb.WriteString(fmt.Sprintf("| %s %s | `%s` | %s:%s | %d | %d |\n",
+				riskIcon(cs.RiskLevel), cs.RiskLevel, shortName(cs.Node.Name),
+				cs.Node.File, formatRanges(cs.Lines), cs.DirectCallers, cs.TotalCallers))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.Callers) > 0 {
+		sb.WriteString("### 受影响的调用者\n\n")
+		sb.WriteString("| 深度 | 函数 | 文件 |\n")
+		sb.WriteString("|------|------|------|\n")
+		for _, c := range r.Callers {
+			sb.WriteString(fmt.Sprintf("| %d | `%s` | %s:%d |\n", c.Depth, shortName(c.Name), c.File, c.Line))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.Unmapped) > 0 {
+		sb.WriteString("### 未归属到符号的变更\n\n")
+		for _, f := range r.Unmapped {
+			sb.WriteString(fmt.Sprintf("- %s:%s\n", f.File, formatRanges(f.Lines)))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.OwnerGroups) > 0 {
+		sb.WriteString("### 需要通知的负责人\n\n")
+		for _, g := range r.OwnerGroups {
+			sb.WriteString(fmt.Sprintf("- %s: %d 个函数\n", owners.OwnerLabel(g.Owner), len(g.Nodes)))
+		}
+	}
+	return sb.String()
+}
+
+func formatRanges(ranges []LineRange) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func riskIcon(level string) string {
+	switch level {
+	case "critical":
+		return "🔴"
+	case "high":
+		return "🟠"
+	case "medium":
+		return "🟡"
+	default:
+		return "🟢"
+	}
+}
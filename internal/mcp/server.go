@@ -7,22 +7,26 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/zheng/crag/internal/analyzer"
 	"github.com/zheng/crag/internal/display"
 	"github.com/zheng/crag/internal/graph"
 	"github.com/zheng/crag/internal/impact"
+	"github.com/zheng/crag/internal/owners"
+	"github.com/zheng/crag/internal/query"
 	"github.com/zheng/crag/internal/storage"
 )
 
 // Server implements the MCP protocol for crag
 type Server struct {
-	db     *storage.DB
+	db     storage.Store
 	input  io.Reader
 	output io.Writer
 }
 
 // NewServer creates a new MCP server
-func NewServer(db *storage.DB) *Server {
+func NewServer(db storage.Store) *Server {
 	return &Server{
 		db:     db,
 		input:  os.Stdin,
@@ -205,8 +209,9 @@ func (s *Server) handleToolsList(req *Request) {
 - 间接调用者：可能受影响的上游函数
 - 下游依赖：该函数调用的其他函数
 使用场景：修改函数签名、重构函数、删除函数前
+如果项目有 CODEOWNERS，还会按负责人分组列出调用者，便于通知相关团队
 
-⚠️ 如果函数名匹配到多个结果，会返回候选列表，请根据上下文选择正确的函数，使用候选列表中的完整函数名重新调用此工具。
+⚠️ 如果函数名匹配到多个结果，会返回候选列表，请根据上下文选择正确的函数，使用候选列表中的完整函数名或 #ID 重新调用此工具（#ID 在重新分析后保持不变）。
 
 📌 索引维护：当你完成代码修改（新增/修改/删除了代码文件）后，必须在后台运行 crag analyze -i -r 更新索引，否则后续查询结果可能不准确。如果改完代码后需要立即查询，则应前台阻塞运行确保索引就绪。`,
 			InputSchema: InputSchema{
@@ -214,7 +219,7 @@ func (s *Server) handleToolsList(req *Request) {
 				Properties: map[string]Property{
 					"function": {
 						Type:        "string",
-						Description: "函数名，支持短名称如 'HandleRequest' 或完整名 'pkg/service.HandleRequest'",
+						Description: "函数名，支持短名称如 'HandleRequest' 或完整名 'pkg/service.HandleRequest'，或节点 ID 如 '#123456'",
 					},
 					"limit": {
 						Type:        "number",
@@ -233,13 +238,13 @@ func (s *Server) handleToolsList(req *Request) {
 - "修改这个函数会影响哪些地方？"
 - 理解函数的使用方式和入口点
 
-⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名重新调用。`,
+⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名或候选的 #ID 重新调用（#ID 在重新分析后保持不变）。`,
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"function": {
 						Type:        "string",
-						Description: "函数名，支持短名称如 'Query' 或 'db.Query'",
+						Description: "函数名，支持短名称如 'Query' 或 'db.Query'，或节点 ID 如 '#123456'",
 					},
 					"depth": {
 						Type:        "number",
@@ -262,13 +267,13 @@ func (s *Server) handleToolsList(req *Request) {
 - "这个函数的依赖是什么？"
 - 理解函数的实现细节和依赖关系
 
-⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名重新调用。`,
+⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名或候选的 #ID 重新调用（#ID 在重新分析后保持不变）。`,
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"function": {
 						Type:        "string",
-						Description: "函数名，支持短名称",
+						Description: "函数名，支持短名称或节点 ID 如 '#123456'",
 					},
 					"depth": {
 						Type:        "number",
@@ -285,18 +290,20 @@ func (s *Server) handleToolsList(req *Request) {
 		},
 		{
 			Name: "search",
-			Description: `搜索项目中的函数、变量、常量等。支持模糊匹配，短名称优先。
+			Description: `全文搜索项目中的函数、变量、常量等，匹配名称、文档注释、签名和包路径，按相关度排序。
 使用场景：
 - 不确定函数/变量完整名称时
 - 查找包含某关键字的所有函数和变量
+- 按文档注释或签名查找功能相关的代码
 - 探索项目结构
-示例：搜索 'Handler' 会找到所有包含 Handler 的函数和变量`,
+语法：多个词同时匹配；驼峰名按单词匹配（FindNodes 可找到 FindNodesByPattern）；Handl* 前缀匹配；"error handling" 短语；doc:/sig:/pkg: 限定字段
+示例：'Handler'、'doc:retry sig:context'、'pkg:storage Node*'`,
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"pattern": {
 						Type:        "string",
-						Description: "搜索关键字，如 'Handler'、'Query'、'Process'",
+						Description: "搜索条件，如 'Handler'、'parse config'、'doc:缓存'、'sig:context.Context'",
 					},
 					"limit": {
 						Type:        "number",
@@ -342,7 +349,7 @@ func (s *Server) handleToolsList(req *Request) {
 - 生成文档或报告时
 - 解释复杂的调用链
 
-⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名重新调用。`,
+⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名或候选的 #ID 重新调用（#ID 在重新分析后保持不变）。`,
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -398,7 +405,7 @@ func (s *Server) handleToolsList(req *Request) {
 - 了解哪些函数是"热点"代码
 - 重构时确定优先级
 
-⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名重新调用。`,
+⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名或候选的 #ID 重新调用（#ID 在重新分析后保持不变）。`,
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -414,6 +421,73 @@ func (s *Server) handleToolsList(req *Request) {
 				},
 			},
 		},
+		{
+			Name: "annotate",
+			Description: `为函数、类型、变量等节点添加注释，记录设计决策、约束和踩过的坑。
+注释按节点完整名称保存，重新分析后仍然保留，并会显示在 impact 等工具的输出中，后续会话无需重新摸索。
+使用场景：弄清某段代码为何这样写、发现隐含约束、做出设计决策后
+
+⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名或候选的 #ID 重新调用（#ID 在重新分析后保持不变）。`,
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"function": {
+						Type:        "string",
+						Description: "节点名称，支持短名称、完整名或节点 ID 如 '#123456'",
+					},
+					"text": {
+						Type:        "string",
+						Description: "注释内容",
+					},
+				},
+				Required: []string{"function", "text"},
+			},
+		},
+		{
+			Name: "get_notes",
+			Description: `查询节点上的注释（之前会话或用户记录的设计决策、约束等）。
+修改不熟悉的代码前建议先查看。
+
+⚠️ 如果函数名匹配到多个结果，会返回候选列表，请使用完整函数名或候选的 #ID 重新调用（#ID 在重新分析后保持不变）。`,
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"function": {
+						Type:        "string",
+						Description: "节点名称，留空则列出所有注释",
+					},
+				},
+			},
+		},
+		{
+			Name: "query",
+			Description: `用查询语言一次回答组合问题，代替多次调用 upstream/downstream/search/implements 再自行取交集。
+表达式是节点集合的运算：
+- 节点：storage.Save、"(*pkg.T).M"（含特殊字符需加引号）、#123
+- 过滤：kind=func、package~"handler"、name=Save、file~"^cmd/"、module=...（= != ~ !~，~ 为正则）
+- 集合：all、tests（_test.go 中的节点；分析不加载测试文件，目前为空）、exported
+- 步骤：callers(x)、callees(x)，callers*(x) 任意深度，callees{1,3}(x) 深度范围；implements(x) 类型实现的接口，implementers(x) 接口的实现类型；references(x) 函数引用的变量常量，referrers(x) 引用变量常量的函数；fields(x) 结构体字段
+- 运算：a & b 交集，a | b 并集，a - b 差集（& 优先）；limit(x, n)
+示例：
+- callers*(storage.Save) & package~"handler" - tests   哪些 handler 间接调用了 Save（不含测试）
+- implementers(io.Reader) & exported
+- referrers(config.Timeout) - callers*(main)           引用了配置但不在 main 调用链上的函数`,
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"expr": {
+						Type:        "string",
+						Description: "查询表达式",
+					},
+					"limit": {
+						Type:        "number",
+						Description: "最多返回数量，默认 50",
+						Default:     50,
+					},
+				},
+				Required: []string{"expr"},
+			},
+		},
 	}
 
 	s.sendResult(req.ID, map[string]interface{}{"tools": tools})
@@ -446,6 +520,12 @@ func (s *Server) handleToolsCall(req *Request) {
 		result, isError = s.toolImplements(params.Arguments)
 	case "risk":
 		result, isError = s.toolRisk(params.Arguments)
+	case "annotate":
+		result, isError = s.toolAnnotate(params.Arguments)
+	case "get_notes":
+		result, isError = s.toolGetNotes(params.Arguments)
+	case "query":
+		result, isError = s.toolQuery(params.Arguments)
 	default:
 		result = fmt.Sprintf("Unknown tool: %s", params.Name)
 		isError = true
@@ -462,7 +542,7 @@ func (s *Server) handleResourcesList(req *Request) {
 		{
 			URI:         "crag://summary",
 			Name:        "项目概览",
-			Description: "项目代码统计概览：包、函数、变量、常量、接口、结构体数量及按包分布",
+			Description: "项目代码统计概览：分析时间/git 提交/版本等元数据 (用于判断是否过期)，包、函数、变量、常量、接口、结构体数量及按包分布",
 			MimeType:    "text/markdown",
 		},
 	}
@@ -524,6 +604,11 @@ func (s *Server) buildSummary() (string, error) {
 	sb.WriteString("# 项目概览\n\n")
 	sb.WriteString("> 💡 使用 crag 工具后记得给简短评价，帮助迭代改进这个工具\n\n")
 
+	// Analysis metadata, so the data can be judged for staleness
+	if meta, err := s.db.GetMetadata(); err == nil && meta != nil {
+		sb.WriteString(formatMetadata(meta))
+	}
+
 	// Stats table
 	sb.WriteString("## 统计\n\n")
 	sb.WriteString("| 类型 | 数量 |\n")
@@ -533,6 +618,7 @@ func (s *Server) buildSummary() (string, error) {
 	sb.WriteString(fmt.Sprintf("| 接口 | %d |\n", kindCounts["interface"]))
 	sb.WriteString(fmt.Sprintf("| 变量 | %d |\n", kindCounts["var"]))
 	sb.WriteString(fmt.Sprintf("| 常量 | %d |\n", kindCounts["const"]))
+	sb.WriteString(fmt.Sprintf("| 枚举 | %d |\n", kindCounts["enum"]))
 	sb.WriteString(fmt.Sprintf("| 调用/引用边 | %d |\n", edgeCount))
 	sb.WriteString("\n")
 
@@ -550,10 +636,10 @@ func (s *Server) buildSummary() (string, error) {
 	// Top risky functions
 	if len(risks) > 0 {
 		sb.WriteString("## 高风险函数 (Top 5)\n\n")
-		sb.WriteString("| 风险 | 函数 | 直接调用者 |\n")
-		sb.WriteString("|------|------|------------|\n")
+		sb.WriteString("| 风险 | 函数 | 直接调用者 | 总调用者 |\n")
+		sb.WriteString("|------|------|------------|----------|\n")
 		for _, r := range risks {
-			sb.WriteString(fmt.Sprintf("| %s %s | %s | %d |\n", getRiskIcon(r.RiskLevel), r.RiskLevel, display.ShortFuncName(r.Node.Name), r.DirectCallers))
+			sb.WriteString(fmt.Sprintf("| %s %s | %s | %d | %d |\n", getRiskIcon(r.RiskLevel), r.RiskLevel, display.ShortFuncName(r.Node.Name), r.DirectCallers, r.TotalCallers))
 		}
 		sb.WriteString("\n")
 	}
@@ -561,6 +647,51 @@ func (s *Server) buildSummary() (string, error) {
 	return sb.String(), nil
 }
 
+// formatMetadata renders how and when the graph was built, with staleness warnings
+func formatMetadata(meta *graph.Metadata) string {
+	var sb strings.Builder
+
+	commit := meta.GitCommit
+	if commit == "" {
+		commit = "-"
+	} else if meta.GitDirty {
+		commit += " (有未提交的变更)"
+	}
+	flags := meta.Flags
+	if flags == "" {
+		flags = "-"
+	}
+
+	sb.WriteString("## 分析信息\n\n")
+	sb.WriteString("| 项 | 值 |\n")
+	sb.WriteString("|----|----|\n")
+	sb.WriteString(fmt.Sprintf("| 项目路径 | %s |\n", meta.ProjectRoot))
+	sb.WriteString(fmt.Sprintf("| 模块 | %s |\n", meta.ModulePath))
+	sb.WriteString(fmt.Sprintf("| git 提交 | %s |\n", commit))
+	sb.WriteString(fmt.Sprintf("| 分析时间 | %s |\n", meta.AnalyzedAt.Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("| 增量分析 | %v |\n", meta.Incremental))
+	if meta.Snapshot != "" {
+		sb.WriteString(fmt.Sprintf("| 快照 | %s |\n", meta.Snapshot))
+	}
+	sb.WriteString(fmt.Sprintf("| 耗时 | %dms |\n", meta.DurationMs))
+	sb.WriteString(fmt.Sprintf("| 包数量 | %d |\n", meta.PackageCount))
+	sb.WriteString(fmt.Sprintf("| Go 版本 | %s |\n", meta.GoVersion))
+	sb.WriteString(fmt.Sprintf("| crag 版本 | %s |\n", meta.CragVersion))
+	sb.WriteString(fmt.Sprintf("| 调用图算法 | %s |\n", meta.Algorithm))
+	sb.WriteString(fmt.Sprintf("| 分析参数 | %s |\n", flags))
+	sb.WriteString("\n")
+
+	if warnings := analyzer.CheckStale(meta); len(warnings) > 0 {
+		sb.WriteString("> ⚠️ 图谱可能已过期，结果可能与当前代码不符：\n")
+		for _, w := range warnings {
+			sb.WriteString(fmt.Sprintf("> - %s\n", w))
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
 // shortPkgName extracts the last 2 segments of a package path
 func shortPkgName(pkg string) string {
 	parts := strings.Split(pkg, "/")
@@ -576,9 +707,9 @@ func shortPkgName(pkg string) string {
 func (s *Server) formatAmbiguousResult(funcName string, nodes []*graph.Node) string {
 	result := fmt.Sprintf("函数名 '%s' 匹配到 %d 个结果，请使用完整函数名重新调用：\n\n", funcName, len(nodes))
 	for i, n := range nodes {
-		result += fmt.Sprintf("  [%d] %s\n      %s:%d\n", i+1, n.Name, n.File, n.Line)
+		result += fmt.Sprintf("  [%d] %s\n      %s:%d  #%d\n", i+1, n.Name, n.File, n.Line, n.ID)
 	}
-	result += "\n请使用上述完整函数名（如第一列所示）重新调用此工具。"
+	result += "\n请使用上述完整函数名（如第一列所示）或 #ID 重新调用此工具。"
 	return result
 }
 
@@ -603,7 +734,19 @@ func (s *Server) toolImpact(args map[string]interface{}) (string, bool) {
 		return fmt.Sprintf("错误：%v", err), true
 	}
 
-	return s.formatImpactAsTree(report, upstreamDepth, downstreamDepth), false
+	result := s.formatImpactAsTree(report, upstreamDepth, downstreamDepth)
+	report.GroupByOwner(s.loadOwners())
+	if owners := report.FormatOwners(); owners != "" {
+		result += "\n" + owners
+	}
+	return result, false
+}
+
+// loadOwners loads the CODEOWNERS file of the analyzed project, or returns nil
+func (s *Server) loadOwners() *owners.Ruleset {
+	meta, _ := s.db.GetMetadata()
+	rs, _ := owners.LoadForGraph(meta)
+	return rs
 }
 
 func (s *Server) formatImpactAsTree(report *impact.ImpactReport, upstreamDepth, downstreamDepth int) string {
@@ -618,11 +761,17 @@ func (s *Server) formatImpactAsTree(report *impact.ImpactReport, upstreamDepth,
 			kindLabel = "常量"
 		}
 		result += fmt.Sprintf("📍 当前%s\n", kindLabel)
-		result += fmt.Sprintf("%s  %s:%d\n", display.ShortFuncName(report.Target.Name), report.Target.File, report.Target.Line)
+		result += fmt.Sprintf("%s  %s:%d  #%d\n", display.ShortFuncName(report.Target.Name), report.Target.File, report.Target.Line, report.Target.ID)
 		if report.Target.Signature != "" {
 			result += fmt.Sprintf("   类型: %s\n", report.Target.Signature)
 		}
+		if report.Target.Value != "" {
+			result += fmt.Sprintf("   值: %s\n", report.Target.Value)
+		}
 		result += "\n"
+		if notes := report.FormatNotes(); notes != "" {
+			result += notes + "\n"
+		}
 
 		if len(report.DirectCallers) > 0 {
 			result += fmt.Sprintf("⬆️ 引用此%s的函数 (共 %d 个)\n", kindLabel, len(report.DirectCallers))
@@ -637,6 +786,9 @@ func (s *Server) formatImpactAsTree(report *impact.ImpactReport, upstreamDepth,
 			result += fmt.Sprintf("⬆️ 引用此%s的函数\n", kindLabel)
 			result += "└── (无)\n"
 		}
+		if derived := report.FormatDerived(); derived != "" {
+			result += "\n" + derived
+		}
 		return result
 	}
 
@@ -656,11 +808,14 @@ func (s *Server) formatImpactAsTree(report *impact.ImpactReport, upstreamDepth,
 		targetMaxDepth = downstreamMaxDepth
 	}
 	targetPadding := maxWidth + targetMaxDepth*4
-	result += fmt.Sprintf("%-*s  %s:%d\n", targetPadding, display.ShortFuncName(report.Target.Name), report.Target.File, report.Target.Line)
+	result += fmt.Sprintf("%-*s  %s:%d  #%d\n", targetPadding, display.ShortFuncName(report.Target.Name), report.Target.File, report.Target.Line, report.Target.ID)
 	if report.Target.Signature != "" {
 		result += fmt.Sprintf("   %s\n", display.ShortSignature(report.Target.Signature))
 	}
 	result += "\n"
+	if notes := report.FormatNotes(); notes != "" {
+		result += notes + "\n"
+	}
 
 	if len(upstreamTree) > 0 {
 		result += fmt.Sprintf("⬆️ 调用者 (深度 %d)\n", upstreamDepth)
@@ -676,6 +831,15 @@ func (s *Server) formatImpactAsTree(report *impact.ImpactReport, upstreamDepth,
 	} else {
 		result += "⬇️ 被调用\n└── (无)\n"
 	}
+	if impls := report.FormatImplementations(); impls != "" {
+		result += "\n" + impls
+	}
+	if asserts := report.FormatTypeAssertions(); asserts != "" {
+		result += "\n" + asserts
+	}
+	if derived := report.FormatDerived(); derived != "" {
+		result += "\n" + derived
+	}
 
 	return result
 }
@@ -715,7 +879,7 @@ func (s *Server) toolUpstream(args map[string]interface{}) (string, bool) {
 
 	targetPadding := maxWidth + maxDepth*4
 	result := "📍 当前函数\n"
-	result += fmt.Sprintf("%-*s  %s:%d\n\n", targetPadding, display.ShortFuncName(node.Name), node.File, node.Line)
+	result += fmt.Sprintf("%-*s  %s:%d  #%d\n\n", targetPadding, display.ShortFuncName(node.Name), node.File, node.Line, node.ID)
 
 	if len(callTree) > 0 {
 		result += fmt.Sprintf("⬆️ 调用者 (深度 %d)\n", depth)
@@ -762,7 +926,7 @@ func (s *Server) toolDownstream(args map[string]interface{}) (string, bool) {
 
 	targetPadding := maxWidth + maxDepth*4
 	result := "📍 当前函数\n"
-	result += fmt.Sprintf("%-*s  %s:%d\n\n", targetPadding, display.ShortFuncName(node.Name), node.File, node.Line)
+	result += fmt.Sprintf("%-*s  %s:%d  #%d\n\n", targetPadding, display.ShortFuncName(node.Name), node.File, node.Line, node.ID)
 
 	if len(callTree) > 0 {
 		result += fmt.Sprintf("⬇️ 被调用 (深度 %d)\n", depth)
@@ -785,7 +949,7 @@ func (s *Server) toolSearch(args map[string]interface{}) (string, bool) {
 		limit = int(l)
 	}
 
-	nodes, err := s.db.FindNodesByPattern(pattern)
+	nodes, err := s.db.SearchNodes(pattern, 0)
 	if err != nil {
 		return fmt.Sprintf("错误：%v", err), true
 	}
@@ -806,7 +970,7 @@ func (s *Server) toolSearch(args map[string]interface{}) (string, bool) {
 	result += ":\n\n"
 
 	for _, n := range nodes {
-		result += fmt.Sprintf("  [%s] %s\n    %s:%d\n", n.Kind, display.ShortFuncName(n.Name), n.File, n.Line)
+		result += fmt.Sprintf("  [%s] %s\n    %s:%d  #%d\n", n.Kind, display.ShortFuncName(n.Name), n.File, n.Line, n.ID)
 	}
 
 	return result, false
@@ -1015,9 +1179,9 @@ func (s *Server) toolRisk(args map[string]interface{}) (string, bool) {
 		for _, r := range risks {
 			riskIcon := getRiskIcon(r.RiskLevel)
 			result += fmt.Sprintf("%s **%s** - %s\n", riskIcon, r.RiskLevel, display.ShortFuncName(r.Node.Name))
-			result += fmt.Sprintf("   调用者: %d | %s:%d\n\n", r.DirectCallers, r.Node.File, r.Node.Line)
+			result += fmt.Sprintf("   调用者: 直接 %d / 总 %d | %s:%d\n\n", r.DirectCallers, r.TotalCallers, r.Node.File, r.Node.Line)
 		}
-		result += "风险等级: 🔴critical(>=50) 🟠high(>=20) 🟡medium(>=5) 🟢low\n"
+		result += "风险等级: 🔴critical(直接>=50 或总>=200) 🟠high(>=20/100) 🟡medium(>=5/30) 🟢low\n"
 		return result, false
 	}
 
@@ -1043,6 +1207,7 @@ func (s *Server) toolRisk(args map[string]interface{}) (string, bool) {
 	riskIcon := getRiskIcon(risk.RiskLevel)
 	result := fmt.Sprintf("## 变更风险分析: %s\n\n", display.ShortFuncName(risk.Node.Name))
 	result += fmt.Sprintf("**位置:** %s:%d\n", risk.Node.File, risk.Node.Line)
+	result += fmt.Sprintf("**ID:** #%d\n", risk.Node.ID)
 	if risk.Node.Signature != "" {
 		result += fmt.Sprintf("**签名:** `%s`\n", risk.Node.Signature)
 	}
@@ -1050,6 +1215,9 @@ func (s *Server) toolRisk(args map[string]interface{}) (string, bool) {
 
 	result += fmt.Sprintf("### 风险等级: %s %s\n\n", riskIcon, risk.RiskLevel)
 	result += fmt.Sprintf("直接调用者: %d\n", risk.DirectCallers)
+	if risk.TotalCallers > 0 {
+		result += fmt.Sprintf("总调用者: %d (最长调用链 %d 层)\n", risk.TotalCallers, risk.MaxDepth)
+	}
 
 	result += "\n**建议:**\n"
 	switch risk.RiskLevel {
@@ -1309,3 +1477,132 @@ func (s *Server) send(resp Response) {
 	data, _ := json.Marshal(resp)
 	fmt.Fprintln(s.output, string(data))
 }
+
+// findNoteTarget resolves the node a note refers to. It returns a message
+// instead of a node when the name is unknown or ambiguous.
+func (s *Server) findNoteTarget(funcName string) (*graph.Node, string, bool) {
+	if node, err := s.db.GetNodeByName(funcName); err == nil {
+		return node, "", false
+	}
+	nodes, err := s.db.FindNodesByPattern(funcName)
+	if err != nil {
+		return nil, fmt.Sprintf("错误：%v", err), true
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Sprintf("未找到节点：%s", funcName), true
+	}
+	if len(nodes) > 1 {
+		return nil, s.formatAmbiguousResult(funcName, nodes), false
+	}
+	return nodes[0], "", false
+}
+
+func (s *Server) toolAnnotate(args map[string]interface{}) (string, bool) {
+	funcName, _ := args["function"].(string)
+	text, _ := args["text"].(string)
+	if funcName == "" || strings.TrimSpace(text) == "" {
+		return "错误：需要提供节点名称和注释内容", true
+	}
+
+	node, msg, isError := s.findNoteTarget(funcName)
+	if node == nil {
+		return msg, isError
+	}
+
+	note, err := s.db.AddNote(node.Name, strings.TrimSpace(text))
+	if err != nil {
+		return fmt.Sprintf("错误：%v", err), true
+	}
+	return fmt.Sprintf("✅ 已为 %s 添加注释 (#%d)", display.ShortFuncName(node.Name), note.ID), false
+}
+
+func (s *Server) toolGetNotes(args map[string]interface{}) (string, bool) {
+	funcName, _ := args["function"].(string)
+	if funcName != "" {
+		node, msg, isError := s.findNoteTarget(funcName)
+		if node == nil {
+			return msg, isError
+		}
+		notes, err := s.db.GetNotes(node.Name)
+		if err != nil {
+			return fmt.Sprintf("错误：%v", err), true
+		}
+		if len(notes) == 0 {
+			return fmt.Sprintf("%s 没有注释", display.ShortFuncName(node.Name)), false
+		}
+		return fmt.Sprintf("%s  %s:%d\n%s", display.ShortFuncName(node.Name), node.File, node.Line, impact.FormatNotes(notes)), false
+	}
+
+	notes, err := s.db.GetAllNotes()
+	if err != nil {
+		return fmt.Sprintf("错误：%v", err), true
+	}
+	if len(notes) == 0 {
+		return "项目中还没有注释", false
+	}
+
+	var result string
+	for i := 0; i < len(notes); {
+		j := i
+		for j < len(notes) && notes[j].NodeName == notes[i].NodeName {
+			j++
+		}
+		result += display.ShortFuncName(notes[i].NodeName)
+		if _, err := s.db.GetNodeByName(notes[i].NodeName); err != nil {
+			result += "  (节点已不存在)"
+		}
+		result += "\n" + impact.FormatNotes(notes[i:j]) + "\n"
+		i = j
+	}
+	return strings.TrimSuffix(result, "\n"), false
+}
+
+func (s *Server) toolQuery(args map[string]interface{}) (string, bool) {
+	expr, ok := args["expr"].(string)
+	if !ok || expr == "" {
+		return "错误：需要提供查询表达式", true
+	}
+
+	limit := 50
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	parsed, err := query.Parse(expr)
+	if err != nil {
+		return fmt.Sprintf("查询语法错误：%v", err), true
+	}
+	g, err := query.Load(s.db)
+	if err != nil {
+		return fmt.Sprintf("错误：%v", err), true
+	}
+	nodes, err := g.Eval(parsed)
+	if err != nil {
+		return fmt.Sprintf("错误：%v", err), true
+	}
+	var notices string
+	for _, notice := range g.Notices(parsed) {
+		notices += "注意：" + notice + "\n"
+	}
+
+	if len(nodes) == 0 {
+		return notices + fmt.Sprintf("没有匹配 %s 的节点", expr), false
+	}
+
+	total := len(nodes)
+	if len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+
+	result := notices + fmt.Sprintf("共 %d 个节点", total)
+	if total > limit {
+		result += fmt.Sprintf("（显示前 %d 个）", limit)
+	}
+	result += ":\n\n"
+
+	for _, n := range nodes {
+		result += fmt.Sprintf("  [%s] %s\n    %s:%d  #%d\n", n.Kind, display.ShortFuncName(n.Name), n.File, n.Line, n.ID)
+	}
+
+	return result, false
+}
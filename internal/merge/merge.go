@@ -0,0 +1,333 @@
+// Package merge combines the graphs of several repositories into one
+// organization-wide graph.
+//
+// Node IDs are derived from kind and qualified name (see storage.StableNodeID),
+// so the same function analyzed in two repositories, e.g. a shared library
+// included with --include-module, has the same ID in both and becomes a single
+// node. The copy from the repository whose main module owns the node wins.
+//
+// Calls into other modules are recorded by the analysis as external calls.
+// When one input's external call names a function another input analyzed, the
+// call becomes a regular calls edge, so impact analysis on a library function
+// reaches its consumers in every repository. Calls that still point outside
+// the merged graph stay external and can be resolved by a later merge.
+package merge
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+	"github.com/zheng/crag/internal/version"
+)
+
+// Source is one input graph
+type Source struct {
+	Name string // 显示名称 (数据库路径)
+	DB   *storage.DB
+}
+
+// SourceStats describes what one input contributed
+type SourceStats struct {
+	Name     string `json:"name"`
+	Module   string `json:"module"`   // 主模块路径，合并结果为空
+	Nodes    int    `json:"nodes"`    // 采用的节点
+	Shadowed int    `json:"shadowed"` // 被所属仓库的同名节点取代的节点
+	Edges    int    `json:"edges"`
+	External int    `json:"external"` // 外部调用
+}
+
+// Stats describes a merge
+type Stats struct {
+	Sources    []*SourceStats `json:"sources"`
+	Nodes      int            `json:"nodes"`
+	Edges      int            `json:"edges"`
+	Resolved   int            `json:"resolved"`   // 解析为跨仓库调用边的外部调用
+	Unresolved int            `json:"unresolved"` // 仍指向合并图之外的外部调用
+	Notes      int            `json:"notes"`
+}
+
+// input is the graph of one source as read from its database
+type input struct {
+	stats     *SourceStats
+	meta      *graph.Metadata
+	nodes     []*graph.Node
+	edges     []*graph.Edge
+	tags      []*graph.FieldTag
+	switches  []*graph.EnumSwitch
+	externals []*graph.ExternalCall
+	notes     []*graph.Note
+}
+
+// candidate is a node together with the input it comes from
+type candidate struct {
+	node  *graph.Node
+	src   *input
+	owned bool // 节点属于该输入的主模块
+}
+
+// Merge replaces the graph in batch with the union of the sources' graphs and
+// resolves their external calls against each other. Nothing is visible until
+// the caller commits the batch.
+func Merge(sources []*Source, batch *storage.Batch) (*Stats, error) {
+	inputs := make([]*input, 0, len(sources))
+	for _, s := range sources {
+		in, err := read(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Name, err)
+		}
+		inputs = append(inputs, in)
+	}
+
+	// Nodes: one per ID, preferring the copy of the repository that owns it
+	chosen := make(map[int64]*candidate)
+	for _, in := range inputs {
+		for _, n := range in.nodes {
+			c := &candidate{node: n, src: in, owned: in.owns(n)}
+			prev, ok := chosen[n.ID]
+			if !ok {
+				chosen[n.ID] = c
+				continue
+			}
+			if prev.node.Kind != n.Kind || prev.node.Name != n.Name {
+				return nil, fmt.Errorf("节点 ID #%d 冲突: %s (%s) 与 %s (%s)", n.ID, prev.node.Name, prev.src.stats.Name, n.Name, in.stats.Name)
+			}
+			if c.owned && !prev.owned {
+				chosen[n.ID] = c
+			}
+		}
+	}
+
+	if err := batch.Clear(); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	ids := make([]int64, 0, len(chosen))
+	for id := range chosen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	funcs := make(map[string]int64)
+	packages := make(map[string]bool)
+	for _, id := range ids {
+		c := chosen[id]
+		n := *c.node
+		n.File = c.src.relocate(n.File, n.Module)
+		if err := batch.RestoreNode(&n); err != nil {
+			return nil, err
+		}
+		c.src.stats.Nodes++
+		stats.Nodes++
+		if n.Kind == graph.NodeKindFunc {
+			funcs[n.Name] = n.ID
+		}
+		if n.Package != "" {
+			packages[n.Package] = true
+		}
+	}
+	for _, in := range inputs {
+		in.stats.Shadowed = len(in.nodes) - in.stats.Nodes
+	}
+
+	// Edges: the same relationship recorded by several inputs is kept once
+	type edgeKey struct {
+		from, to int64
+		kind     graph.EdgeKind
+	}
+	seenEdges := make(map[edgeKey]bool)
+	addEdge := func(in *input, e *graph.Edge) error {
+		k := edgeKey{e.FromID, e.ToID, e.Kind}
+		if seenEdges[k] {
+			return nil
+		}
+		seenEdges[k] = true
+		in.stats.Edges++
+		stats.Edges++
+		return batch.InsertEdge(e)
+	}
+	// Call sites are in the caller's file, so they move with the caller's module
+	relocateSite := func(in *input, file string, fromID int64) string {
+		if from, ok := chosen[fromID]; ok {
+			return in.relocate(file, from.node.Module)
+		}
+		return file
+	}
+	for _, in := range inputs {
+		for _, e := range in.edges {
+			e.CallSiteFile = relocateSite(in, e.CallSiteFile, e.FromID)
+			if err := addEdge(in, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// External calls: resolve against the merged functions, keep the rest
+	type externalKey struct {
+		from   int64
+		callee string
+	}
+	seenExternal := make(map[externalKey]bool)
+	for _, in := range inputs {
+		for _, c := range in.externals {
+			c.CallSiteFile = relocateSite(in, c.CallSiteFile, c.FromID)
+			if toID, ok := funcs[c.Callee]; ok && toID != c.FromID {
+				stats.Resolved++
+				if err := addEdge(in, &graph.Edge{
+					FromID:       c.FromID,
+					ToID:         toID,
+					Kind:         graph.EdgeKindCalls,
+					CallSiteFile: c.CallSiteFile,
+					CallSiteLine: c.CallSiteLine,
+				}); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			k := externalKey{c.FromID, c.Callee}
+			if seenExternal[k] {
+				continue
+			}
+			seenExternal[k] = true
+			stats.Unresolved++
+			if err := batch.InsertExternalCall(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	type tagKey struct {
+		field     int64
+		key, name string
+	}
+	seenTags := make(map[tagKey]bool)
+	type switchKey struct {
+		enum, fn int64
+		file     string
+		line     int
+	}
+	seenSwitches := make(map[switchKey]bool)
+	for _, in := range inputs {
+		for _, t := range in.tags {
+			k := tagKey{t.FieldID, t.Key, t.Name}
+			if seenTags[k] {
+				continue
+			}
+			seenTags[k] = true
+			if err := batch.InsertFieldTag(t); err != nil {
+				return nil, err
+			}
+		}
+		for _, sw := range in.switches {
+			if fn, ok := chosen[sw.FuncID]; ok {
+				sw.File = in.relocate(sw.File, fn.node.Module)
+			}
+			k := switchKey{sw.EnumID, sw.FuncID, sw.File, sw.Line}
+			if seenSwitches[k] {
+				continue
+			}
+			seenSwitches[k] = true
+			if err := batch.InsertEnumSwitch(sw); err != nil {
+				return nil, err
+			}
+		}
+		for _, n := range in.notes {
+			added, err := batch.RestoreNote(n)
+			if err != nil {
+				return nil, err
+			}
+			if added {
+				stats.Notes++
+			}
+		}
+	}
+
+	names := make([]string, 0, len(inputs))
+	for _, in := range inputs {
+		stats.Sources = append(stats.Sources, in.stats)
+		names = append(names, in.stats.Name)
+	}
+	meta := &graph.Metadata{
+		CragVersion:  version.String(),
+		Flags:        "merge " + strings.Join(names, " "),
+		PackageCount: len(packages),
+		AnalyzedAt:   time.Now(),
+	}
+	if m := inputs[0].meta; m != nil {
+		meta.GoVersion = m.GoVersion
+		meta.Algorithm = m.Algorithm
+	}
+	if err := batch.SaveMetadata(meta); err != nil {
+		return nil, err
+	}
+	// A merged graph is not analyzed from one source tree, so it records no files
+	if err := batch.SaveSourceFiles(nil); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// read loads everything merge needs from one source
+func read(s *Source) (*input, error) {
+	in := &input{stats: &SourceStats{Name: s.Name}}
+	var err error
+	if in.meta, err = s.DB.GetMetadata(); err != nil {
+		return nil, err
+	}
+	if in.meta != nil {
+		in.stats.Module = in.meta.ModulePath
+	}
+	if in.nodes, err = s.DB.GetAllNodes(); err != nil {
+		return nil, err
+	}
+	if in.edges, err = s.DB.GetAllEdges(); err != nil {
+		return nil, err
+	}
+	if in.tags, err = s.DB.GetAllFieldTags(); err != nil {
+		return nil, err
+	}
+	if in.switches, err = s.DB.GetAllEnumSwitches(); err != nil {
+		return nil, err
+	}
+	if in.externals, err = s.DB.GetAllExternalCalls(); err != nil {
+		return nil, err
+	}
+	if in.notes, err = s.DB.GetAllNotes(); err != nil {
+		return nil, err
+	}
+	in.stats.External = len(in.externals)
+	return in, nil
+}
+
+// owns reports whether a node belongs to the main module of the input, rather
+// than to a dependency module included in its analysis
+func (in *input) owns(n *graph.Node) bool {
+	return in.stats.Module != "" && n.Module == in.stats.Module && n.ModuleVersion == ""
+}
+
+// relocate turns a path relative to the input's project root into one that is
+// unique across repositories: "module/relative/path.go", the same form used
+// for files of included dependency modules (without the version). Absolute
+// paths under the project root, as recorded for call sites, are relocated too.
+func (in *input) relocate(file, module string) string {
+	if in.stats.Module == "" || module != in.stats.Module || file == "" {
+		return file
+	}
+	if filepath.IsAbs(file) {
+		if in.meta == nil || in.meta.ProjectRoot == "" {
+			return file
+		}
+		rel, err := filepath.Rel(in.meta.ProjectRoot, file)
+		if err != nil || !filepath.IsLocal(rel) {
+			return file
+		}
+		file = rel
+	}
+	return path.Join(in.stats.Module, filepath.ToSlash(file))
+}
@@ -0,0 +1,319 @@
+// Package owners maps source files to their owners using a CODEOWNERS file.
+package owners
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// Unowned is the owner key of files no CODEOWNERS rule assigns
+const Unowned = ""
+
+// codeownersPaths are the locations GitHub and GitLab look for CODEOWNERS,
+// relative to the repository root, in priority order
+var codeownersPaths = []string{
+	".github/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+// Ruleset is a parsed CODEOWNERS file
+type Ruleset struct {
+	Path   string // CODEOWNERS 文件路径
+	prefix string // 项目目录相对仓库根目录的路径，"" 表示二者相同
+	rules  []*rule
+}
+
+type rule struct {
+	pattern string
+	re      *regexp.Regexp
+	owners  []string
+	section string // GitLab 分节名，GitHub 格式为 ""
+}
+
+// Load finds the CODEOWNERS file of the repository containing projectRoot.
+// It returns nil without error when there is none.
+func Load(projectRoot string) (*Ruleset, error) {
+	root, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk up to the repository root: CODEOWNERS patterns are relative to it
+	for dir := root; ; dir = filepath.Dir(dir) {
+		for _, p := range codeownersPaths {
+			path := filepath.Join(dir, p)
+			f, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			rs, err := Parse(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			rs.Path = path
+			if rel, err := filepath.Rel(dir, root); err == nil && rel != "." {
+				rs.prefix = filepath.ToSlash(rel)
+			}
+			return rs, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || filepath.Dir(dir) == dir {
+			return nil, nil
+		}
+	}
+}
+
+// LoadForGraph loads the CODEOWNERS file of the project a graph was built from,
+// falling back to the working directory for graphs without metadata
+func LoadForGraph(meta *graph.Metadata) (*Ruleset, error) {
+	if meta != nil && meta.ProjectRoot != "" {
+		return Load(meta.ProjectRoot)
+	}
+	return Load(".")
+}
+
+// Parse parses a CODEOWNERS file. Both the GitHub format and GitLab sections
+// ("[Section] @default-owner", "^[Optional]") are supported.
+func Parse(r io.Reader) (*Ruleset, error) {
+	rs := &Ruleset{}
+	section := ""
+	var sectionOwners []string
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if name, owners, ok := parseSection(line); ok {
+			section, sectionOwners = name, owners
+			continue
+		}
+
+		fields := splitFields(line)
+		var owners []string
+		for _, f := range fields[1:] {
+			if strings.HasPrefix(f, "#") {
+				break
+			}
+			owners = append(owners, f)
+		}
+		if len(owners) == 0 {
+			owners = sectionOwners
+		}
+
+		re, err := compilePattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", lineNo, err)
+		}
+		rs.rules = append(rs.rules, &rule{pattern: fields[0], re: re, owners: owners, section: section})
+	}
+	return rs, scanner.Err()
+}
+
+// sectionRe matches GitLab section headers: [Name], ^[Name] and [Name][2], with optional default owners
+var sectionRe = regexp.MustCompile(`^\^?\[([^\]]+)\](?:\[\d+\])?\s*(.*)$`)
+
+func parseSection(line string) (string, []string, bool) {
+	m := sectionRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", nil, false
+	}
+	return m[1], strings.Fields(m[2]), true
+}
+
+// splitFields splits a rule line on whitespace, honoring "\ " escapes in paths
+func splitFields(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ' ' || r == '\t':
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// compilePattern converts a CODEOWNERS pattern to a regexp over slash-separated
+// paths relative to the repository root, following gitignore rules:
+//
+//	/docs/    anchored directory: everything below docs at the root
+//	docs/     any docs directory
+//	*.go      matching file names at any depth
+//	a/b       paths containing a slash are anchored
+//	docs/*    files directly in docs, not in subdirectories
+//	**/x, a/**/b, a/**   any number of directories
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	p := pattern
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	anchored := strings.HasPrefix(p, "/") || strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if p == "" || p == "*" || p == "**" {
+		return regexp.Compile(`.*`)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case strings.HasPrefix(p[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "/**") && i+3 == len(p):
+			sb.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	// A name matches the file itself or, as a directory, everything below it.
+	// Wildcards in the last segment only match files in that directory.
+	last := p[strings.LastIndex(p, "/")+1:]
+	switch {
+	case dirOnly:
+		sb.WriteString("/.*")
+	case strings.ContainsAny(last, "*?"):
+	default:
+		sb.WriteString("(?:/.*)?")
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// Match returns the owners of a path relative to the repository root. As on
+// GitHub the last matching rule wins; with GitLab sections the last matching
+// rule of every section applies and their owners are combined.
+func (rs *Ruleset) Match(path string) []string {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	matched := make(map[string]*rule)
+	var sections []string
+	for _, r := range rs.rules {
+		if !r.re.MatchString(path) {
+			continue
+		}
+		if _, ok := matched[r.section]; !ok {
+			sections = append(sections, r.section)
+		}
+		matched[r.section] = r
+	}
+
+	seen := make(map[string]bool)
+	var owners []string
+	for _, s := range sections {
+		for _, o := range matched[s].owners {
+			if !seen[o] {
+				seen[o] = true
+				owners = append(owners, o)
+			}
+		}
+	}
+	return owners
+}
+
+// Owners returns the owners of a file relative to the project root
+func (rs *Ruleset) Owners(file string) []string {
+	if rs == nil || file == "" || filepath.IsAbs(file) {
+		return nil
+	}
+	if rs.prefix != "" {
+		file = rs.prefix + "/" + filepath.ToSlash(file)
+	}
+	return rs.Match(file)
+}
+
+// Group is the set of nodes one owner is responsible for
+type Group struct {
+	Owner string        `json:"owner"` // 负责人，Unowned 表示未指定
+	Nodes []*graph.Node `json:"nodes"`
+}
+
+// GroupByOwner groups nodes by the owners of their files. A node with several
+// owners appears in each of their groups. Groups are ordered by size, with
+// unowned nodes last.
+func (rs *Ruleset) GroupByOwner(nodes []*graph.Node) []*Group {
+	byOwner := make(map[string]*Group)
+	var groups []*Group
+	add := func(owner string, n *graph.Node) {
+		g, ok := byOwner[owner]
+		if !ok {
+			g = &Group{Owner: owner}
+			byOwner[owner] = g
+			groups = append(groups, g)
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+
+	seen := make(map[int64]bool)
+	for _, n := range nodes {
+		if seen[n.ID] {
+			continue
+		}
+		seen[n.ID] = true
+		owners := rs.Owners(n.File)
+		if len(owners) == 0 {
+			add(Unowned, n)
+		}
+		for _, o := range owners {
+			add(o, n)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].Owner == Unowned) != (groups[j].Owner == Unowned) {
+			return groups[j].Owner == Unowned
+		}
+		if len(groups[i].Nodes) != len(groups[j].Nodes) {
+			return len(groups[i].Nodes) > len(groups[j].Nodes)
+		}
+		return groups[i].Owner < groups[j].Owner
+	})
+	return groups
+}
+
+// OwnerLabel returns the display name of an owner key
+func OwnerLabel(owner string) string {
+	if owner == Unowned {
+		return "(未指定负责人)"
+	}
+	return owner
+}
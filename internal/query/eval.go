@@ -0,0 +1,359 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zheng/crag/internal/display"
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// stepDef describes a traversal step: the edges it follows and in which direction
+type stepDef struct {
+	kind     graph.EdgeKind
+	upstream bool // 沿边的反方向 (to -> from)
+}
+
+var steps = map[string]stepDef{
+	"callers":      {graph.EdgeKindCalls, true},
+	"callees":      {graph.EdgeKindCalls, false},
+	"implements":   {graph.EdgeKindImplements, false},
+	"implementers": {graph.EdgeKindImplements, true},
+	"references":   {graph.EdgeKindReferences, false},
+	"referrers":    {graph.EdgeKindReferences, true},
+	"fields":       {graph.EdgeKindHasField, false},
+}
+
+// allKinds are the node kinds a query ranges over
+var allKinds = []graph.NodeKind{
+	graph.NodeKindFunc, graph.NodeKindStruct, graph.NodeKindInterface, graph.NodeKindPackage,
+	graph.NodeKindVar, graph.NodeKindConst, graph.NodeKindField, graph.NodeKindEnum,
+}
+
+type nodeSet map[int64]bool
+
+// Graph is a stored graph loaded for querying. It can answer any number of queries.
+type Graph struct {
+	nodes map[int64]*graph.Node
+	all   []*graph.Node // ordered by name
+	out   map[graph.EdgeKind]map[int64][]int64
+	in    map[graph.EdgeKind]map[int64][]int64
+}
+
+// Load reads the nodes and edges of a store
+func Load(store storage.Store) (*Graph, error) {
+	nodes, err := store.GetNodesByKinds(allKinds...)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := store.GetAllEdges()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graph{
+		nodes: make(map[int64]*graph.Node, len(nodes)),
+		all:   nodes,
+		out:   make(map[graph.EdgeKind]map[int64][]int64),
+		in:    make(map[graph.EdgeKind]map[int64][]int64),
+	}
+	for _, n := range nodes {
+		g.nodes[n.ID] = n
+	}
+	sortNodes(g.all)
+	for _, e := range edges {
+		if g.out[e.Kind] == nil {
+			g.out[e.Kind] = make(map[int64][]int64)
+			g.in[e.Kind] = make(map[int64][]int64)
+		}
+		g.out[e.Kind][e.FromID] = append(g.out[e.Kind][e.FromID], e.ToID)
+		g.in[e.Kind][e.ToID] = append(g.in[e.Kind][e.ToID], e.FromID)
+	}
+	return g, nil
+}
+
+// Run parses and evaluates a query against a store
+func Run(store storage.Store, src string) ([]*graph.Node, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	g, err := Load(store)
+	if err != nil {
+		return nil, err
+	}
+	return g.Eval(e)
+}
+
+// Eval evaluates a parsed query. Nodes are ordered by name.
+func (g *Graph) Eval(e Expr) ([]*graph.Node, error) {
+	set, err := g.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return g.list(set), nil
+}
+
+func (g *Graph) eval(e Expr) (nodeSet, error) {
+	switch e := e.(type) {
+	case *binaryExpr:
+		left, err := g.eval(e.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := g.eval(e.right)
+		if err != nil {
+			return nil, err
+		}
+		result := make(nodeSet)
+		switch e.op {
+		case '&':
+			for id := range left {
+				if right[id] {
+					result[id] = true
+				}
+			}
+		case '-':
+			for id := range left {
+				if !right[id] {
+					result[id] = true
+				}
+			}
+		default:
+			for id := range left {
+				result[id] = true
+			}
+			for id := range right {
+				result[id] = true
+			}
+		}
+		return result, nil
+	case *stepExpr:
+		arg, err := g.eval(e.arg)
+		if err != nil {
+			return nil, err
+		}
+		return g.walk(arg, steps[e.step], e.min, e.max), nil
+	case *limitExpr:
+		arg, err := g.eval(e.arg)
+		if err != nil {
+			return nil, err
+		}
+		result := make(nodeSet)
+		for _, n := range g.list(arg) {
+			if len(result) == e.n {
+				break
+			}
+			result[n.ID] = true
+		}
+		return result, nil
+	case *predExpr:
+		return g.filter(func(n *graph.Node) bool { return e.match(n) }), nil
+	case *setExpr:
+		switch e.name {
+		case "tests":
+			return g.filter(isTestNode), nil
+		case "exported":
+			return g.filter(func(n *graph.Node) bool { return isExported(identifier(n.Name)) }), nil
+		default:
+			return g.filter(func(*graph.Node) bool { return true }), nil
+		}
+	case *refExpr:
+		return g.resolve(e.name)
+	}
+	return nil, fmt.Errorf("未知表达式 %s", e)
+}
+
+// Notices returns remarks about a query that evaluated without error but may
+// not mean what it says, such as a tests set in a graph without test files
+func (g *Graph) Notices(e Expr) []string {
+	var notices []string
+	if usesSet(e, "tests") && len(g.filter(isTestNode)) == 0 {
+		notices = append(notices, "tests 集合为空: crag analyze 不加载 _test.go 文件，- tests 不会排除任何节点")
+	}
+	return notices
+}
+
+// usesSet reports whether an expression refers to a named set
+func usesSet(e Expr, name string) bool {
+	switch e := e.(type) {
+	case *setExpr:
+		return e.name == name
+	case *binaryExpr:
+		return usesSet(e.left, name) || usesSet(e.right, name)
+	case *stepExpr:
+		return usesSet(e.arg, name)
+	case *limitExpr:
+		return usesSet(e.arg, name)
+	}
+	return false
+}
+
+func isTestNode(n *graph.Node) bool {
+	return strings.HasSuffix(n.File, "_test.go")
+}
+
+// walk returns the nodes reachable from seeds by a path of min to max steps
+// (max < 0: any number of steps at least min)
+func (g *Graph) walk(seeds nodeSet, step stepDef, min, max int) nodeSet {
+	adj := g.out[step.kind]
+	if step.upstream {
+		adj = g.in[step.kind]
+	}
+	advance := func(frontier nodeSet) nodeSet {
+		next := make(nodeSet)
+		for id := range frontier {
+			for _, nb := range adj[id] {
+				next[nb] = true
+			}
+		}
+		return next
+	}
+
+	result := make(nodeSet)
+	frontier := seeds
+	for depth := 0; depth < min && len(frontier) > 0; depth++ {
+		frontier = advance(frontier)
+	}
+	if max < 0 {
+		// Everything reachable from the nodes min steps away, including them
+		queue := make([]int64, 0, len(frontier))
+		for id := range frontier {
+			result[id] = true
+			queue = append(queue, id)
+		}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, nb := range adj[id] {
+				if !result[nb] {
+					result[nb] = true
+					queue = append(queue, nb)
+				}
+			}
+		}
+		return result
+	}
+	for depth := min; len(frontier) > 0; depth++ {
+		for id := range frontier {
+			result[id] = true
+		}
+		if depth == max {
+			break
+		}
+		frontier = advance(frontier)
+	}
+	return result
+}
+
+func (g *Graph) filter(keep func(*graph.Node) bool) nodeSet {
+	result := make(nodeSet)
+	for _, n := range g.all {
+		if keep(n) {
+			result[n.ID] = true
+		}
+	}
+	return result
+}
+
+// resolve finds the nodes a reference names: an ID, a full name, or a short
+// name such as "storage.Save" or "Save". A short name may match several nodes.
+func (g *Graph) resolve(name string) (nodeSet, error) {
+	result := make(nodeSet)
+	if id, ok := storage.ParseNodeRef(name); ok {
+		if _, ok := g.nodes[id]; ok {
+			result[id] = true
+			return result, nil
+		}
+		return nil, fmt.Errorf("未找到节点 %s", name)
+	}
+
+	for _, n := range g.all {
+		if n.Name == name {
+			result[n.ID] = true
+		}
+	}
+	if len(result) > 0 {
+		return result, nil
+	}
+	for _, n := range g.all {
+		short := display.ShortFuncName(n.Name)
+		if short == name || strings.HasSuffix(n.Name, "."+name) || strings.HasSuffix(n.Name, "/"+name) {
+			result[n.ID] = true
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("未找到节点 %q (可使用完整名称、短名称或 #ID)", name)
+	}
+	return result, nil
+}
+
+func (e *predExpr) match(n *graph.Node) bool {
+	var value string
+	switch e.field {
+	case "kind":
+		value = string(n.Kind)
+	case "name":
+		value = n.Name
+	case "package":
+		value = n.Package
+	case "module":
+		value = n.Module
+	case "file":
+		value = n.File
+	}
+
+	var ok bool
+	switch e.op {
+	case "=", "!=":
+		ok = value == e.value
+		// name=Save also matches the bare identifier
+		if e.field == "name" && !ok {
+			ok = identifier(n.Name) == e.value
+		}
+	default:
+		ok = e.re.MatchString(value)
+	}
+	if strings.HasPrefix(e.op, "!") {
+		return !ok
+	}
+	return ok
+}
+
+// identifier returns the last identifier of a qualified name: "Save" for "(*pkg.DB).Save"
+func identifier(name string) string {
+	name = name[strings.LastIndex(name, ".")+1:]
+	if i := strings.IndexAny(name, "[$"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func isExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+func (g *Graph) list(set nodeSet) []*graph.Node {
+	nodes := make([]*graph.Node, 0, len(set))
+	for id := range set {
+		if n, ok := g.nodes[id]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+	sortNodes(nodes)
+	return nodes
+}
+
+func sortNodes(nodes []*graph.Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Name != nodes[j].Name {
+			return nodes[i].Name < nodes[j].Name
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+}
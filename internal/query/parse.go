@@ -0,0 +1,439 @@
+// Package query implements crag's graph query language.
+//
+// A query is a set expression over nodes:
+//
+//	callers*(storage.Save) & package~"handler" - tests
+//
+// Operands:
+//
+//	storage.Save, "(*pkg.T).M", #123   node reference: full or short name, or ID
+//	kind=func  package~"re"  name=Save  file~"^cmd/"  module=...
+//	                                   filters over all nodes (= != ~ !~, ~ is a regexp)
+//	all, tests, exported               named sets; tests are the nodes of
+//	                                   _test.go files, which crag analyze does
+//	                                   not load, so it is empty (see Notices)
+//	callers(x) callees(x)              call graph steps; * for any depth,
+//	callers{2}(x) callees{1,3}(x)      {n} / {n,m} / {n,} for a depth range
+//	implements(x) implementers(x)      interfaces a type implements / types implementing an interface
+//	references(x) referrers(x)         vars/consts a function uses / functions using a var/const
+//	fields(x)                          fields of a struct
+//	limit(x, n)                        the first n nodes of x, ordered by name
+//	(x)
+//
+// Operators, loosest last: & (intersection), then | (union) and - (difference),
+// left to right. Names containing characters other than letters, digits and
+// _ . / # $ must be quoted.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed query expression
+type Expr interface {
+	String() string
+}
+
+type binaryExpr struct {
+	op          byte // '&', '|' or '-'
+	left, right Expr
+}
+
+type stepExpr struct {
+	step     string
+	min, max int // max < 0: unbounded
+	arg      Expr
+}
+
+type predExpr struct {
+	field, op, value string
+	re               *regexp.Regexp // for ~ and !~
+}
+
+type refExpr struct {
+	name string
+}
+
+type setExpr struct {
+	name string
+}
+
+type limitExpr struct {
+	arg Expr
+	n   int
+}
+
+func (e *binaryExpr) String() string {
+	return fmt.Sprintf("(%s %c %s)", e.left, e.op, e.right)
+}
+
+func (e *stepExpr) String() string {
+	var q string
+	switch {
+	case e.min == 1 && e.max == 1:
+	case e.min == 1 && e.max < 0:
+		q = "*"
+	case e.max < 0:
+		q = fmt.Sprintf("{%d,}", e.min)
+	case e.min == e.max:
+		q = fmt.Sprintf("{%d}", e.min)
+	default:
+		q = fmt.Sprintf("{%d,%d}", e.min, e.max)
+	}
+	return fmt.Sprintf("%s%s(%s)", e.step, q, e.arg)
+}
+
+func (e *predExpr) String() string { return e.field + e.op + strconv.Quote(e.value) }
+func (e *refExpr) String() string  { return strconv.Quote(e.name) }
+func (e *setExpr) String() string  { return e.name }
+func (e *limitExpr) String() string {
+	return fmt.Sprintf("limit(%s, %d)", e.arg, e.n)
+}
+
+// predicate fields and named sets
+var (
+	fields    = map[string]bool{"kind": true, "name": true, "package": true, "module": true, "file": true}
+	namedSets = map[string]bool{"all": true, "tests": true, "exported": true}
+)
+
+// Parse parses a query expression
+func Parse(src string) (Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.union()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "多余的 %s", t)
+	}
+	return e, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) peekAt(n int) token {
+	if p.pos+n < len(p.toks) {
+		return p.toks[p.pos+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "期望 %s，实际为 %s", kind, t)
+	}
+	return t, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("第 %d 列: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+// union := inter (('|' | '-') inter)*
+func (p *parser) union() (Expr, error) {
+	left, err := p.inter()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp || (t.text != "|" && t.text != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.inter()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text[0], left: left, right: right}
+	}
+}
+
+// inter := primary ('&' primary)*
+func (p *parser) inter() (Expr, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&" {
+		p.next()
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: '&', left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) primary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		e, err := p.union()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case tokString:
+		return &refExpr{name: t.text}, nil
+	case tokWord:
+	default:
+		return nil, p.errorf(t, "期望节点名、过滤条件或步骤，实际为 %s", t)
+	}
+
+	next := p.peek()
+	if _, ok := steps[t.text]; ok && (next.kind == tokLParen || next.kind == tokStar || next.kind == tokLBrace) {
+		return p.step(t.text)
+	}
+	if t.text == "limit" && next.kind == tokLParen {
+		return p.limit()
+	}
+	if next.kind == tokCompare {
+		if !fields[t.text] {
+			return nil, p.errorf(t, "未知字段 %q (可用: kind, name, package, module, file)", t.text)
+		}
+		return p.predicate(t.text)
+	}
+	if namedSets[t.text] {
+		return &setExpr{name: t.text}, nil
+	}
+	return &refExpr{name: t.text}, nil
+}
+
+// step := name ('*' | '{' n [',' [m]] '}')? '(' union ')'
+func (p *parser) step(name string) (Expr, error) {
+	e := &stepExpr{step: name, min: 1, max: 1}
+	switch t := p.peek(); t.kind {
+	case tokStar:
+		p.next()
+		e.max = -1
+	case tokLBrace:
+		p.next()
+		n, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		e.min, e.max = n, n
+		if p.peek().kind == tokComma {
+			p.next()
+			e.max = -1
+			if p.peek().kind == tokWord {
+				if e.max, err = p.number(); err != nil {
+					return nil, err
+				}
+				if e.max < e.min {
+					return nil, p.errorf(t, "深度范围 {%d,%d} 无效", e.min, e.max)
+				}
+			}
+		}
+		if _, err := p.expect(tokRBrace); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.expect(tokLParen); err != nil {
+		return nil, err
+	}
+	arg, err := p.union()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	e.arg = arg
+	return e, nil
+}
+
+// limit := 'limit' '(' union ',' n ')'
+func (p *parser) limit() (Expr, error) {
+	p.next()
+	arg, err := p.union()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokComma); err != nil {
+		return nil, err
+	}
+	n, err := p.number()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	return &limitExpr{arg: arg, n: n}, nil
+}
+
+// predicate := field ('=' | '!=' | '~' | '!~') (word | string)
+func (p *parser) predicate(field string) (Expr, error) {
+	op := p.next().text
+	t := p.next()
+	if t.kind != tokWord && t.kind != tokString {
+		return nil, p.errorf(t, "%s%s 后期望一个值，实际为 %s", field, op, t)
+	}
+	e := &predExpr{field: field, op: op, value: t.text}
+	if op == "~" || op == "!~" {
+		re, err := regexp.Compile(t.text)
+		if err != nil {
+			return nil, p.errorf(t, "正则表达式无效: %v", err)
+		}
+		e.re = re
+	}
+	return e, nil
+}
+
+func (p *parser) number() (int, error) {
+	t := p.next()
+	n, err := strconv.Atoi(t.text)
+	if t.kind != tokWord || err != nil || n < 0 {
+		return 0, p.errorf(t, "期望非负整数，实际为 %s", t)
+	}
+	return n, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokLBrace
+	tokRBrace
+	tokComma
+	tokStar
+	tokOp      // & | -
+	tokCompare // = != ~ !~
+)
+
+func (k tokKind) String() string {
+	switch k {
+	case tokEOF:
+		return "查询结尾"
+	case tokWord:
+		return "名称"
+	case tokString:
+		return "字符串"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokLBrace:
+		return "'{'"
+	case tokRBrace:
+		return "'}'"
+	case tokComma:
+		return "','"
+	case tokStar:
+		return "'*'"
+	case tokOp:
+		return "运算符"
+	default:
+		return "比较符"
+	}
+}
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return t.kind.String()
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || c == '/' || c == '#' || c == '$' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				if src[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("第 %d 列: 字符串缺少结束引号", i+1)
+			}
+			text := src[i+1 : end]
+			if c == '"' {
+				s, err := strconv.Unquote(src[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 列: 字符串无效: %v", i+1, err)
+				}
+				text = s
+			}
+			toks = append(toks, token{tokString, text, i})
+			i = end + 1
+		case isWordChar(c):
+			start := i
+			for i < len(src) && isWordChar(src[i]) {
+				i++
+			}
+			toks = append(toks, token{tokWord, src[start:i], start})
+		case strings.HasPrefix(src[i:], "!=") || strings.HasPrefix(src[i:], "!~"):
+			toks = append(toks, token{tokCompare, src[i : i+2], i})
+			i += 2
+		case c == '=' || c == '~':
+			toks = append(toks, token{tokCompare, string(c), i})
+			i++
+		case c == '&' || c == '|' || c == '-':
+			toks = append(toks, token{tokOp, string(c), i})
+			i++
+		default:
+			kinds := map[byte]tokKind{'(': tokLParen, ')': tokRParen, '{': tokLBrace, '}': tokRBrace, ',': tokComma, '*': tokStar}
+			k, ok := kinds[c]
+			if !ok {
+				return nil, fmt.Errorf("第 %d 列: 无法识别的字符 %q", i+1, c)
+			}
+			toks = append(toks, token{k, string(c), i})
+			i++
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
@@ -0,0 +1,241 @@
+package snapshot
+
+import (
+	"sort"
+
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// SignatureChange is a function whose signature differs between two graphs
+type SignatureChange struct {
+	Name         string `json:"name"`
+	OldSignature string `json:"old_signature"`
+	NewSignature string `json:"new_signature"`
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+}
+
+// EdgeChange is an edge present in only one of two graphs, identified by node names
+type EdgeChange struct {
+	From string         `json:"from"`
+	To   string         `json:"to"`
+	Kind graph.EdgeKind `json:"kind"`
+}
+
+// RiskChange is a function whose number of direct or transitive callers changed
+type RiskChange struct {
+	Name            string `json:"name"`
+	OldCallers      int    `json:"old_callers"`
+	NewCallers      int    `json:"new_callers"`
+	OldTotalCallers int    `json:"old_total_callers"`
+	NewTotalCallers int    `json:"new_total_callers"`
+	OldLevel        string `json:"old_level"`
+	NewLevel        string `json:"new_level"`
+}
+
+// Delta returns the change in direct callers
+func (r *RiskChange) Delta() int {
+	return r.NewCallers - r.OldCallers
+}
+
+// TotalDelta returns the change in transitive callers
+func (r *RiskChange) TotalDelta() int {
+	return r.NewTotalCallers - r.OldTotalCallers
+}
+
+// LevelChanged reports whether the risk level moved
+func (r *RiskChange) LevelChanged() bool {
+	return r.OldLevel != r.NewLevel
+}
+
+// DiffReport lists the differences from graph a to graph b
+type DiffReport struct {
+	AddedFuncs       []*graph.Node      `json:"added_funcs"`
+	RemovedFuncs     []*graph.Node      `json:"removed_funcs"`
+	SignatureChanges []*SignatureChange `json:"signature_changes"`
+	AddedEdges       []*EdgeChange      `json:"added_edges"`
+	RemovedEdges     []*EdgeChange      `json:"removed_edges"`
+	RiskChanges      []*RiskChange      `json:"risk_changes"`
+}
+
+// graphView is the part of a graph needed for diffing, keyed by names
+type graphView struct {
+	funcs   map[string]*graph.Node
+	edges   map[EdgeChange]bool
+	callers map[string]int // direct caller edges per function name
+	totals  map[string]int // transitive callers per function name, from the reachability index
+}
+
+// risk returns the callers of a function and rates them like crag risk. A
+// function missing from the reachability index is rated by direct callers.
+func (v *graphView) risk(name string) (direct, total int, level string) {
+	direct = v.callers[name]
+	total, ok := v.totals[name]
+	if !ok {
+		return direct, 0, storage.CalculateRiskLevelFast(direct)
+	}
+	return direct, total, storage.CalculateRiskLevel(direct, total)
+}
+
+// Diff compares graph a (older) with graph b (newer)
+func Diff(a, b *storage.DB) (*DiffReport, error) {
+	va, err := loadView(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := loadView(b)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DiffReport{}
+
+	for name, n := range vb.funcs {
+		old, ok := va.funcs[name]
+		if !ok {
+			report.AddedFuncs = append(report.AddedFuncs, n)
+			continue
+		}
+		if old.Signature != n.Signature {
+			report.SignatureChanges = append(report.SignatureChanges, &SignatureChange{
+				Name:         name,
+				OldSignature: old.Signature,
+				NewSignature: n.Signature,
+				File:         n.File,
+				Line:         n.Line,
+			})
+		}
+		oldDirect, oldTotal, oldLevel := va.risk(name)
+		newDirect, newTotal, newLevel := vb.risk(name)
+		if oldDirect != newDirect || oldTotal != newTotal {
+			report.RiskChanges = append(report.RiskChanges, &RiskChange{
+				Name:            name,
+				OldCallers:      oldDirect,
+				NewCallers:      newDirect,
+				OldTotalCallers: oldTotal,
+				NewTotalCallers: newTotal,
+				OldLevel:        oldLevel,
+				NewLevel:        newLevel,
+			})
+		}
+	}
+	for name, n := range va.funcs {
+		if _, ok := vb.funcs[name]; !ok {
+			report.RemovedFuncs = append(report.RemovedFuncs, n)
+		}
+	}
+
+	for e := range vb.edges {
+		if !va.edges[e] {
+			report.AddedEdges = append(report.AddedEdges, &e)
+		}
+	}
+	for e := range va.edges {
+		if !vb.edges[e] {
+			report.RemovedEdges = append(report.RemovedEdges, &e)
+		}
+	}
+
+	sortNodes(report.AddedFuncs)
+	sortNodes(report.RemovedFuncs)
+	sort.Slice(report.SignatureChanges, func(i, j int) bool {
+		return report.SignatureChanges[i].Name < report.SignatureChanges[j].Name
+	})
+	sortEdges(report.AddedEdges)
+	sortEdges(report.RemovedEdges)
+	// Level changes first, then by size of the change
+	sort.Slice(report.RiskChanges, func(i, j int) bool {
+		ri, rj := report.RiskChanges[i], report.RiskChanges[j]
+		if ri.LevelChanged() != rj.LevelChanged() {
+			return ri.LevelChanged()
+		}
+		if abs(ri.TotalDelta()) != abs(rj.TotalDelta()) {
+			return abs(ri.TotalDelta()) > abs(rj.TotalDelta())
+		}
+		if abs(ri.Delta()) != abs(rj.Delta()) {
+			return abs(ri.Delta()) > abs(rj.Delta())
+		}
+		return ri.Name < rj.Name
+	})
+
+	return report, nil
+}
+
+// IsEmpty reports whether the two graphs have no differences
+func (r *DiffReport) IsEmpty() bool {
+	return len(r.AddedFuncs) == 0 && len(r.RemovedFuncs) == 0 && len(r.SignatureChanges) == 0 &&
+		len(r.AddedEdges) == 0 && len(r.RemovedEdges) == 0 && len(r.RiskChanges) == 0
+}
+
+func loadView(db *storage.DB) (*graphView, error) {
+	nodes, err := db.GetNodesByKinds(
+		graph.NodeKindFunc, graph.NodeKindStruct, graph.NodeKindInterface, graph.NodeKindVar,
+		graph.NodeKindConst, graph.NodeKindField, graph.NodeKindEnum,
+	)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := db.GetAllEdges()
+	if err != nil {
+		return nil, err
+	}
+	totals, err := db.GetAllTotalCallers()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &graphView{
+		funcs:   make(map[string]*graph.Node),
+		edges:   make(map[EdgeChange]bool),
+		callers: make(map[string]int),
+		totals:  make(map[string]int),
+	}
+	names := make(map[int64]string, len(nodes))
+	for _, n := range nodes {
+		names[n.ID] = n.Name
+		if n.Kind == graph.NodeKindFunc {
+			v.funcs[n.Name] = n
+			if total, ok := totals[n.ID]; ok {
+				v.totals[n.Name] = total
+			}
+		}
+	}
+
+	for _, e := range edges {
+		from, fromOK := names[e.FromID]
+		to, toOK := names[e.ToID]
+		if !fromOK || !toOK {
+			continue
+		}
+		// Counted per edge, like GetDirectCallerCount, as crag risk does
+		if e.Kind == graph.EdgeKindCalls {
+			v.callers[to]++
+		}
+		v.edges[EdgeChange{From: from, To: to, Kind: e.Kind}] = true
+	}
+	return v, nil
+}
+
+func sortNodes(nodes []*graph.Node) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+}
+
+func sortEdges(edges []*EdgeChange) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].Kind < edges[j].Kind
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,94 @@
+// Package snapshot keeps named graphs (one database per snapshot) and
+// compares two graphs with each other.
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zheng/crag/internal/graph"
+	"github.com/zheng/crag/internal/storage"
+)
+
+// Info describes a stored snapshot
+type Info struct {
+	Name     string          `json:"name"`
+	Path     string          `json:"path"`
+	Metadata *graph.Metadata `json:"metadata,omitempty"`
+}
+
+const dbExt = ".db"
+
+// nameReplacer maps characters that are not valid in file names, e.g. "release/1.2"
+var nameReplacer = strings.NewReplacer("/", "_", "\\", "_", ":", "_")
+
+// Dir returns the default snapshot directory for a database: ".crag.db" -> ".crag.snapshots"
+func Dir(dbPath string) string {
+	return strings.TrimSuffix(dbPath, filepath.Ext(dbPath)) + ".snapshots"
+}
+
+// Path returns the database file of a named snapshot in dir
+func Path(dir, name string) string {
+	return filepath.Join(dir, nameReplacer.Replace(name)+dbExt)
+}
+
+// List returns the snapshots stored in dir, sorted by name
+func List(dir string) ([]*Info, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []*Info
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != dbExt {
+			continue
+		}
+		info := &Info{
+			Name: strings.TrimSuffix(e.Name(), dbExt),
+			Path: filepath.Join(dir, e.Name()),
+		}
+		if db, err := storage.Open(info.Path); err == nil {
+			info.Metadata, _ = db.GetMetadata()
+			if info.Metadata != nil && info.Metadata.Snapshot != "" {
+				info.Name = info.Metadata.Snapshot
+			}
+			db.Close()
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// Resolve returns the database path for a snapshot name or a database file path
+func Resolve(dir, nameOrPath string) (string, error) {
+	if p := Path(dir, nameOrPath); fileExists(p) {
+		return p, nil
+	}
+	if fileExists(nameOrPath) {
+		return nameOrPath, nil
+	}
+
+	var names []string
+	if snapshots, err := List(dir); err == nil {
+		for _, s := range snapshots {
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("未找到快照 %s (%s 中没有快照，请先运行 crag analyze --snapshot <name>)", nameOrPath, dir)
+	}
+	return "", fmt.Errorf("未找到快照 %s，可用快照: %s", nameOrPath, strings.Join(names, ", "))
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// BatchOptions configures a Batch
+type BatchOptions struct {
+	// SyncOff disables fsync (PRAGMA synchronous = OFF) while the batch is open.
+	// Faster bulk loads, but a power loss during the write may corrupt the database.
+	SyncOff bool
+}
+
+// Batch writes a graph inside a single transaction using prepared statements.
+// Its insert methods have the same signatures as the DB ones, so they can be
+// passed to the builder and analyzers directly.
+//
+// The transaction is the staging area of the next graph generation: readers
+// (in WAL mode, see Open) keep seeing the previous generation, complete, until
+// Commit swaps the new one in atomically and bumps the generation number.
+// Rollback (e.g. after a failed analysis) leaves the previous graph intact.
+type Batch struct {
+	pool *sql.DB   // the batch's own connection, see BeginBatch
+	conn *sql.Conn // pinned so that PRAGMAs apply to the transaction's connection
+	tx   *sql.Tx
+
+	generation   int64 // generation number the batch commits
+	insertNode   *sql.Stmt
+	insertEdge   *sql.Stmt
+	insertTag    *sql.Stmt
+	insertSwitch *sql.Stmt
+	insertFTS    *sql.Stmt
+	insertExt    *sql.Stmt
+}
+
+// BeginBatch starts a batched write. The batch runs on a connection of its own
+// whose transactions begin IMMEDIATE: the write lock is taken up front, so a
+// second writer (watch and analyze at once) waits for it under the busy timeout
+// instead of failing with SQLITE_BUSY when its read would turn into a write.
+func (db *DB) BeginBatch(opts BatchOptions) (*Batch, error) {
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite", dsn(db.path, false)+"&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	pool.SetMaxOpenConns(1)
+	b := &Batch{pool: pool}
+	if b.conn, err = pool.Conn(ctx); err != nil {
+		b.release()
+		return nil, err
+	}
+
+	// synchronous cannot be changed inside a transaction; the connection is
+	// closed with the batch, so nothing needs restoring
+	if opts.SyncOff {
+		if _, err := b.conn.ExecContext(ctx, "PRAGMA synchronous = OFF"); err != nil {
+			b.release()
+			return nil, err
+		}
+	}
+
+	if b.tx, err = b.conn.BeginTx(ctx, nil); err != nil {
+		b.release()
+		return nil, err
+	}
+	if b.generation, err = currentGeneration(b.tx); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	b.generation++
+
+	for _, p := range []struct {
+		stmt **sql.Stmt
+		sql  string
+	}{
+		{&b.insertNode, insertNodeSQL},
+		{&b.insertEdge, insertEdgeSQL},
+		{&b.insertTag, insertFieldTagSQL},
+		{&b.insertSwitch, insertEnumSwitchSQL},
+		{&b.insertFTS, insertNodeFTSSQL},
+		{&b.insertExt, insertExternalCallSQL},
+	} {
+		if *p.stmt, err = b.tx.Prepare(p.sql); err != nil {
+			b.Rollback()
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// InsertNode inserts a node under its stable ID, indexes it for search and returns the ID
+func (b *Batch) InsertNode(node *graph.Node) (int64, error) {
+	id, inserted, err := insertNodeStable(b.insertNode.Exec, b.tx.QueryRow, node)
+	if err != nil || !inserted {
+		return id, err
+	}
+	_, err = b.insertFTS.Exec(nodeFTSArgs(id, node)...)
+	return id, err
+}
+
+// InsertEdge inserts an edge
+func (b *Batch) InsertEdge(edge *graph.Edge) error {
+	_, err := b.insertEdge.Exec(edgeInsertArgs(edge)...)
+	return err
+}
+
+// InsertFieldTag inserts a struct field tag
+func (b *Batch) InsertFieldTag(tag *graph.FieldTag) error {
+	_, err := b.insertTag.Exec(fieldTagInsertArgs(tag)...)
+	return err
+}
+
+// InsertEnumSwitch inserts a switch over an enum type
+func (b *Batch) InsertEnumSwitch(sw *graph.EnumSwitch) error {
+	_, err := b.insertSwitch.Exec(enumSwitchInsertArgs(sw)...)
+	return err
+}
+
+// InsertExternalCall inserts a call to a function outside the graph
+func (b *Batch) InsertExternalCall(call *graph.ExternalCall) error {
+	_, err := b.insertExt.Exec(call.FromID, call.Callee, call.Package, call.CallSiteFile, call.CallSiteLine)
+	return err
+}
+
+// Clear removes the previous graph within the batch
+func (b *Batch) Clear() error {
+	return clearGraph(b.tx)
+}
+
+// DeleteNodesByPackage deletes nodes of the given packages and everything referencing them
+func (b *Batch) DeleteNodesByPackage(packages []string) (int64, error) {
+	return deleteNodesByPackage(b.tx, packages)
+}
+
+// DeleteOrphanEdges deletes edges that reference non-existent nodes
+func (b *Batch) DeleteOrphanEdges() (int64, error) {
+	return deleteOrphanEdges(b.tx)
+}
+
+// SaveMetadata replaces the analysis metadata within the batch
+func (b *Batch) SaveMetadata(meta *graph.Metadata) error {
+	return saveMetadata(b.tx, meta)
+}
+
+// SaveSourceFiles replaces the recorded source files within the batch
+func (b *Batch) SaveSourceFiles(files []*graph.SourceFile) error {
+	return saveSourceFiles(b.tx, files)
+}
+
+// Commit rebuilds the reachability index for the new graph, makes the batch
+// visible as the next generation and releases its connection
+func (b *Batch) Commit() error {
+	if err := buildReachability(b.tx); err != nil {
+		b.Rollback()
+		return err
+	}
+	_, err := b.tx.Exec(
+		`INSERT INTO metadata (key, value) VALUES ('generation', ?)
+		 ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+		strconv.FormatInt(b.generation, 10),
+	)
+	if err != nil {
+		b.Rollback()
+		return err
+	}
+	err = b.tx.Commit()
+	b.release()
+	return err
+}
+
+// Generation returns the number of the last committed graph generation (0 if none)
+func (db *DB) Generation() (int64, error) {
+	return currentGeneration(db.conn)
+}
+
+func currentGeneration(q queryer) (int64, error) {
+	var value string
+	err := q.QueryRow(`SELECT value FROM metadata WHERE key = 'generation'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Rollback discards the batch; it is a no-op after Commit
+func (b *Batch) Rollback() error {
+	if b.conn == nil {
+		return nil
+	}
+	err := b.tx.Rollback()
+	b.release()
+	return err
+}
+
+// release closes the batch's connection
+func (b *Batch) release() {
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+	if b.pool != nil {
+		b.pool.Close()
+		b.pool = nil
+	}
+}
@@ -3,6 +3,9 @@ package storage
 import (
 	"database/sql"
 	_ "embed"
+	"fmt"
+	"os"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -10,31 +13,113 @@ import (
 //go:embed schema.sql
 var schema string
 
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 // DB wraps the SQLite database connection
 type DB struct {
-	conn *sql.DB
+	conn     *sql.DB
+	path     string
+	readOnly bool
 }
 
-// Open opens or creates a SQLite database at the given path
+// busyTimeoutMs is how long a connection waits for another process's lock
+// (e.g. a reader while analyze commits) before failing with "database is locked"
+const busyTimeoutMs = 10000
+
+// dsn builds the connection URI for a database file. Every pooled connection
+// gets foreign keys and the busy timeout.
+func dsn(path string, readOnly bool) string {
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	uri := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(%d)", escaped, busyTimeoutMs)
+	if readOnly {
+		uri += "&mode=ro"
+	}
+	return uri
+}
+
+// Open opens or creates a SQLite database at the given path, switches it to WAL
+// mode and applies any pending schema migrations. Databases written by a newer
+// crag are rejected.
+//
+// In WAL mode readers never block the writer and keep seeing the last committed
+// graph while analyze or watch writes the next one in a Batch.
 func Open(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite", path)
+	db, err := OpenWithoutMigrate(path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Enable foreign keys
-	if _, err := conn.Exec("PRAGMA foreign_keys = ON"); err != nil {
+	if _, err := db.conn.Exec("PRAGMA journal_mode = WAL"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenWithoutMigrate opens a SQLite database without touching its schema,
+// e.g. to inspect its version before migrating
+func OpenWithoutMigrate(path string) (*DB, error) {
+	conn, err := sql.Open("sqlite", dsn(path, false))
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
 		conn.Close()
 		return nil, err
 	}
+	return &DB{conn: conn, path: path}, nil
+}
+
+// OpenReadOnly opens an existing database for long-running readers such as
+// crag mcp and crag view. The database is migrated and switched to WAL mode
+// first, then reopened read-only, so a reader can never block or damage a
+// concurrent analysis. User notes are the only writes; they use a short-lived
+// writable connection.
+func OpenReadOnly(path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("数据库不存在: %s (请先运行 crag analyze)", path)
+	}
+	db, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	db.Close()
 
-	// Initialize schema
-	if _, err := conn.Exec(schema); err != nil {
+	conn, err := sql.Open("sqlite", dsn(path, true))
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
 		conn.Close()
 		return nil, err
 	}
+	return &DB{conn: conn, path: path, readOnly: true}, nil
+}
 
-	return &DB{conn: conn}, nil
+// writer returns a connection that accepts writes and a function to release it
+func (db *DB) writer() (*sql.DB, func(), error) {
+	if !db.readOnly {
+		return db.conn, func() {}, nil
+	}
+	conn, err := sql.Open("sqlite", dsn(db.path, false))
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, func() { conn.Close() }, nil
 }
 
 // Close closes the database connection
@@ -44,7 +129,11 @@ func (db *DB) Close() error {
 
 // Clear removes all data from the database
 func (db *DB) Clear() error {
-	_, err := db.conn.Exec("DELETE FROM edges; DELETE FROM nodes;")
+	return clearGraph(db.conn)
+}
+
+func clearGraph(e execer) error {
+	_, err := e.Exec("DELETE FROM reach_nodes; DELETE FROM reach_sccs; DELETE FROM reach_edges; DELETE FROM nodes_fts; DELETE FROM external_calls; DELETE FROM enum_switches; DELETE FROM field_tags; DELETE FROM edges; DELETE FROM nodes;")
 	return err
 }
 
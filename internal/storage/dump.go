@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// GetAllNodes returns the nodes of every kind, ordered by ID
+func (db *DB) GetAllNodes() ([]*graph.Node, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value, end_line FROM nodes ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNodesWithEndLine(rows)
+}
+
+// GetAllFieldTags returns every struct field tag, ordered by field and key
+func (db *DB) GetAllFieldTags() ([]*graph.FieldTag, error) {
+	rows, err := db.conn.Query(
+		`SELECT field_id, key, name, COALESCE(options, '') FROM field_tags ORDER BY field_id, key, name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*graph.FieldTag
+	for rows.Next() {
+		var t graph.FieldTag
+		if err := rows.Scan(&t.FieldID, &t.Key, &t.Name, &t.Options); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &t)
+	}
+	return tags, rows.Err()
+}
+
+// GetAllEnumSwitches returns every switch over an enum type, ordered by enum and position
+func (db *DB) GetAllEnumSwitches() ([]*graph.EnumSwitch, error) {
+	rows, err := db.conn.Query(
+		`SELECT enum_id, func_id, file, line, COALESCE(missing, ''), has_default
+		 FROM enum_switches ORDER BY enum_id, func_id, file, line`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var switches []*graph.EnumSwitch
+	for rows.Next() {
+		var sw graph.EnumSwitch
+		var missing string
+		if err := rows.Scan(&sw.EnumID, &sw.FuncID, &sw.File, &sw.Line, &missing, &sw.HasDefault); err != nil {
+			return nil, err
+		}
+		if missing != "" {
+			sw.Missing = strings.Split(missing, ",")
+		}
+		switches = append(switches, &sw)
+	}
+	return switches, rows.Err()
+}
+
+// RestoreNode inserts a node under the ID it already has, e.g. when loading a dump
+func (b *Batch) RestoreNode(node *graph.Node) error {
+	if _, err := b.tx.Exec(insertNodeSQL, nodeInsertArgs(node.ID, node)...); err != nil {
+		return err
+	}
+	_, err := b.insertFTS.Exec(nodeFTSArgs(node.ID, node)...)
+	return err
+}
+
+// RestoreNote adds a note with its original creation time unless the node
+// already has a note with the same text. It reports whether the note was added.
+func (b *Batch) RestoreNote(note *graph.Note) (bool, error) {
+	var exists int
+	err := b.tx.QueryRow(
+		`SELECT 1 FROM annotations WHERE node_name = ? AND text = ?`, note.NodeName, note.Text,
+	).Scan(&exists)
+	if err == nil {
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+	_, err = b.tx.Exec(
+		`INSERT INTO annotations (node_name, text, created_at) VALUES (?, ?, ?)`,
+		note.NodeName, note.Text, note.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	return err == nil, err
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// GetAllExternalCalls returns every call to a function outside the graph,
+// ordered by caller, callee and call site
+func (db *DB) GetAllExternalCalls() ([]*graph.ExternalCall, error) {
+	rows, err := db.conn.Query(
+		`SELECT from_id, callee, package, call_site_file, call_site_line FROM external_calls
+		 ORDER BY from_id, callee, call_site_file, call_site_line`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []*graph.ExternalCall
+	for rows.Next() {
+		var c graph.ExternalCall
+		var callSiteFile sql.NullString
+		var callSiteLine sql.NullInt64
+		if err := rows.Scan(&c.FromID, &c.Callee, &c.Package, &callSiteFile, &callSiteLine); err != nil {
+			return nil, err
+		}
+		c.CallSiteFile = callSiteFile.String
+		c.CallSiteLine = int(callSiteLine.Int64)
+		calls = append(calls, &c)
+	}
+	return calls, rows.Err()
+}
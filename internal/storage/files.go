@@ -0,0 +1,37 @@
+package storage
+
+import "github.com/zheng/crag/internal/graph"
+
+// GetSourceFiles returns the source files recorded by the last analysis, ordered by path
+func (db *DB) GetSourceFiles() ([]*graph.SourceFile, error) {
+	rows, err := db.conn.Query(`SELECT path, package, hash, mtime, size FROM files ORDER BY path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []*graph.SourceFile
+	for rows.Next() {
+		var f graph.SourceFile
+		if err := rows.Scan(&f.Path, &f.Package, &f.Hash, &f.ModTime, &f.Size); err != nil {
+			return nil, err
+		}
+		files = append(files, &f)
+	}
+	return files, rows.Err()
+}
+
+func saveSourceFiles(e execer, files []*graph.SourceFile) error {
+	if _, err := e.Exec(`DELETE FROM files`); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if _, err := e.Exec(
+			`INSERT INTO files (path, package, hash, mtime, size) VALUES (?, ?, ?, ?, ?)`,
+			f.Path, f.Package, f.Hash, f.ModTime, f.Size,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// Incremental applies a complete re-analysis to a batch as an incremental update.
+//
+// Nodes of the target packages are deleted up front and re-inserted. Every other
+// node the analysis produces is matched to its existing row (same kind, name and
+// position) instead of being inserted again, so its ID and its edges are kept.
+// Edges between two kept nodes are only inserted when missing, and Finish deletes
+// the kept nodes and edges the analysis no longer produced, so the result matches
+// a full analysis.
+type Incremental struct {
+	batch *Batch
+
+	existing  map[string][]int64 // node key -> kept node IDs not matched yet
+	kept      map[int64]bool     // IDs of kept nodes
+	seenNodes map[int64]bool     // kept nodes produced by the analysis
+	keptEdges map[edgeKey]int64  // edges between kept nodes -> edge ID
+	seenEdges map[edgeKey]bool   // kept edges produced by the analysis
+}
+
+type edgeKey struct {
+	from, to int64
+	kind     graph.EdgeKind
+}
+
+func nodeKey(kind graph.NodeKind, name, file string, line int) string {
+	return fmt.Sprintf("%s|%s|%s|%d", kind, name, file, line)
+}
+
+// BeginIncremental deletes the target packages from the batch and loads what is kept
+func (b *Batch) BeginIncremental(targets []string) (*Incremental, int64, error) {
+	deleted, err := b.DeleteNodesByPackage(targets)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := b.DeleteOrphanEdges(); err != nil {
+		return nil, 0, err
+	}
+
+	inc := &Incremental{
+		batch:     b,
+		existing:  make(map[string][]int64),
+		kept:      make(map[int64]bool),
+		seenNodes: make(map[int64]bool),
+		keptEdges: make(map[edgeKey]int64),
+		seenEdges: make(map[edgeKey]bool),
+	}
+
+	rows, err := b.tx.Query(`SELECT id, kind, name, file, line FROM nodes ORDER BY id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	for rows.Next() {
+		var id int64
+		var kind graph.NodeKind
+		var name, file string
+		var line int
+		if err := rows.Scan(&id, &kind, &name, &file, &line); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		key := nodeKey(kind, name, file, line)
+		inc.existing[key] = append(inc.existing[key], id)
+		inc.kept[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err = b.tx.Query(`SELECT id, from_id, to_id, kind FROM edges`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var k edgeKey
+		if err := rows.Scan(&id, &k.from, &k.to, &k.kind); err != nil {
+			return nil, 0, err
+		}
+		inc.keptEdges[k] = id
+	}
+	return inc, deleted, rows.Err()
+}
+
+// InsertNode returns the ID of the matching kept node, or inserts a new one
+func (inc *Incremental) InsertNode(node *graph.Node) (int64, error) {
+	key := nodeKey(node.Kind, node.Name, node.File, node.Line)
+	if ids := inc.existing[key]; len(ids) > 0 {
+		inc.existing[key] = ids[1:]
+		inc.seenNodes[ids[0]] = true
+		return ids[0], nil
+	}
+	return inc.batch.InsertNode(node)
+}
+
+// InsertEdge inserts an edge unless it joins two kept nodes and already exists
+func (inc *Incremental) InsertEdge(edge *graph.Edge) error {
+	if inc.kept[edge.FromID] && inc.kept[edge.ToID] {
+		k := edgeKey{edge.FromID, edge.ToID, edge.Kind}
+		if _, ok := inc.keptEdges[k]; ok {
+			inc.seenEdges[k] = true
+			return nil
+		}
+	}
+	return inc.batch.InsertEdge(edge)
+}
+
+// InsertFieldTag inserts the tags of new fields; kept fields keep theirs
+func (inc *Incremental) InsertFieldTag(tag *graph.FieldTag) error {
+	if inc.kept[tag.FieldID] {
+		return nil
+	}
+	return inc.batch.InsertFieldTag(tag)
+}
+
+// InsertEnumSwitch inserts switches involving a new enum or function
+func (inc *Incremental) InsertEnumSwitch(sw *graph.EnumSwitch) error {
+	if inc.kept[sw.EnumID] && inc.kept[sw.FuncID] {
+		return nil
+	}
+	return inc.batch.InsertEnumSwitch(sw)
+}
+
+// InsertExternalCall inserts the external calls of new functions; kept functions keep theirs
+func (inc *Incremental) InsertExternalCall(call *graph.ExternalCall) error {
+	if inc.kept[call.FromID] {
+		return nil
+	}
+	return inc.batch.InsertExternalCall(call)
+}
+
+// Finish deletes the kept nodes and edges that the analysis did not produce again
+func (inc *Incremental) Finish() (staleNodes, staleEdges int64, err error) {
+	var edgeIDs []int64
+	for k, id := range inc.keptEdges {
+		if !inc.seenEdges[k] {
+			edgeIDs = append(edgeIDs, id)
+		}
+	}
+	var nodeIDs []int64
+	for id := range inc.kept {
+		if !inc.seenNodes[id] {
+			nodeIDs = append(nodeIDs, id)
+		}
+	}
+
+	// Stale edges first: edges of stale nodes are removed with the nodes
+	for _, chunk := range chunkIDs(edgeIDs) {
+		res, err := inc.batch.tx.Exec(`DELETE FROM edges WHERE id IN (` + chunk + `)`)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, _ := res.RowsAffected()
+		staleEdges += n
+	}
+	for _, chunk := range chunkIDs(nodeIDs) {
+		n, err := deleteNodesByID(inc.batch.tx, chunk)
+		if err != nil {
+			return 0, 0, err
+		}
+		staleNodes += n
+	}
+	return staleNodes, staleEdges, nil
+}
+
+// chunkIDs formats IDs as comma-separated lists small enough for one statement
+func chunkIDs(ids []int64) []string {
+	const size = 500
+	var chunks []string
+	for start := 0; start < len(ids); start += size {
+		end := min(start+size, len(ids))
+		parts := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			parts = append(parts, fmt.Sprint(id))
+		}
+		chunks = append(chunks, strings.Join(parts, ","))
+	}
+	return chunks
+}
+
+// deleteNodesByID deletes the nodes with the given comma-separated IDs and the rows referencing them
+func deleteNodesByID(e execer, ids string) (int64, error) {
+	for _, q := range []string{
+		`DELETE FROM edges WHERE from_id IN (` + ids + `) OR to_id IN (` + ids + `)`,
+		`DELETE FROM field_tags WHERE field_id IN (` + ids + `)`,
+		`DELETE FROM external_calls WHERE from_id IN (` + ids + `)`,
+		`DELETE FROM enum_switches WHERE enum_id IN (` + ids + `) OR func_id IN (` + ids + `)`,
+		`DELETE FROM nodes_fts WHERE rowid IN (` + ids + `)`,
+	} {
+		if _, err := e.Exec(q); err != nil {
+			return 0, err
+		}
+	}
+	res, err := e.Exec(`DELETE FROM nodes WHERE id IN (` + ids + `)`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
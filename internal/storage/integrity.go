@@ -0,0 +1,97 @@
+package storage
+
+// Orphans counts rows that reference nodes no longer in the graph. A full
+// analysis never leaves any; they come from interrupted or hand-edited
+// databases, or from incremental runs of older crag versions.
+type Orphans struct {
+	Edges         int64 `json:"edges"`
+	FieldTags     int64 `json:"field_tags"`
+	EnumSwitches  int64 `json:"enum_switches"`
+	ExternalCalls int64 `json:"external_calls"`
+}
+
+// Total returns the number of orphaned rows of all kinds
+func (o *Orphans) Total() int64 {
+	return o.Edges + o.FieldTags + o.EnumSwitches + o.ExternalCalls
+}
+
+// orphanConditions selects the orphaned rows of each table referencing nodes
+var orphanConditions = []struct {
+	table, where string
+	count        func(*Orphans) *int64
+}{
+	{"edges", `from_id NOT IN (SELECT id FROM nodes) OR to_id NOT IN (SELECT id FROM nodes)`,
+		func(o *Orphans) *int64 { return &o.Edges }},
+	{"field_tags", `field_id NOT IN (SELECT id FROM nodes)`,
+		func(o *Orphans) *int64 { return &o.FieldTags }},
+	{"enum_switches", `enum_id NOT IN (SELECT id FROM nodes) OR func_id NOT IN (SELECT id FROM nodes)`,
+		func(o *Orphans) *int64 { return &o.EnumSwitches }},
+	{"external_calls", `from_id NOT IN (SELECT id FROM nodes)`,
+		func(o *Orphans) *int64 { return &o.ExternalCalls }},
+}
+
+// CountOrphans counts the rows referencing missing nodes
+func (db *DB) CountOrphans() (*Orphans, error) {
+	o := &Orphans{}
+	for _, c := range orphanConditions {
+		if err := db.conn.QueryRow(`SELECT COUNT(*) FROM ` + c.table + ` WHERE ` + c.where).Scan(c.count(o)); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// DeleteOrphans deletes the rows referencing missing nodes within the batch
+func (b *Batch) DeleteOrphans() (*Orphans, error) {
+	o := &Orphans{}
+	for _, c := range orphanConditions {
+		result, err := b.tx.Exec(`DELETE FROM ` + c.table + ` WHERE ` + c.where)
+		if err != nil {
+			return nil, err
+		}
+		if *c.count(o), err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// DuplicateName is a qualified name shared by several nodes
+type DuplicateName struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// FindDuplicateNames returns names carried by more than one node, which makes
+// lookups by name ambiguous. A named type recorded both as a struct and as
+// an enum is expected and not reported.
+func (db *DB) FindDuplicateNames() ([]*DuplicateName, error) {
+	rows, err := db.conn.Query(
+		`SELECT name, COUNT(*) FROM nodes WHERE kind != 'enum'
+		 GROUP BY name HAVING COUNT(*) > 1 ORDER BY name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*DuplicateName
+	for rows.Next() {
+		var d DuplicateName
+		if err := rows.Scan(&d.Name, &d.Count); err != nil {
+			return nil, err
+		}
+		result = append(result, &d)
+	}
+	return result, rows.Err()
+}
+
+// CountUnindexedFuncs returns the number of functions missing from the
+// reachability index (see buildReachability), which is rebuilt on every commit
+func (db *DB) CountUnindexedFuncs() (int, error) {
+	var count int
+	err := db.conn.QueryRow(
+		`SELECT COUNT(*) FROM nodes WHERE kind = 'func' AND id NOT IN (SELECT node_id FROM reach_nodes)`,
+	).Scan(&count)
+	return count, err
+}
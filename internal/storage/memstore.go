@@ -0,0 +1,567 @@
+package storage
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// memRefreshInterval bounds how often a MemStore checks the database for a newer graph
+const memRefreshInterval = 2 * time.Second
+
+// memGraph is an immutable adjacency-list snapshot of the nodes and edges tables
+type memGraph struct {
+	version string
+	nodes   map[int64]*graph.Node
+	byName  map[string]*graph.Node
+	order   []*graph.Node // by ID
+	edges   []*graph.Edge
+	out     map[int64][]*graph.Edge
+	in      map[int64][]*graph.Edge
+	reach   *reachIndex
+}
+
+// MemStore is a Store that keeps the nodes and edges in memory and answers
+// lookups and traversals from adjacency lists. Queries that are not graph
+// traversals (pattern search, tags, enums, summaries) go to the embedded DB.
+// The graph is reloaded when the database is rewritten, e.g. by crag watch.
+type MemStore struct {
+	*DB
+
+	mu        sync.Mutex // serializes reloads
+	g         atomic.Pointer[memGraph]
+	checkedAt atomic.Int64 // unix nanos of the last version check
+}
+
+// NewMemStore loads the graph stored in db into memory
+func NewMemStore(db *DB) (*MemStore, error) {
+	m := &MemStore{DB: db}
+	g, err := loadMemGraph(db)
+	if err != nil {
+		return nil, err
+	}
+	m.g.Store(g)
+	m.checkedAt.Store(time.Now().UnixNano())
+	return m, nil
+}
+
+// graphVersion identifies the stored graph; it changes whenever an analysis
+// commits a new generation. The other parts cover graphs written by older crag.
+func graphVersion(q queryer) (string, error) {
+	var version string
+	err := q.QueryRow(`SELECT
+		COALESCE((SELECT value FROM metadata WHERE key = 'generation'), '') || '/' ||
+		COALESCE((SELECT value FROM metadata WHERE key = 'analyzed_at'), '') || '/' ||
+		COALESCE((SELECT MAX(id) FROM nodes), 0) || '/' ||
+		COALESCE((SELECT MAX(id) FROM edges), 0)`).Scan(&version)
+	return version, err
+}
+
+// loadMemGraph reads the graph and its version in one read transaction, so the
+// nodes and edges always come from the same committed generation
+func loadMemGraph(db *DB) (*memGraph, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := graphVersion(tx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value, end_line FROM nodes ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := scanNodesWithEndLine(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	edges, err := queryAllEdges(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &memGraph{
+		version: version,
+		nodes:   make(map[int64]*graph.Node, len(nodes)),
+		byName:  make(map[string]*graph.Node, len(nodes)),
+		order:   nodes,
+		out:     make(map[int64][]*graph.Edge),
+		in:      make(map[int64][]*graph.Edge),
+	}
+	for _, n := range nodes {
+		g.nodes[n.ID] = n
+		if _, ok := g.byName[n.Name]; !ok {
+			g.byName[n.Name] = n
+		}
+	}
+	for _, e := range edges {
+		// Edges whose nodes are gone would be dropped by the SQL joins too
+		if g.nodes[e.FromID] == nil || g.nodes[e.ToID] == nil {
+			continue
+		}
+		g.edges = append(g.edges, e)
+		g.out[e.FromID] = append(g.out[e.FromID], e)
+		g.in[e.ToID] = append(g.in[e.ToID], e)
+	}
+
+	// Same index as the stored one (see Batch.Commit), built from the snapshot
+	var funcs []int64
+	var calls [][2]int64
+	for _, n := range nodes {
+		if n.Kind == graph.NodeKindFunc {
+			funcs = append(funcs, n.ID)
+		}
+	}
+	for _, e := range g.edges {
+		if e.Kind == graph.EdgeKindCalls {
+			calls = append(calls, [2]int64{e.FromID, e.ToID})
+		}
+	}
+	g.reach = buildReachIndex(funcs, calls)
+	return g, nil
+}
+
+// graph returns the current in-memory graph, reloading it if the database changed
+func (m *MemStore) graph() *memGraph {
+	g := m.g.Load()
+	if time.Now().UnixNano()-m.checkedAt.Load() < int64(memRefreshInterval) {
+		return g
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UnixNano()
+	if now-m.checkedAt.Load() < int64(memRefreshInterval) {
+		return m.g.Load()
+	}
+	m.checkedAt.Store(now)
+
+	// On errors keep serving the graph we have
+	version, err := graphVersion(m.DB.conn)
+	if err != nil || version == g.version {
+		return g
+	}
+	fresh, err := loadMemGraph(m.DB)
+	if err != nil {
+		return g
+	}
+	m.g.Store(fresh)
+	return fresh
+}
+
+// copyNode returns a copy so that callers cannot modify the shared graph
+func copyNode(n *graph.Node) *graph.Node {
+	c := *n
+	return &c
+}
+
+func copyEdge(e *graph.Edge) *graph.Edge {
+	c := *e
+	return &c
+}
+
+func sortByName(nodes []*graph.Node) {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+}
+
+// neighbors returns the nodes on the other end of the node's edges of the given kind,
+// one per edge like the SQL joins. upstream follows incoming edges.
+func (g *memGraph) neighbors(id int64, kind graph.EdgeKind, upstream bool) []*graph.Node {
+	edges := g.out[id]
+	if upstream {
+		edges = g.in[id]
+	}
+	var result []*graph.Node
+	for _, e := range edges {
+		if e.Kind != kind {
+			continue
+		}
+		other := e.ToID
+		if upstream {
+			other = e.FromID
+		}
+		result = append(result, copyNode(g.nodes[other]))
+	}
+	return result
+}
+
+// walk traverses call edges breadth-first and returns every reachable node
+// with its shortest distance, ordered by depth. maxDepth 0 means no limit.
+// The start node is included only if it is reachable through a cycle.
+func (g *memGraph) walk(id int64, upstream bool, maxDepth int) []*NodeWithDepth {
+	var result []*NodeWithDepth
+	seen := make(map[int64]bool)
+	frontier := []int64{id}
+	for depth := 1; len(frontier) > 0 && (maxDepth == 0 || depth <= maxDepth); depth++ {
+		var next []int64
+		for _, cur := range frontier {
+			edges := g.out[cur]
+			if upstream {
+				edges = g.in[cur]
+			}
+			for _, e := range edges {
+				if e.Kind != graph.EdgeKindCalls {
+					continue
+				}
+				other := e.ToID
+				if upstream {
+					other = e.FromID
+				}
+				if seen[other] {
+					continue
+				}
+				seen[other] = true
+				next = append(next, other)
+				result = append(result, &NodeWithDepth{Node: copyNode(g.nodes[other]), Depth: depth})
+			}
+		}
+		frontier = next
+	}
+	return result
+}
+
+func (g *memGraph) callTree(id int64, upstream bool, maxDepth int) []*CallTreeNode {
+	children := g.neighbors(id, graph.EdgeKindCalls, upstream)
+	result := make([]*CallTreeNode, len(children))
+	for i, c := range children {
+		result[i] = &CallTreeNode{Node: c}
+		if maxDepth != 1 {
+			result[i].Children = g.callTree(c.ID, upstream, maxDepth-1)
+		}
+	}
+	return result
+}
+
+func (g *memGraph) nodesOfKind(kind graph.NodeKind) []*graph.Node {
+	var result []*graph.Node
+	for _, n := range g.order {
+		if n.Kind == kind {
+			result = append(result, copyNode(n))
+		}
+	}
+	return result
+}
+
+func (g *memGraph) directCallerCount(id int64) int {
+	count := 0
+	for _, e := range g.in[id] {
+		if e.Kind == graph.EdgeKindCalls {
+			count++
+		}
+	}
+	return count
+}
+
+// ==================== Node Lookup ====================
+
+// GetNodeByName returns a node by its full name
+func (m *MemStore) GetNodeByName(name string) (*graph.Node, error) {
+	n, ok := m.graph().byName[name]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyNode(n), nil
+}
+
+// GetNodeByID returns a node by its ID
+func (m *MemStore) GetNodeByID(id int64) (*graph.Node, error) {
+	n, ok := m.graph().nodes[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyNode(n), nil
+}
+
+// GetAllFunctions returns all function nodes
+func (m *MemStore) GetAllFunctions() ([]*graph.Node, error) {
+	return m.graph().nodesOfKind(graph.NodeKindFunc), nil
+}
+
+// GetNodesByKinds returns all nodes of the given kinds, ordered by name
+func (m *MemStore) GetNodesByKinds(kinds ...graph.NodeKind) ([]*graph.Node, error) {
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	want := make(map[graph.NodeKind]bool, len(kinds))
+	for _, k := range kinds {
+		want[k] = true
+	}
+	var result []*graph.Node
+	for _, n := range m.graph().order {
+		if want[n.Kind] {
+			result = append(result, copyNode(n))
+		}
+	}
+	sortByName(result)
+	return result, nil
+}
+
+// GetNodesByPackage returns all nodes belonging to the given packages
+func (m *MemStore) GetNodesByPackage(packages []string) ([]*graph.Node, error) {
+	if len(packages) == 0 {
+		return nil, nil
+	}
+	want := make(map[string]bool, len(packages))
+	for _, p := range packages {
+		want[p] = true
+	}
+	var result []*graph.Node
+	for _, n := range m.graph().order {
+		if want[n.Package] {
+			result = append(result, copyNode(n))
+		}
+	}
+	return result, nil
+}
+
+// GetNodesInFiles returns the nodes declared in the given files, ordered by file and line
+func (m *MemStore) GetNodesInFiles(files []string) ([]*graph.Node, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	want := make(map[string]bool, len(files))
+	for _, f := range files {
+		want[f] = true
+	}
+	var result []*graph.Node
+	for _, n := range m.graph().order {
+		if want[n.File] {
+			result = append(result, copyNode(n))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].File != result[j].File {
+			return result[i].File < result[j].File
+		}
+		return result[i].Line < result[j].Line
+	})
+	return result, nil
+}
+
+// GetAllEdges returns all edges
+func (m *MemStore) GetAllEdges() ([]*graph.Edge, error) {
+	g := m.graph()
+	result := make([]*graph.Edge, len(g.edges))
+	for i, e := range g.edges {
+		result[i] = copyEdge(e)
+	}
+	return result, nil
+}
+
+// GetStats returns node and edge counts
+func (m *MemStore) GetStats() (nodeCount, edgeCount int64, err error) {
+	g := m.graph()
+	return int64(len(g.order)), int64(len(g.edges)), nil
+}
+
+// ==================== Call Graph Traversal ====================
+
+// GetDirectCallers returns functions that directly call the given function
+func (m *MemStore) GetDirectCallers(nodeID int64) ([]*graph.Node, error) {
+	return m.graph().neighbors(nodeID, graph.EdgeKindCalls, true), nil
+}
+
+// GetDirectCallees returns functions that the given function directly calls
+func (m *MemStore) GetDirectCallees(nodeID int64) ([]*graph.Node, error) {
+	return m.graph().neighbors(nodeID, graph.EdgeKindCalls, false), nil
+}
+
+// GetUpstreamCallers returns all upstream callers up to maxDepth (0 = no limit)
+func (m *MemStore) GetUpstreamCallers(nodeID int64, maxDepth int) ([]*graph.Node, error) {
+	return withoutDepth(m.graph().walk(nodeID, true, maxDepth)), nil
+}
+
+// GetDownstreamCallees returns all downstream callees up to maxDepth (0 = no limit)
+func (m *MemStore) GetDownstreamCallees(nodeID int64, maxDepth int) ([]*graph.Node, error) {
+	return withoutDepth(m.graph().walk(nodeID, false, maxDepth)), nil
+}
+
+// GetUpstreamCallersWithDepth returns all upstream callers with their shortest depth
+func (m *MemStore) GetUpstreamCallersWithDepth(nodeID int64, maxDepth int) ([]*NodeWithDepth, error) {
+	return m.graph().walk(nodeID, true, maxDepth), nil
+}
+
+// GetDownstreamCalleesWithDepth returns all downstream callees with their shortest depth
+func (m *MemStore) GetDownstreamCalleesWithDepth(nodeID int64, maxDepth int) ([]*NodeWithDepth, error) {
+	return m.graph().walk(nodeID, false, maxDepth), nil
+}
+
+// GetUpstreamCallTree builds a tree of upstream callers
+func (m *MemStore) GetUpstreamCallTree(nodeID int64, maxDepth int) ([]*CallTreeNode, error) {
+	return m.graph().callTree(nodeID, true, maxDepth), nil
+}
+
+// GetDownstreamCallTree builds a tree of downstream callees
+func (m *MemStore) GetDownstreamCallTree(nodeID int64, maxDepth int) ([]*CallTreeNode, error) {
+	return m.graph().callTree(nodeID, false, maxDepth), nil
+}
+
+// GetCallEdgesForNode returns all call edges where the node is the caller
+func (m *MemStore) GetCallEdgesForNode(nodeID int64) ([]*graph.Edge, error) {
+	var result []*graph.Edge
+	for _, e := range m.graph().out[nodeID] {
+		if e.Kind == graph.EdgeKindCalls {
+			result = append(result, copyEdge(e))
+		}
+	}
+	return result, nil
+}
+
+func withoutDepth(nodes []*NodeWithDepth) []*graph.Node {
+	result := make([]*graph.Node, len(nodes))
+	for i, n := range nodes {
+		result[i] = n.Node
+	}
+	return result
+}
+
+// ==================== Interfaces, Types, Vars and Consts ====================
+
+// GetAllInterfaces returns all interface nodes
+func (m *MemStore) GetAllInterfaces() ([]*graph.Node, error) {
+	return m.graph().nodesOfKind(graph.NodeKindInterface), nil
+}
+
+// GetImplementations returns all types that implement a given interface
+func (m *MemStore) GetImplementations(interfaceID int64) ([]*graph.Node, error) {
+	return m.graph().neighbors(interfaceID, graph.EdgeKindImplements, true), nil
+}
+
+// GetImplementedInterfaces returns all interfaces that a type implements
+func (m *MemStore) GetImplementedInterfaces(typeID int64) ([]*graph.Node, error) {
+	return m.graph().neighbors(typeID, graph.EdgeKindImplements, false), nil
+}
+
+// GetAllTypes returns all struct/type nodes
+func (m *MemStore) GetAllTypes() ([]*graph.Node, error) {
+	return m.graph().nodesOfKind(graph.NodeKindStruct), nil
+}
+
+// GetTypeAssertingFunctions returns functions that type-assert or type-switch on the given type
+func (m *MemStore) GetTypeAssertingFunctions(typeID int64) ([]*graph.Node, error) {
+	result := m.graph().neighbors(typeID, graph.EdgeKindAssertsType, true)
+	sortByName(result)
+	return result, nil
+}
+
+// GetAllVars returns all package-level variable nodes
+func (m *MemStore) GetAllVars() ([]*graph.Node, error) {
+	return m.graph().nodesOfKind(graph.NodeKindVar), nil
+}
+
+// GetAllConsts returns all package-level constant nodes
+func (m *MemStore) GetAllConsts() ([]*graph.Node, error) {
+	return m.graph().nodesOfKind(graph.NodeKindConst), nil
+}
+
+// GetReferencingFunctions returns all functions that reference the given var/const
+func (m *MemStore) GetReferencingFunctions(nodeID int64) ([]*graph.Node, error) {
+	return m.graph().neighbors(nodeID, graph.EdgeKindReferences, true), nil
+}
+
+// GetReferencedVarConsts returns all vars/consts that the given function references
+func (m *MemStore) GetReferencedVarConsts(funcID int64) ([]*graph.Node, error) {
+	return m.graph().neighbors(funcID, graph.EdgeKindReferences, false), nil
+}
+
+// GetDerivedVarConsts returns vars/consts whose initializers depend on the given node,
+// directly or through other derived vars/consts
+func (m *MemStore) GetDerivedVarConsts(nodeID int64) ([]*graph.Node, error) {
+	g := m.graph()
+	seen := map[int64]bool{nodeID: true}
+	queue := []int64{nodeID}
+	var result []*graph.Node
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range g.in[cur] {
+			if e.Kind != graph.EdgeKindInitializes || seen[e.FromID] {
+				continue
+			}
+			seen[e.FromID] = true
+			queue = append(queue, e.FromID)
+			result = append(result, copyNode(g.nodes[e.FromID]))
+		}
+	}
+	sortByName(result)
+	return result, nil
+}
+
+// ==================== Risk Scores ====================
+
+// GetDirectCallerCount returns the number of direct callers for a node
+func (m *MemStore) GetDirectCallerCount(nodeID int64) (int, error) {
+	return m.graph().directCallerCount(nodeID), nil
+}
+
+// GetTotalCallerCount returns the number of distinct functions that reach a node
+func (m *MemStore) GetTotalCallerCount(nodeID int64) (int, error) {
+	return m.graph().reach.totalCallers(nodeID), nil
+}
+
+// GetMaxCallDepth returns the length of the longest caller chain above a node,
+// counting each recursion cycle as one level
+func (m *MemStore) GetMaxCallDepth(nodeID int64) (int, error) {
+	return m.graph().reach.maxDepth(nodeID), nil
+}
+
+// Reaches reports whether function from calls function to, directly or not
+func (m *MemStore) Reaches(fromID, toID int64) (bool, error) {
+	return m.graph().reach.reaches(fromID, toID), nil
+}
+
+// GetRiskScore calculates the risk score for a function
+func (m *MemStore) GetRiskScore(nodeID int64) (*RiskScore, error) {
+	g := m.graph()
+	node, ok := g.nodes[nodeID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	direct := g.directCallerCount(nodeID)
+	total := g.reach.totalCallers(nodeID)
+	return &RiskScore{
+		Node:          copyNode(node),
+		DirectCallers: direct,
+		TotalCallers:  total,
+		MaxDepth:      g.reach.maxDepth(nodeID),
+		RiskLevel:     CalculateRiskLevel(direct, total),
+	}, nil
+}
+
+// GetTopRiskyFunctions returns the functions with the highest risk (see SortRiskScores)
+func (m *MemStore) GetTopRiskyFunctions(limit int) ([]*RiskScore, error) {
+	g := m.graph()
+	var results []*RiskScore
+	for _, n := range g.order {
+		if n.Kind != graph.NodeKindFunc {
+			continue
+		}
+		direct := g.directCallerCount(n.ID)
+		total := g.reach.totalCallers(n.ID)
+		results = append(results, &RiskScore{
+			Node:          n,
+			DirectCallers: direct,
+			TotalCallers:  total,
+			MaxDepth:      g.reach.maxDepth(n.ID),
+			RiskLevel:     CalculateRiskLevel(direct, total),
+		})
+	}
+	SortRiskScores(results)
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	for _, r := range results {
+		r.Node = copyNode(r.Node)
+	}
+	return results, nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// SaveMetadata replaces the analysis metadata of the database
+func (db *DB) SaveMetadata(meta *graph.Metadata) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveMetadata(tx, meta); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveMetadata(e execer, meta *graph.Metadata) error {
+	values := map[string]string{
+		"project_root":  meta.ProjectRoot,
+		"module_path":   meta.ModulePath,
+		"git_commit":    meta.GitCommit,
+		"git_dirty":     strconv.FormatBool(meta.GitDirty),
+		"go_version":    meta.GoVersion,
+		"crag_version":  meta.CragVersion,
+		"algorithm":     meta.Algorithm,
+		"flags":         meta.Flags,
+		"incremental":   strconv.FormatBool(meta.Incremental),
+		"snapshot":      meta.Snapshot,
+		"package_count": strconv.Itoa(meta.PackageCount),
+		"duration_ms":   strconv.FormatInt(meta.DurationMs, 10),
+		"analyzed_at":   meta.AnalyzedAt.UTC().Format(time.RFC3339),
+	}
+
+	if _, err := e.Exec(`DELETE FROM metadata`); err != nil {
+		return err
+	}
+	for key, value := range values {
+		if _, err := e.Exec(`INSERT INTO metadata (key, value) VALUES (?, ?)`, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMetadata returns the analysis metadata, or nil if the graph was built
+// before metadata was recorded
+func (db *DB) GetMetadata() (*graph.Metadata, error) {
+	rows, err := db.conn.Query(`SELECT key, value FROM metadata`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	meta := &graph.Metadata{}
+	found := false
+	for rows.Next() {
+		var key string
+		var value sql.NullString
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		if key == "generation" { // written by Batch.Commit, not analysis metadata
+			continue
+		}
+		found = true
+
+		v := value.String
+		switch key {
+		case "project_root":
+			meta.ProjectRoot = v
+		case "module_path":
+			meta.ModulePath = v
+		case "git_commit":
+			meta.GitCommit = v
+		case "git_dirty":
+			meta.GitDirty, _ = strconv.ParseBool(v)
+		case "go_version":
+			meta.GoVersion = v
+		case "crag_version":
+			meta.CragVersion = v
+		case "algorithm":
+			meta.Algorithm = v
+		case "flags":
+			meta.Flags = v
+		case "incremental":
+			meta.Incremental, _ = strconv.ParseBool(v)
+		case "snapshot":
+			meta.Snapshot = v
+		case "package_count":
+			meta.PackageCount, _ = strconv.Atoi(v)
+		case "duration_ms":
+			meta.DurationMs, _ = strconv.ParseInt(v, 10, 64)
+		case "analyzed_at":
+			meta.AnalyzedAt, _ = time.Parse(time.RFC3339, v)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return meta, nil
+}
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration is a single ordered schema change
+type Migration struct {
+	Version     int
+	Description string
+	apply       func(tx *sql.Tx) error
+}
+
+// SchemaVersionInfo records a migration applied to a database
+type SchemaVersionInfo struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	AppliedAt   string `json:"applied_at"`
+}
+
+// migrations lists every schema change in order. Released migrations must never be
+// edited; schema changes are made by appending a new migration.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "初始表结构 (nodes, edges)",
+		apply:       execSQL(schema),
+	},
+	{
+		Version:     2,
+		Description: "节点所属模块 (nodes.module, nodes.module_version)",
+		apply: addColumns("nodes", [][2]string{
+			{"module", "TEXT"},
+			{"module_version", "TEXT"},
+		}),
+	},
+	{
+		Version:     3,
+		Description: "结构体字段标签表 (field_tags)",
+		apply: execSQL(`
+CREATE TABLE IF NOT EXISTS field_tags (
+    field_id INTEGER NOT NULL,    -- 字段节点 ID
+    key TEXT NOT NULL,            -- 标签键 (json, db, yaml...)
+    name TEXT NOT NULL,           -- 线上名称 (user_id)
+    options TEXT,                 -- 选项 (omitempty...)
+    FOREIGN KEY (field_id) REFERENCES nodes(id)
+);
+CREATE INDEX IF NOT EXISTS idx_field_tags_name ON field_tags(key, name);
+CREATE INDEX IF NOT EXISTS idx_field_tags_field ON field_tags(field_id);`),
+	},
+	{
+		Version:     4,
+		Description: "常量值与枚举 switch 表 (nodes.value, enum_switches)",
+		apply: chain(
+			addColumns("nodes", [][2]string{{"value", "TEXT"}}),
+			execSQL(`
+CREATE TABLE IF NOT EXISTS enum_switches (
+    enum_id INTEGER NOT NULL,     -- 枚举节点 ID
+    func_id INTEGER NOT NULL,     -- switch 所在函数节点 ID
+    file TEXT NOT NULL,           -- switch 所在文件
+    line INTEGER NOT NULL,        -- switch 所在行号
+    missing TEXT,                 -- 未处理的成员名，逗号分隔
+    has_default INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (enum_id) REFERENCES nodes(id),
+    FOREIGN KEY (func_id) REFERENCES nodes(id)
+);
+CREATE INDEX IF NOT EXISTS idx_enum_switches_enum ON enum_switches(enum_id);`),
+		),
+	},
+	{
+		Version:     5,
+		Description: "分析元数据表 (metadata)",
+		apply: execSQL(`
+CREATE TABLE IF NOT EXISTS metadata (
+    key TEXT PRIMARY KEY,         -- 项目路径、git 提交、Go/crag 版本、分析参数等
+    value TEXT
+);`),
+	},
+	{
+		Version:     6,
+		Description: "全文搜索索引 (nodes_fts)",
+		apply:       rebuildNodesFTS,
+	},
+	{
+		Version:     7,
+		Description: "源文件哈希表 (files)",
+		apply: execSQL(`
+CREATE TABLE IF NOT EXISTS files (
+    path TEXT PRIMARY KEY,        -- 相对项目根目录的路径
+    package TEXT NOT NULL,        -- 所属包路径
+    hash TEXT NOT NULL,           -- 内容 SHA-256
+    mtime INTEGER NOT NULL,       -- 修改时间 (Unix 纳秒)
+    size INTEGER NOT NULL         -- 文件大小
+);`),
+	},
+	{
+		Version:     8,
+		Description: "用户注释表 (annotations)",
+		apply: execSQL(`
+CREATE TABLE IF NOT EXISTS annotations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_name TEXT NOT NULL,      -- 节点完整名称 (重新分析后不变)
+    text TEXT NOT NULL,           -- 注释内容
+    created_at TEXT NOT NULL      -- 添加时间 (RFC 3339)
+);
+CREATE INDEX IF NOT EXISTS idx_annotations_node ON annotations(node_name);`),
+	},
+	{
+		Version:     9,
+		Description: "外部调用表 (external_calls)",
+		apply: execSQL(`
+CREATE TABLE IF NOT EXISTS external_calls (
+    from_id INTEGER NOT NULL,     -- 调用方节点 ID
+    callee TEXT NOT NULL,         -- 其他模块中被调用函数的完整限定名
+    package TEXT NOT NULL,        -- 被调用函数的包路径
+    call_site_file TEXT,
+    call_site_line INTEGER,
+    FOREIGN KEY (from_id) REFERENCES nodes(id)
+);
+CREATE INDEX IF NOT EXISTS idx_external_calls_from ON external_calls(from_id);
+CREATE INDEX IF NOT EXISTS idx_external_calls_callee ON external_calls(callee);`),
+	},
+	{
+		Version:     10,
+		Description: "调用图可达性索引 (reach_nodes, reach_sccs, reach_edges)",
+		apply: chain(
+			execSQL(`
+CREATE TABLE IF NOT EXISTS reach_nodes (
+    node_id INTEGER PRIMARY KEY,  -- 函数节点 ID
+    scc INTEGER NOT NULL          -- 所在强连通分量
+);
+CREATE TABLE IF NOT EXISTS reach_sccs (
+    scc INTEGER PRIMARY KEY,      -- 拓扑序编号，被调用方更小
+    size INTEGER NOT NULL,        -- 分量中的函数数量
+    pre INTEGER NOT NULL,         -- DFS 区间
+    post INTEGER NOT NULL,
+    total_callers INTEGER NOT NULL, -- 传递调用者数量
+    max_depth INTEGER NOT NULL    -- 最长调用链
+);
+CREATE TABLE IF NOT EXISTS reach_edges (
+    from_scc INTEGER NOT NULL,
+    to_scc INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_reach_edges_from ON reach_edges(from_scc);`),
+			buildReachability,
+		),
+	},
+	{
+		Version:     11,
+		Description: "节点结束行号 (nodes.end_line)，按文件查找节点的索引",
+		apply: chain(
+			addColumns("nodes", [][2]string{{"end_line", "INTEGER NOT NULL DEFAULT 0"}}),
+			execSQL(`CREATE INDEX IF NOT EXISTS idx_nodes_file ON nodes(file, line);`),
+		),
+	},
+}
+
+// LatestSchemaVersion returns the schema version this build of crag writes
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ErrSchemaTooNew is returned when a database was written by a newer crag
+type ErrSchemaTooNew struct {
+	Version   int
+	Supported int
+}
+
+func (e *ErrSchemaTooNew) Error() string {
+	return fmt.Sprintf("数据库 schema 版本为 %d，高于当前 crag 支持的版本 %d，请升级 crag 后再使用该数据库", e.Version, e.Supported)
+}
+
+const createSchemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,  -- 迁移版本号
+    description TEXT NOT NULL,    -- 迁移说明
+    applied_at TEXT NOT NULL      -- 应用时间 (RFC 3339)
+)`
+
+// SchemaVersion returns the current schema version of the database.
+// Databases created before versioning existed report version 1 if they have a
+// nodes table, and empty databases report 0.
+func (db *DB) SchemaVersion() (int, error) {
+	hasVersionTable, err := db.tableExists("schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if !hasVersionTable {
+		hasNodes, err := db.tableExists("nodes")
+		if err != nil || !hasNodes {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	var version sql.NullInt64
+	if err := db.conn.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// PendingMigrations returns the migrations not yet applied to the database
+func (db *DB) PendingMigrations() ([]Migration, error) {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current > LatestSchemaVersion() {
+		return nil, &ErrSchemaTooNew{Version: current, Supported: LatestSchemaVersion()}
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies all pending migrations in order, each in its own transaction,
+// and returns the migrations that were applied
+func (db *DB) Migrate() ([]Migration, error) {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := db.PendingMigrations()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	if _, err := db.conn.Exec(createSchemaVersionTable); err != nil {
+		return nil, err
+	}
+	// Pre-versioning databases already have the initial schema; record it
+	if current == 1 {
+		if err := recordVersion(db.conn, migrations[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		tx, err := db.conn.Begin()
+		if err != nil {
+			return applied, err
+		}
+		if err := m.apply(tx); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("迁移 %d (%s) 失败: %w", m.Version, m.Description, err)
+		}
+		if err := recordVersion(tx, m); err != nil {
+			tx.Rollback()
+			return applied, err
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// SchemaHistory returns the migrations recorded in the database, oldest first
+func (db *DB) SchemaHistory() ([]*SchemaVersionInfo, error) {
+	ok, err := db.tableExists("schema_version")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`SELECT version, description, applied_at FROM schema_version ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*SchemaVersionInfo
+	for rows.Next() {
+		info := &SchemaVersionInfo{}
+		if err := rows.Scan(&info.Version, &info.Description, &info.AppliedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, rows.Err()
+}
+
+func (db *DB) tableExists(name string) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	return count > 0, err
+}
+
+func recordVersion(e execer, m Migration) error {
+	_, err := e.Exec(`INSERT OR IGNORE INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Description, time.Now().Format(time.RFC3339))
+	return err
+}
+
+// execSQL returns a migration step that executes a SQL script
+func execSQL(script string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(script)
+		return err
+	}
+}
+
+// addColumns returns a migration step that adds columns to a table.
+// Columns that already exist are skipped, since databases written by
+// pre-versioning builds may already have some of them.
+func addColumns(table string, columns [][2]string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			existing[name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, col := range columns {
+			if existing[col[0]] {
+				continue
+			}
+			if _, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + col[0] + ` ` + col[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// chain combines migration steps into one
+func chain(steps ...func(tx *sql.Tx) error) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, step := range steps {
+			if err := step(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
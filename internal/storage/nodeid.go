@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// maxNodeIDProbes bounds the search for a free ID when two nodes hash alike
+const maxNodeIDProbes = 100
+
+// StableNodeID derives a node ID from its kind and fully qualified name, so the
+// same symbol keeps its ID across analyses. IDs are positive and fit in 53 bits,
+// so they survive JSON numbers in JavaScript. attempt > 0 gives alternative IDs
+// for the rare node whose ID is already taken.
+func StableNodeID(kind graph.NodeKind, name string, attempt int) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	if attempt > 0 {
+		fmt.Fprintf(h, "#%d", attempt)
+	}
+	id := int64(h.Sum64() & (1<<53 - 1))
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// ParseNodeRef parses a node ID reference such as "#123456". Bare digits are
+// not a reference: they stay a name pattern.
+func ParseNodeRef(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "#") {
+		return 0, false
+	}
+	return ParseNodeID(s[1:])
+}
+
+// ParseNodeID parses a bare node ID such as "123456", as used in URLs
+func ParseNodeID(s string) (int64, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// insertNodeStable inserts a node under its stable ID and reports whether a row
+// was added. A node with the same kind and name already stored keeps its row
+// and ID; only a different node under the ID (a hash collision) moves on to the
+// next alternative ID. insert runs insertNodeSQL with the given arguments,
+// queryRow runs a query in the same transaction.
+func insertNodeStable(insert func(args ...any) (sql.Result, error), queryRow func(query string, args ...any) *sql.Row, node *graph.Node) (int64, bool, error) {
+	for attempt := 0; attempt < maxNodeIDProbes; attempt++ {
+		id := StableNodeID(node.Kind, node.Name, attempt)
+		result, err := insert(nodeInsertArgs(id, node)...)
+		if err != nil {
+			return 0, false, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return 0, false, err
+		} else if n > 0 {
+			return id, true, nil
+		}
+
+		var kind graph.NodeKind
+		var name string
+		if err := queryRow(`SELECT kind, name FROM nodes WHERE id = ?`, id).Scan(&kind, &name); err != nil {
+			return 0, false, err
+		}
+		if kind == node.Kind && name == node.Name {
+			return id, false, nil
+		}
+	}
+	return 0, false, fmt.Errorf("no free node ID for %s %s", node.Kind, node.Name)
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// AddNote attaches a note to the node with the given qualified name
+func (db *DB) AddNote(nodeName, text string) (*graph.Note, error) {
+	conn, release, err := db.writer()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	note := &graph.Note{NodeName: nodeName, Text: text, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	result, err := conn.Exec(
+		`INSERT INTO annotations (node_name, text, created_at) VALUES (?, ?, ?)`,
+		note.NodeName, note.Text, note.CreatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, err
+	}
+	note.ID, err = result.LastInsertId()
+	return note, err
+}
+
+// GetNotes returns the notes attached to a node, oldest first
+func (db *DB) GetNotes(nodeName string) ([]*graph.Note, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, node_name, text, created_at FROM annotations WHERE node_name = ? ORDER BY id`,
+		nodeName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNotes(rows)
+}
+
+// GetAllNotes returns every note, ordered by node name and then age.
+// Notes whose node no longer exists are kept and returned too.
+func (db *DB) GetAllNotes() ([]*graph.Note, error) {
+	rows, err := db.conn.Query(`SELECT id, node_name, text, created_at FROM annotations ORDER BY node_name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNotes(rows)
+}
+
+func scanNotes(rows *sql.Rows) ([]*graph.Note, error) {
+	var notes []*graph.Note
+	for rows.Next() {
+		var n graph.Note
+		var createdAt string
+		if err := rows.Scan(&n.ID, &n.NodeName, &n.Text, &createdAt); err != nil {
+			return nil, err
+		}
+		n.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		notes = append(notes, &n)
+	}
+	return notes, rows.Err()
+}
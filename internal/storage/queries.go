@@ -2,38 +2,55 @@ package storage
 
 import (
 	"database/sql"
+	"sort"
 	"strings"
 
 	"github.com/zheng/crag/internal/graph"
 )
 
-// InsertNode inserts a node into the database and returns its ID
+const (
+	insertNodeSQL = `INSERT INTO nodes (id, kind, name, package, file, line, signature, doc, module, module_version, value, end_line)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`
+	insertEdgeSQL = `INSERT INTO edges (from_id, to_id, kind, call_site_file, call_site_line)
+		 VALUES (?, ?, ?, ?, ?)`
+	insertFieldTagSQL     = `INSERT INTO field_tags (field_id, key, name, options) VALUES (?, ?, ?, ?)`
+	insertEnumSwitchSQL   = `INSERT INTO enum_switches (enum_id, func_id, file, line, missing, has_default) VALUES (?, ?, ?, ?, ?, ?)`
+	insertExternalCallSQL = `INSERT INTO external_calls (from_id, callee, package, call_site_file, call_site_line) VALUES (?, ?, ?, ?, ?)`
+)
+
+func nodeInsertArgs(id int64, node *graph.Node) []any {
+	return []any{id, node.Kind, node.Name, node.Package, node.File, node.Line, node.Signature, node.Doc, node.Module, node.ModuleVersion, node.Value, node.EndLine}
+}
+
+func edgeInsertArgs(edge *graph.Edge) []any {
+	return []any{edge.FromID, edge.ToID, edge.Kind, edge.CallSiteFile, edge.CallSiteLine}
+}
+
+func fieldTagInsertArgs(tag *graph.FieldTag) []any {
+	return []any{tag.FieldID, tag.Key, tag.Name, tag.Options}
+}
+
+// InsertNode inserts a node under its stable ID (see StableNodeID), indexes it for search and returns the ID
 func (db *DB) InsertNode(node *graph.Node) (int64, error) {
-	result, err := db.conn.Exec(
-		`INSERT INTO nodes (kind, name, package, file, line, signature, doc)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		node.Kind, node.Name, node.Package, node.File, node.Line, node.Signature, node.Doc,
-	)
-	if err != nil {
-		return 0, err
+	id, inserted, err := insertNodeStable(func(args ...any) (sql.Result, error) {
+		return db.conn.Exec(insertNodeSQL, args...)
+	}, db.conn.QueryRow, node)
+	if err != nil || !inserted {
+		return id, err
 	}
-	return result.LastInsertId()
+	return id, indexNode(db.conn, id, node)
 }
 
 // InsertEdge inserts an edge into the database
 func (db *DB) InsertEdge(edge *graph.Edge) error {
-	_, err := db.conn.Exec(
-		`INSERT INTO edges (from_id, to_id, kind, call_site_file, call_site_line)
-		 VALUES (?, ?, ?, ?, ?)`,
-		edge.FromID, edge.ToID, edge.Kind, edge.CallSiteFile, edge.CallSiteLine,
-	)
+	_, err := db.conn.Exec(insertEdgeSQL, edgeInsertArgs(edge)...)
 	return err
 }
 
 // GetNodeByName returns a node by its fully qualified name
 func (db *DB) GetNodeByName(name string) (*graph.Node, error) {
 	row := db.conn.QueryRow(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE name = ?`,
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE name = ?`,
 		name,
 	)
 	return scanNode(row)
@@ -42,7 +59,7 @@ func (db *DB) GetNodeByName(name string) (*graph.Node, error) {
 // GetNodeByID returns a node by its ID
 func (db *DB) GetNodeByID(id int64) (*graph.Node, error) {
 	row := db.conn.QueryRow(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE id = ?`,
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE id = ?`,
 		id,
 	)
 	return scanNode(row)
@@ -50,18 +67,35 @@ func (db *DB) GetNodeByID(id int64) (*graph.Node, error) {
 
 // FindNodesByPattern returns nodes matching a name pattern (using LIKE)
 // Results are sorted by match quality: exact short name match > ends with pattern > contains pattern
+// A node ID reference such as "#123456" returns that node.
 func (db *DB) FindNodesByPattern(pattern string) ([]*graph.Node, error) {
+	if id, ok := ParseNodeRef(pattern); ok {
+		node, err := db.GetNodeByID(id)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*graph.Node{node}, nil
+	}
+
 	// First, try exact name match (full qualified name)
 	if node, err := db.GetNodeByName(pattern); err == nil {
 		return []*graph.Node{node}, nil
 	}
 
+	// Methods with a short receiver, e.g. "(Storage).Save" or "(*store.Server).Handle"
+	if nodes, err := db.findMethodsByReceiver(pattern); err != nil || len(nodes) > 0 {
+		return nodes, err
+	}
+
 	// Use a query that sorts by match quality:
 	// 1. Exact match on short name (after last dot or after ").")
 	// 2. Name ends with the pattern (e.g., "pkg.FuncName" matches "FuncName")
 	// 3. Name contains the pattern anywhere
 	rows, err := db.conn.Query(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes
 		 WHERE name LIKE ?
 		 ORDER BY
 			CASE
@@ -104,6 +138,52 @@ func (db *DB) FindNodesByPattern(pattern string) ([]*graph.Node, error) {
 	return nodes, nil
 }
 
+// findMethodsByReceiver matches "(T).Method" / "(*T).Method" patterns against fully
+// qualified method names such as "(*github.com/x/pkg.T).Method".
+// Returns nil if the pattern is not of that form or nothing matches.
+func (db *DB) findMethodsByReceiver(pattern string) ([]*graph.Node, error) {
+	if !strings.HasPrefix(pattern, "(") {
+		return nil, nil
+	}
+	end := strings.Index(pattern, ").")
+	if end < 0 {
+		return nil, nil
+	}
+	recv, method := pattern[1:end], pattern[end+2:]
+	star := ""
+	if strings.HasPrefix(recv, "*") {
+		star, recv = "*", recv[1:]
+	}
+	if recv == "" || method == "" {
+		return nil, nil
+	}
+
+	rows, err := db.conn.Query(
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes
+		 WHERE name LIKE ? ORDER BY length(name) ASC`,
+		"("+star+"%"+recv+")."+method,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes, err := scanNodes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// The receiver must match a whole type name, not a suffix of a longer one
+	var results []*graph.Node
+	for _, n := range nodes {
+		fullRecv := strings.TrimPrefix(n.Name[1:strings.Index(n.Name, ").")], "*")
+		if fullRecv == recv || isExactNameMatch(fullRecv, recv) {
+			results = append(results, n)
+		}
+	}
+	return results, nil
+}
+
 // isExactNameMatch checks if pattern matches the node's full name as a complete
 // identifier, not just as a substring of a longer name.
 // For example, pattern "pkg.Foo" exactly matches "github.com/x/pkg.Foo"
@@ -130,7 +210,7 @@ func isExactNameMatch(fullName, pattern string) bool {
 // GetDirectCallers returns functions that directly call the given function
 func (db *DB) GetDirectCallers(nodeID int64) ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
 		 FROM nodes n
 		 JOIN edges e ON e.from_id = n.id
 		 WHERE e.to_id = ? AND e.kind = 'calls'`,
@@ -146,7 +226,7 @@ func (db *DB) GetDirectCallers(nodeID int64) ([]*graph.Node, error) {
 // GetDirectCallees returns functions that the given function directly calls
 func (db *DB) GetDirectCallees(nodeID int64) ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
 		 FROM nodes n
 		 JOIN edges e ON e.to_id = n.id
 		 WHERE e.from_id = ? AND e.kind = 'calls'`,
@@ -168,36 +248,36 @@ func (db *DB) GetUpstreamCallers(nodeID int64, maxDepth int) ([]*graph.Node, err
 	if maxDepth == 0 {
 		// No depth limit
 		query = `
-		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			WHERE e.to_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			JOIN callers c ON e.to_id = c.id
 			WHERE e.kind = 'calls'
 		)
-		SELECT DISTINCT id, kind, name, package, file, line, signature, doc FROM callers`
+		SELECT DISTINCT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM callers`
 		args = []interface{}{nodeID}
 	} else {
 		// With depth limit
 		query = `
-		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			WHERE e.to_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			JOIN callers c ON e.to_id = c.id
 			WHERE e.kind = 'calls' AND c.depth < ?
 		)
-		SELECT DISTINCT id, kind, name, package, file, line, signature, doc FROM callers`
+		SELECT DISTINCT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM callers`
 		args = []interface{}{nodeID, maxDepth}
 	}
 
@@ -218,36 +298,36 @@ func (db *DB) GetDownstreamCallees(nodeID int64, maxDepth int) ([]*graph.Node, e
 	if maxDepth == 0 {
 		// No depth limit
 		query = `
-		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			WHERE e.from_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			JOIN callees c ON e.from_id = c.id
 			WHERE e.kind = 'calls'
 		)
-		SELECT DISTINCT id, kind, name, package, file, line, signature, doc FROM callees`
+		SELECT DISTINCT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM callees`
 		args = []interface{}{nodeID}
 	} else {
 		// With depth limit
 		query = `
-		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			WHERE e.from_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			JOIN callees c ON e.from_id = c.id
 			WHERE e.kind = 'calls' AND c.depth < ?
 		)
-		SELECT DISTINCT id, kind, name, package, file, line, signature, doc FROM callees`
+		SELECT DISTINCT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM callees`
 		args = []interface{}{nodeID, maxDepth}
 	}
 
@@ -293,7 +373,7 @@ func (db *DB) GetCallEdgesForNode(nodeID int64) ([]*graph.Edge, error) {
 // GetAllFunctions returns all function nodes
 func (db *DB) GetAllFunctions() ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE kind = 'func'`,
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE kind = 'func'`,
 	)
 	if err != nil {
 		return nil, err
@@ -303,9 +383,37 @@ func (db *DB) GetAllFunctions() ([]*graph.Node, error) {
 }
 
 
+// GetNodesByKinds returns all nodes of the given kinds, ordered by name
+func (db *DB) GetNodesByKinds(kinds ...graph.NodeKind) ([]*graph.Node, error) {
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(kinds))
+	args := make([]interface{}, len(kinds))
+	for i, k := range kinds {
+		placeholders[i] = "?"
+		args[i] = string(k)
+	}
+	rows, err := db.conn.Query(
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes
+		 WHERE kind IN (`+strings.Join(placeholders, ", ")+`)
+		 ORDER BY name`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNodes(rows)
+}
+
 // GetAllEdges returns all edges in the database
 func (db *DB) GetAllEdges() ([]*graph.Edge, error) {
-	rows, err := db.conn.Query(
+	return queryAllEdges(db.conn)
+}
+
+func queryAllEdges(q queryer) ([]*graph.Edge, error) {
+	rows, err := q.Query(
 		`SELECT id, from_id, to_id, kind, call_site_file, call_site_line FROM edges`,
 	)
 	if err != nil {
@@ -336,6 +444,10 @@ func (db *DB) GetAllEdges() ([]*graph.Edge, error) {
 // Also deletes all edges referencing those nodes
 // Returns the number of deleted nodes
 func (db *DB) DeleteNodesByPackage(packages []string) (int64, error) {
+	return deleteNodesByPackage(db.conn, packages)
+}
+
+func deleteNodesByPackage(e execer, packages []string) (int64, error) {
 	if len(packages) == 0 {
 		return 0, nil
 	}
@@ -352,14 +464,38 @@ func (db *DB) DeleteNodesByPackage(packages []string) (int64, error) {
 	edgeQuery := `DELETE FROM edges WHERE from_id IN (SELECT id FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `)) OR to_id IN (SELECT id FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `))`
 	// Need to duplicate args for the two IN clauses
 	edgeArgs := append(args, args...)
-	_, err := db.conn.Exec(edgeQuery, edgeArgs...)
+	_, err := e.Exec(edgeQuery, edgeArgs...)
 	if err != nil {
 		return 0, err
 	}
 
+	// Delete tags of field nodes in these packages
+	tagQuery := `DELETE FROM field_tags WHERE field_id IN (SELECT id FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `))`
+	if _, err := e.Exec(tagQuery, args...); err != nil {
+		return 0, err
+	}
+
+	// Delete external calls made by functions in these packages
+	externalQuery := `DELETE FROM external_calls WHERE from_id IN (SELECT id FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `))`
+	if _, err := e.Exec(externalQuery, args...); err != nil {
+		return 0, err
+	}
+
+	// Delete switch records of enums or functions in these packages
+	switchQuery := `DELETE FROM enum_switches WHERE enum_id IN (SELECT id FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `)) OR func_id IN (SELECT id FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `))`
+	if _, err := e.Exec(switchQuery, edgeArgs...); err != nil {
+		return 0, err
+	}
+
+	// Remove the nodes from the search index
+	ftsQuery := `DELETE FROM nodes_fts WHERE rowid IN (SELECT id FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `))`
+	if _, err := e.Exec(ftsQuery, args...); err != nil {
+		return 0, err
+	}
+
 	// Then delete the nodes
 	nodeQuery := `DELETE FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `)`
-	result, err := db.conn.Exec(nodeQuery, args...)
+	result, err := e.Exec(nodeQuery, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -368,7 +504,11 @@ func (db *DB) DeleteNodesByPackage(packages []string) (int64, error) {
 
 // DeleteOrphanEdges deletes edges that reference non-existent nodes
 func (db *DB) DeleteOrphanEdges() (int64, error) {
-	result, err := db.conn.Exec(`
+	return deleteOrphanEdges(db.conn)
+}
+
+func deleteOrphanEdges(e execer) (int64, error) {
+	result, err := e.Exec(`
 		DELETE FROM edges
 		WHERE from_id NOT IN (SELECT id FROM nodes)
 		   OR to_id NOT IN (SELECT id FROM nodes)
@@ -392,7 +532,7 @@ func (db *DB) GetNodesByPackage(packages []string) ([]*graph.Node, error) {
 		args[i] = pkg
 	}
 
-	query := `SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `)`
+	query := `SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE package IN (` + joinStrings(placeholders, ",") + `)`
 	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
@@ -401,6 +541,30 @@ func (db *DB) GetNodesByPackage(packages []string) ([]*graph.Node, error) {
 	return scanNodes(rows)
 }
 
+// GetNodesInFiles returns the nodes declared in the given files, with their
+// end lines, ordered by file and line
+func (db *DB) GetNodesInFiles(files []string) ([]*graph.Node, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(files))
+	args := make([]interface{}, len(files))
+	for i, f := range files {
+		placeholders[i] = "?"
+		args[i] = f
+	}
+
+	query := `SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value, end_line FROM nodes
+		 WHERE file IN (` + joinStrings(placeholders, ",") + `) ORDER BY file, line, id`
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNodesWithEndLine(rows)
+}
+
 // GetStats returns database statistics
 func (db *DB) GetStats() (nodeCount, edgeCount int64, err error) {
 	err = db.conn.QueryRow(`SELECT COUNT(*) FROM nodes`).Scan(&nodeCount)
@@ -426,8 +590,8 @@ func joinStrings(strs []string, sep string) string {
 
 func scanNode(row *sql.Row) (*graph.Node, error) {
 	var n graph.Node
-	var signature, doc sql.NullString
-	err := row.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc)
+	var signature, doc, module, moduleVersion, value sql.NullString
+	err := row.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &module, &moduleVersion, &value)
 	if err != nil {
 		return nil, err
 	}
@@ -437,15 +601,37 @@ func scanNode(row *sql.Row) (*graph.Node, error) {
 	if doc.Valid {
 		n.Doc = doc.String
 	}
+	if module.Valid {
+		n.Module = module.String
+	}
+	if moduleVersion.Valid {
+		n.ModuleVersion = moduleVersion.String
+	}
+	if value.Valid {
+		n.Value = value.String
+	}
 	return &n, nil
 }
 
 func scanNodes(rows *sql.Rows) ([]*graph.Node, error) {
+	return scanNodeRows(rows, false)
+}
+
+// scanNodesWithEndLine scans nodes selected with end_line after the usual columns
+func scanNodesWithEndLine(rows *sql.Rows) ([]*graph.Node, error) {
+	return scanNodeRows(rows, true)
+}
+
+func scanNodeRows(rows *sql.Rows, withEndLine bool) ([]*graph.Node, error) {
 	var nodes []*graph.Node
 	for rows.Next() {
 		var n graph.Node
-		var signature, doc sql.NullString
-		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc); err != nil {
+		var signature, doc, module, moduleVersion, value sql.NullString
+		dest := []any{&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &module, &moduleVersion, &value}
+		if withEndLine {
+			dest = append(dest, &n.EndLine)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
 		if signature.Valid {
@@ -454,6 +640,15 @@ func scanNodes(rows *sql.Rows) ([]*graph.Node, error) {
 		if doc.Valid {
 			n.Doc = doc.String
 		}
+		if module.Valid {
+			n.Module = module.String
+		}
+		if moduleVersion.Valid {
+			n.ModuleVersion = moduleVersion.String
+		}
+		if value.Valid {
+			n.Value = value.String
+		}
 		nodes = append(nodes, &n)
 	}
 	return nodes, rows.Err()
@@ -470,8 +665,8 @@ func scanNodesWithDepth(rows *sql.Rows) ([]*NodeWithDepth, error) {
 	for rows.Next() {
 		var n graph.Node
 		var depth int
-		var signature, doc sql.NullString
-		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &depth); err != nil {
+		var signature, doc, module, moduleVersion, value sql.NullString
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &module, &moduleVersion, &value, &depth); err != nil {
 			return nil, err
 		}
 		if signature.Valid {
@@ -480,6 +675,15 @@ func scanNodesWithDepth(rows *sql.Rows) ([]*NodeWithDepth, error) {
 		if doc.Valid {
 			n.Doc = doc.String
 		}
+		if module.Valid {
+			n.Module = module.String
+		}
+		if moduleVersion.Valid {
+			n.ModuleVersion = moduleVersion.String
+		}
+		if value.Valid {
+			n.Value = value.String
+		}
 		nodeCopy := n
 		nodes = append(nodes, &NodeWithDepth{Node: &nodeCopy, Depth: depth})
 	}
@@ -494,36 +698,36 @@ func (db *DB) GetUpstreamCallersWithDepth(nodeID int64, maxDepth int) ([]*NodeWi
 
 	if maxDepth == 0 {
 		query = `
-		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			WHERE e.to_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			JOIN callers c ON e.to_id = c.id
 			WHERE e.kind = 'calls'
 		)
-		SELECT id, kind, name, package, file, line, signature, doc, MIN(depth) as depth
+		SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value, MIN(depth) as depth
 		FROM callers GROUP BY id ORDER BY depth ASC`
 		args = []interface{}{nodeID}
 	} else {
 		query = `
-		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callers(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			WHERE e.to_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.from_id = n.id
 			JOIN callers c ON e.to_id = c.id
 			WHERE e.kind = 'calls' AND c.depth < ?
 		)
-		SELECT id, kind, name, package, file, line, signature, doc, MIN(depth) as depth
+		SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value, MIN(depth) as depth
 		FROM callers GROUP BY id ORDER BY depth ASC`
 		args = []interface{}{nodeID, maxDepth}
 	}
@@ -544,36 +748,36 @@ func (db *DB) GetDownstreamCalleesWithDepth(nodeID int64, maxDepth int) ([]*Node
 
 	if maxDepth == 0 {
 		query = `
-		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			WHERE e.from_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			JOIN callees c ON e.from_id = c.id
 			WHERE e.kind = 'calls'
 		)
-		SELECT id, kind, name, package, file, line, signature, doc, MIN(depth) as depth
+		SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value, MIN(depth) as depth
 		FROM callees GROUP BY id ORDER BY depth ASC`
 		args = []interface{}{nodeID}
 	} else {
 		query = `
-		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, depth) AS (
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, 1
+		WITH RECURSIVE callees(id, kind, name, package, file, line, signature, doc, module, module_version, value, depth) AS (
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			WHERE e.from_id = ? AND e.kind = 'calls'
 			UNION
-			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, c.depth + 1
+			SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value, c.depth + 1
 			FROM nodes n
 			JOIN edges e ON e.to_id = n.id
 			JOIN callees c ON e.from_id = c.id
 			WHERE e.kind = 'calls' AND c.depth < ?
 		)
-		SELECT id, kind, name, package, file, line, signature, doc, MIN(depth) as depth
+		SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value, MIN(depth) as depth
 		FROM callees GROUP BY id ORDER BY depth ASC`
 		args = []interface{}{nodeID, maxDepth}
 	}
@@ -661,7 +865,7 @@ func (db *DB) GetDownstreamCallTree(nodeID int64, maxDepth int) ([]*CallTreeNode
 // GetAllInterfaces returns all interface nodes
 func (db *DB) GetAllInterfaces() ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE kind = 'interface'`,
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE kind = 'interface'`,
 	)
 	if err != nil {
 		return nil, err
@@ -673,7 +877,7 @@ func (db *DB) GetAllInterfaces() ([]*graph.Node, error) {
 // FindInterfacesByPattern returns interfaces matching a name pattern
 func (db *DB) FindInterfacesByPattern(pattern string) ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes
 		 WHERE kind = 'interface' AND name LIKE ?
 		 ORDER BY length(name) ASC`,
 		"%"+pattern+"%",
@@ -688,7 +892,7 @@ func (db *DB) FindInterfacesByPattern(pattern string) ([]*graph.Node, error) {
 // GetImplementations returns all types that implement a given interface
 func (db *DB) GetImplementations(interfaceID int64) ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
 		 FROM nodes n
 		 JOIN edges e ON e.from_id = n.id
 		 WHERE e.to_id = ? AND e.kind = 'implements'`,
@@ -704,7 +908,7 @@ func (db *DB) GetImplementations(interfaceID int64) ([]*graph.Node, error) {
 // GetImplementedInterfaces returns all interfaces that a type implements
 func (db *DB) GetImplementedInterfaces(typeID int64) ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
 		 FROM nodes n
 		 JOIN edges e ON e.to_id = n.id
 		 WHERE e.from_id = ? AND e.kind = 'implements'`,
@@ -720,7 +924,7 @@ func (db *DB) GetImplementedInterfaces(typeID int64) ([]*graph.Node, error) {
 // GetAllTypes returns all struct/type nodes
 func (db *DB) GetAllTypes() ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE kind = 'struct'`,
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE kind = 'struct'`,
 	)
 	if err != nil {
 		return nil, err
@@ -732,7 +936,7 @@ func (db *DB) GetAllTypes() ([]*graph.Node, error) {
 // GetAllVars returns all package-level variable nodes
 func (db *DB) GetAllVars() ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE kind = 'var'`,
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE kind = 'var'`,
 	)
 	if err != nil {
 		return nil, err
@@ -744,7 +948,7 @@ func (db *DB) GetAllVars() ([]*graph.Node, error) {
 // GetAllConsts returns all package-level constant nodes
 func (db *DB) GetAllConsts() ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT id, kind, name, package, file, line, signature, doc FROM nodes WHERE kind = 'const'`,
+		`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes WHERE kind = 'const'`,
 	)
 	if err != nil {
 		return nil, err
@@ -756,7 +960,7 @@ func (db *DB) GetAllConsts() ([]*graph.Node, error) {
 // GetReferencingFunctions returns all functions that reference the given var/const
 func (db *DB) GetReferencingFunctions(nodeID int64) ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
 		 FROM nodes n
 		 JOIN edges e ON e.from_id = n.id
 		 WHERE e.to_id = ? AND e.kind = 'references'`,
@@ -772,7 +976,7 @@ func (db *DB) GetReferencingFunctions(nodeID int64) ([]*graph.Node, error) {
 // GetReferencedVarConsts returns all vars/consts that the given function references
 func (db *DB) GetReferencedVarConsts(funcID int64) ([]*graph.Node, error) {
 	rows, err := db.conn.Query(
-		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
 		 FROM nodes n
 		 JOIN edges e ON e.to_id = n.id
 		 WHERE e.from_id = ? AND e.kind = 'references'`,
@@ -785,6 +989,48 @@ func (db *DB) GetReferencedVarConsts(funcID int64) ([]*graph.Node, error) {
 	return scanNodes(rows)
 }
 
+// GetDerivedVarConsts returns vars/consts whose initializers depend on the given node,
+// directly or through other derived vars/consts (e.g. const B = A * 2; const C = B + 1)
+func (db *DB) GetDerivedVarConsts(nodeID int64) ([]*graph.Node, error) {
+	rows, err := db.conn.Query(
+		`WITH RECURSIVE derived(id) AS (
+			SELECT from_id FROM edges WHERE to_id = ? AND kind = 'initializes'
+			UNION
+			SELECT e.from_id FROM edges e
+			JOIN derived d ON e.to_id = d.id
+			WHERE e.kind = 'initializes'
+		)
+		SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
+		FROM nodes n
+		JOIN derived d ON d.id = n.id
+		WHERE n.id != ?
+		ORDER BY n.name`,
+		nodeID, nodeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNodes(rows)
+}
+
+// GetTypeAssertingFunctions returns functions that type-assert or type-switch on the given type
+func (db *DB) GetTypeAssertingFunctions(typeID int64) ([]*graph.Node, error) {
+	rows, err := db.conn.Query(
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
+		 FROM nodes n
+		 JOIN edges e ON e.from_id = n.id
+		 WHERE e.to_id = ? AND e.kind = 'asserts_type'
+		 ORDER BY n.name`,
+		typeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNodes(rows)
+}
+
 // ==================== Risk Score Queries ====================
 
 // RiskScore represents the change risk assessment for a function
@@ -806,9 +1052,13 @@ func (db *DB) GetDirectCallerCount(nodeID int64) (int, error) {
 	return count, err
 }
 
-// GetTotalCallerCount returns the total number of upstream callers (recursive)
-// Limits depth to 50 to prevent infinite loops in circular call graphs
+// GetTotalCallerCount returns the number of distinct functions that reach a
+// node. It is read from the reachability index; nodes outside the index fall
+// back to a recursive query limited to 50 levels.
 func (db *DB) GetTotalCallerCount(nodeID int64) (int, error) {
+	if total, _, ok, err := db.reachStats(nodeID); ok || err != nil {
+		return total, err
+	}
 	var count int
 	err := db.conn.QueryRow(`
 		WITH RECURSIVE callers(id, depth) AS (
@@ -824,8 +1074,13 @@ func (db *DB) GetTotalCallerCount(nodeID int64) (int, error) {
 	return count, err
 }
 
-// GetMaxCallDepth returns the maximum depth to reach entry points (functions with no callers)
+// GetMaxCallDepth returns the length of the longest caller chain above a node,
+// counting each recursion cycle as one level. It is read from the reachability
+// index; nodes outside the index fall back to a recursive query capped at 50.
 func (db *DB) GetMaxCallDepth(nodeID int64) (int, error) {
+	if _, depth, ok, err := db.reachStats(nodeID); ok || err != nil {
+		return depth, err
+	}
 	var maxDepth int
 	err := db.conn.QueryRow(`
 		WITH RECURSIVE call_chain(id, depth) AS (
@@ -857,8 +1112,10 @@ func CalculateRiskLevel(directCallers, totalCallers int) string {
 	return "low"
 }
 
-// GetRiskScore calculates the risk score for a function
-// Uses only direct callers for fast performance (recursive queries are too slow for hot functions)
+// GetRiskScore calculates the risk score for a function. Total callers and
+// depth come from the reachability index; without it (a graph written by
+// direct inserts rather than a batch) only direct callers are used, because
+// recursive queries can take minutes for hot functions.
 func (db *DB) GetRiskScore(nodeID int64) (*RiskScore, error) {
 	node, err := db.GetNodeByID(nodeID)
 	if err != nil {
@@ -870,30 +1127,40 @@ func (db *DB) GetRiskScore(nodeID int64) (*RiskScore, error) {
 		return nil, err
 	}
 
-	// Skip expensive recursive queries - use direct callers only for risk assessment
-	// For functions with hundreds of callers, recursive queries can take minutes
+	total, depth, ok, err := db.reachStats(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &RiskScore{
+			Node:          node,
+			DirectCallers: directCallers,
+			TotalCallers:  directCallers, // Use direct as estimate
+			MaxDepth:      0,             // Skip depth calculation
+			RiskLevel:     CalculateRiskLevelFast(directCallers),
+		}, nil
+	}
 	return &RiskScore{
 		Node:          node,
 		DirectCallers: directCallers,
-		TotalCallers:  directCallers, // Use direct as estimate
-		MaxDepth:      0,             // Skip depth calculation
-		RiskLevel:     CalculateRiskLevelFast(directCallers),
+		TotalCallers:  total,
+		MaxDepth:      depth,
+		RiskLevel:     CalculateRiskLevel(directCallers, total),
 	}, nil
 }
 
-// GetTopRiskyFunctions returns functions with most callers (highest risk)
-// For performance, only uses direct caller count (skips expensive recursive queries)
+// GetTopRiskyFunctions returns the functions with the highest risk, ordered by
+// risk level, then direct and total callers (see SortRiskScores)
 func (db *DB) GetTopRiskyFunctions(limit int) ([]*RiskScore, error) {
 	rows, err := db.conn.Query(`
-		SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc,
-		       COUNT(e.from_id) as caller_count
+		SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value,
+		       (SELECT COUNT(*) FROM edges e WHERE e.to_id = n.id AND e.kind = 'calls') AS caller_count,
+		       s.total_callers, s.max_depth
 		FROM nodes n
-		LEFT JOIN edges e ON e.to_id = n.id AND e.kind = 'calls'
+		LEFT JOIN reach_nodes r ON r.node_id = n.id
+		LEFT JOIN reach_sccs s ON s.scc = r.scc
 		WHERE n.kind = 'func'
-		GROUP BY n.id
-		ORDER BY caller_count DESC
-		LIMIT ?
-	`, limit)
+	`)
 	if err != nil {
 		return nil, err
 	}
@@ -902,9 +1169,10 @@ func (db *DB) GetTopRiskyFunctions(limit int) ([]*RiskScore, error) {
 	var results []*RiskScore
 	for rows.Next() {
 		var n graph.Node
-		var signature, doc sql.NullString
+		var signature, doc, module, moduleVersion, value sql.NullString
 		var directCallers int
-		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &directCallers); err != nil {
+		var totalCallers, maxDepth sql.NullInt64
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &module, &moduleVersion, &value, &directCallers, &totalCallers, &maxDepth); err != nil {
 			return nil, err
 		}
 		if signature.Valid {
@@ -913,18 +1181,58 @@ func (db *DB) GetTopRiskyFunctions(limit int) ([]*RiskScore, error) {
 		if doc.Valid {
 			n.Doc = doc.String
 		}
+		if module.Valid {
+			n.Module = module.String
+		}
+		if moduleVersion.Valid {
+			n.ModuleVersion = moduleVersion.String
+		}
+		if value.Valid {
+			n.Value = value.String
+		}
 
-		// For list view, use direct callers only (fast)
-		// Total callers calculated only for single function analysis
-		results = append(results, &RiskScore{
+		score := &RiskScore{
 			Node:          &n,
 			DirectCallers: directCallers,
-			TotalCallers:  directCallers, // Use direct as estimate for list
-			MaxDepth:      0,
+			TotalCallers:  directCallers, // Estimate for functions outside the index
 			RiskLevel:     CalculateRiskLevelFast(directCallers),
-		})
+		}
+		if totalCallers.Valid {
+			score.TotalCallers = int(totalCallers.Int64)
+			score.MaxDepth = int(maxDepth.Int64)
+			score.RiskLevel = CalculateRiskLevel(directCallers, score.TotalCallers)
+		}
+		results = append(results, score)
 	}
-	return results, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	SortRiskScores(results)
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// riskRank orders risk levels from lowest to highest
+var riskRank = map[string]int{"low": 0, "medium": 1, "high": 2, "critical": 3}
+
+// SortRiskScores orders scores by risk level, then direct callers, total
+// callers and name, highest risk first
+func SortRiskScores(scores []*RiskScore) {
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if riskRank[a.RiskLevel] != riskRank[b.RiskLevel] {
+			return riskRank[a.RiskLevel] > riskRank[b.RiskLevel]
+		}
+		if a.DirectCallers != b.DirectCallers {
+			return a.DirectCallers > b.DirectCallers
+		}
+		if a.TotalCallers != b.TotalCallers {
+			return a.TotalCallers > b.TotalCallers
+		}
+		return a.Node.Name < b.Node.Name
+	})
 }
 
 // CalculateRiskLevelFast determines risk level based on direct callers only (for list view)
@@ -995,3 +1303,239 @@ func (db *DB) GetPackageSummary() ([]*PackageSummary, error) {
 	}
 	return results, rows.Err()
 }
+
+// ==================== Struct Tag Queries ====================
+
+// TaggedField is a struct field node together with one of its tags
+type TaggedField struct {
+	Field *graph.Node     `json:"field"`
+	Tag   *graph.FieldTag `json:"tag"`
+}
+
+// TypeUsage is a function that constructs or decodes a struct
+type TypeUsage struct {
+	Func *graph.Node    `json:"func"`
+	Kind graph.EdgeKind `json:"kind"`
+	File string         `json:"file"`
+	Line int            `json:"line"`
+}
+
+// InsertFieldTag inserts a struct field tag
+func (db *DB) InsertFieldTag(tag *graph.FieldTag) error {
+	_, err := db.conn.Exec(insertFieldTagSQL, fieldTagInsertArgs(tag)...)
+	return err
+}
+
+// FindFieldsByTag returns fields whose tag has the given wire name.
+// If key is empty, tags of all keys are matched.
+func (db *DB) FindFieldsByTag(key, name string) ([]*TaggedField, error) {
+	query := `SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value,
+		         t.key, t.name, t.options
+		 FROM field_tags t
+		 JOIN nodes n ON n.id = t.field_id
+		 WHERE t.name = ?`
+	args := []interface{}{name}
+	if key != "" {
+		query += ` AND t.key = ?`
+		args = append(args, key)
+	}
+	query += ` ORDER BY n.name`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*TaggedField
+	for rows.Next() {
+		var n graph.Node
+		var t graph.FieldTag
+		var signature, doc, module, moduleVersion, value, options sql.NullString
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &module, &moduleVersion, &value,
+			&t.Key, &t.Name, &options); err != nil {
+			return nil, err
+		}
+		n.Signature = signature.String
+		n.Doc = doc.String
+		n.Module = module.String
+		n.ModuleVersion = moduleVersion.String
+		n.Value = value.String
+		t.FieldID = n.ID
+		t.Options = options.String
+		results = append(results, &TaggedField{Field: &n, Tag: &t})
+	}
+	return results, rows.Err()
+}
+
+// GetFieldTags returns all tags of a struct field
+func (db *DB) GetFieldTags(fieldID int64) ([]*graph.FieldTag, error) {
+	rows, err := db.conn.Query(
+		`SELECT field_id, key, name, options FROM field_tags WHERE field_id = ? ORDER BY key`,
+		fieldID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*graph.FieldTag
+	for rows.Next() {
+		var t graph.FieldTag
+		var options sql.NullString
+		if err := rows.Scan(&t.FieldID, &t.Key, &t.Name, &options); err != nil {
+			return nil, err
+		}
+		t.Options = options.String
+		tags = append(tags, &t)
+	}
+	return tags, rows.Err()
+}
+
+// GetFieldOwner returns the struct that declares the given field
+func (db *DB) GetFieldOwner(fieldID int64) (*graph.Node, error) {
+	row := db.conn.QueryRow(
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
+		 FROM nodes n
+		 JOIN edges e ON e.from_id = n.id
+		 WHERE e.to_id = ? AND e.kind = 'has_field'`,
+		fieldID,
+	)
+	return scanNode(row)
+}
+
+// GetStructFields returns all fields of a struct
+func (db *DB) GetStructFields(structID int64) ([]*graph.Node, error) {
+	rows, err := db.conn.Query(
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
+		 FROM nodes n
+		 JOIN edges e ON e.to_id = n.id
+		 WHERE e.from_id = ? AND e.kind = 'has_field'
+		 ORDER BY n.line`,
+		structID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNodes(rows)
+}
+
+// GetStructUsers returns functions that construct or decode the given struct
+func (db *DB) GetStructUsers(structID int64) ([]*TypeUsage, error) {
+	rows, err := db.conn.Query(
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value,
+		        e.kind, e.call_site_file, e.call_site_line
+		 FROM nodes n
+		 JOIN edges e ON e.from_id = n.id
+		 WHERE e.to_id = ? AND e.kind IN ('constructs', 'decodes')
+		 ORDER BY e.kind, n.name`,
+		structID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*TypeUsage
+	for rows.Next() {
+		var n graph.Node
+		var u TypeUsage
+		var signature, doc, module, moduleVersion, value, callSiteFile sql.NullString
+		var callSiteLine sql.NullInt64
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &module, &moduleVersion, &value,
+			&u.Kind, &callSiteFile, &callSiteLine); err != nil {
+			return nil, err
+		}
+		n.Signature = signature.String
+		n.Doc = doc.String
+		n.Module = module.String
+		n.ModuleVersion = moduleVersion.String
+		n.Value = value.String
+		u.Func = &n
+		u.File = callSiteFile.String
+		u.Line = int(callSiteLine.Int64)
+		results = append(results, &u)
+	}
+	return results, rows.Err()
+}
+
+// ==================== Enum Queries ====================
+
+// EnumSwitchUsage is a switch over an enum together with the function containing it
+type EnumSwitchUsage struct {
+	Switch *graph.EnumSwitch `json:"switch"`
+	Func   *graph.Node       `json:"func"`
+}
+
+// InsertEnumSwitch inserts a switch statement record over an enum type
+func (db *DB) InsertEnumSwitch(sw *graph.EnumSwitch) error {
+	_, err := db.conn.Exec(insertEnumSwitchSQL, enumSwitchInsertArgs(sw)...)
+	return err
+}
+
+func enumSwitchInsertArgs(sw *graph.EnumSwitch) []any {
+	hasDefault := 0
+	if sw.HasDefault {
+		hasDefault = 1
+	}
+	return []any{sw.EnumID, sw.FuncID, sw.File, sw.Line, strings.Join(sw.Missing, ","), hasDefault}
+}
+
+// GetEnumMembers returns the constants of an enum in declaration order
+func (db *DB) GetEnumMembers(enumID int64) ([]*graph.Node, error) {
+	rows, err := db.conn.Query(
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
+		 FROM nodes n
+		 JOIN edges e ON e.to_id = n.id
+		 WHERE e.from_id = ? AND e.kind = 'has_member'
+		 ORDER BY n.file, n.line`,
+		enumID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanNodes(rows)
+}
+
+// GetEnumSwitches returns the switch statements over an enum
+func (db *DB) GetEnumSwitches(enumID int64) ([]*EnumSwitchUsage, error) {
+	rows, err := db.conn.Query(
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value,
+		        s.file, s.line, s.missing, s.has_default
+		 FROM enum_switches s
+		 JOIN nodes n ON n.id = s.func_id
+		 WHERE s.enum_id = ?
+		 ORDER BY s.file, s.line`,
+		enumID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*EnumSwitchUsage
+	for rows.Next() {
+		var n graph.Node
+		sw := &graph.EnumSwitch{EnumID: enumID}
+		var signature, doc, module, moduleVersion, value, missing sql.NullString
+		var hasDefault int
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Name, &n.Package, &n.File, &n.Line, &signature, &doc, &module, &moduleVersion, &value,
+			&sw.File, &sw.Line, &missing, &hasDefault); err != nil {
+			return nil, err
+		}
+		n.Signature = signature.String
+		n.Doc = doc.String
+		n.Module = module.String
+		n.ModuleVersion = moduleVersion.String
+		n.Value = value.String
+		sw.FuncID = n.ID
+		if missing.String != "" {
+			sw.Missing = strings.Split(missing.String, ",")
+		}
+		sw.HasDefault = hasDefault != 0
+		results = append(results, &EnumSwitchUsage{Switch: sw, Func: &n})
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,492 @@
+package storage
+
+import (
+	"database/sql"
+	"math/bits"
+)
+
+// The reachability index makes transitive questions about the call graph
+// (how many functions reach f, how long is the longest chain above f, does g
+// reach f) exact and cheap, where recursive SQL would walk the graph per query.
+//
+// The call graph is condensed into its strongly connected components (sets of
+// mutually recursive functions), which form a DAG. SCCs are numbered in
+// topological order with callees first, so an SCC can only reach SCCs with a
+// lower number. Every SCC also gets a pre/post interval from a depth-first
+// spanning forest of the DAG: an SCC whose interval lies inside another's is
+// reachable from it. Transitive caller counts and chain lengths are computed
+// once per graph generation (see Batch.Commit) and stored with the graph.
+
+// reachIndex is the reachability index of a call graph
+type reachIndex struct {
+	scc   map[int64]int32 // 节点 -> SCC 编号 (被调用方编号更小)
+	size  []int32         // SCC 中的函数数量
+	dag   [][]int32       // SCC 之间的调用边 (调用方 -> 被调用方)
+	pre   []int32         // 生成森林上的 DFS 区间
+	post  []int32
+	total []int32 // 传递调用者数量 (按 SCC)
+	depth []int32 // 最长调用链 (按 SCC)
+}
+
+// reachBlockBits is the number of target SCCs whose ancestors are counted in
+// one pass; it bounds the bitset memory to reachBlockBits/8 bytes per SCC
+const reachBlockBits = 4096
+
+// buildReachIndex builds the index of the call graph over the given function
+// nodes and call edges (caller, callee)
+func buildReachIndex(nodes []int64, calls [][2]int64) *reachIndex {
+	adj := make(map[int64][]int64)
+	for _, c := range calls {
+		if c[0] != c[1] {
+			adj[c[0]] = append(adj[c[0]], c[1])
+		}
+	}
+
+	r := &reachIndex{scc: make(map[int64]int32, len(nodes))}
+	r.condense(nodes, adj)
+	r.label()
+	r.countCallers()
+	r.measureDepth()
+	return r
+}
+
+// condense finds the SCCs with an iterative Tarjan's algorithm, which completes
+// them in reverse topological order: callees before callers
+func (r *reachIndex) condense(nodes []int64, adj map[int64][]int64) {
+	index := make(map[int64]int32, len(nodes))
+	low := make(map[int64]int32, len(nodes))
+	onStack := make(map[int64]bool)
+	var stack []int64
+	var order []int64 // nodes in visiting order, for a deterministic DAG
+	next := int32(0)
+
+	type frame struct {
+		node int64
+		edge int
+	}
+	for _, root := range nodes {
+		if _, ok := index[root]; ok {
+			continue
+		}
+		call := []frame{{node: root}}
+		index[root], low[root] = next, next
+		next++
+		order = append(order, root)
+		stack = append(stack, root)
+		onStack[root] = true
+
+		for len(call) > 0 {
+			f := &call[len(call)-1]
+			if f.edge < len(adj[f.node]) {
+				w := adj[f.node][f.edge]
+				f.edge++
+				if _, ok := index[w]; !ok {
+					index[w], low[w] = next, next
+					next++
+					order = append(order, w)
+					stack = append(stack, w)
+					onStack[w] = true
+					call = append(call, frame{node: w})
+				} else if onStack[w] && index[w] < low[f.node] {
+					low[f.node] = index[w]
+				}
+				continue
+			}
+
+			v := f.node
+			call = call[:len(call)-1]
+			if len(call) > 0 {
+				if parent := call[len(call)-1].node; low[v] < low[parent] {
+					low[parent] = low[v]
+				}
+			}
+			if low[v] != index[v] {
+				continue
+			}
+			id := int32(len(r.size))
+			var size int32
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				r.scc[w] = id
+				size++
+				if w == v {
+					break
+				}
+			}
+			r.size = append(r.size, size)
+		}
+	}
+
+	r.dag = make([][]int32, len(r.size))
+	seen := make(map[[2]int32]bool)
+	for _, from := range order {
+		a := r.scc[from]
+		for _, to := range adj[from] {
+			b := r.scc[to]
+			if a == b || seen[[2]int32{a, b}] {
+				continue
+			}
+			seen[[2]int32{a, b}] = true
+			r.dag[a] = append(r.dag[a], b)
+		}
+	}
+}
+
+// label assigns pre/post intervals from a depth-first forest of the DAG,
+// starting at the SCCs nothing calls
+func (r *reachIndex) label() {
+	n := len(r.size)
+	r.pre = make([]int32, n)
+	r.post = make([]int32, n)
+	called := make([]bool, n)
+	for _, tos := range r.dag {
+		for _, to := range tos {
+			called[to] = true
+		}
+	}
+
+	visited := make([]bool, n)
+	counter := int32(0)
+	type frame struct {
+		scc  int32
+		edge int
+	}
+	// Roots have the highest numbers; visit them first so the forest is deep
+	for root := n - 1; root >= 0; root-- {
+		if called[root] || visited[root] {
+			continue
+		}
+		visited[root] = true
+		r.pre[root] = counter
+		counter++
+		call := []frame{{scc: int32(root)}}
+		for len(call) > 0 {
+			f := &call[len(call)-1]
+			if f.edge < len(r.dag[f.scc]) {
+				w := r.dag[f.scc][f.edge]
+				f.edge++
+				if !visited[w] {
+					visited[w] = true
+					r.pre[w] = counter
+					counter++
+					call = append(call, frame{scc: w})
+				}
+				continue
+			}
+			r.post[f.scc] = counter
+			counter++
+			call = call[:len(call)-1]
+		}
+	}
+}
+
+// countCallers counts, for every SCC, the functions that reach it: the
+// members of all SCCs above it, plus its own members when it is a cycle.
+// Ancestor sets are propagated as bitsets, one block of target SCCs at a time.
+func (r *reachIndex) countCallers() {
+	n := len(r.size)
+	r.total = make([]int32, n)
+	const words = reachBlockBits / 64
+	for lo := 0; lo < n; lo += reachBlockBits {
+		hi := min(lo+reachBlockBits, n)
+		// reach[u-lo] holds the targets in [lo, hi) that SCC u reaches.
+		// Only SCCs numbered lo or above can reach them.
+		reach := make([]uint64, (n-lo)*words)
+		row := func(u int) []uint64 { return reach[(u-lo)*words : (u-lo+1)*words] }
+		for u := lo; u < n; u++ {
+			bitsU := row(u)
+			if u < hi {
+				bitsU[(u-lo)/64] |= 1 << ((u - lo) % 64)
+			}
+			for _, c := range r.dag[u] {
+				if int(c) < lo {
+					continue
+				}
+				for i, w := range row(int(c)) {
+					bitsU[i] |= w
+				}
+			}
+		}
+		for u := lo; u < n; u++ {
+			for i, w := range row(u) {
+				for w != 0 {
+					t := lo + i*64 + bits.TrailingZeros64(w)
+					w &= w - 1
+					if t != u || r.size[u] > 1 {
+						r.total[t] += r.size[u]
+					}
+				}
+			}
+		}
+	}
+}
+
+// measureDepth computes the longest caller chain above every SCC, counting each
+// recursion cycle on the way as one level
+func (r *reachIndex) measureDepth() {
+	n := len(r.size)
+	r.depth = make([]int32, n)
+	// Callers are numbered higher, so depth[u] holds the longest chain from
+	// above by the time u is visited; a cycle adds its own level on top
+	for u := n - 1; u >= 0; u-- {
+		if r.size[u] > 1 {
+			r.depth[u]++
+		}
+		for _, c := range r.dag[u] {
+			if d := r.depth[u] + 1; d > r.depth[c] {
+				r.depth[c] = d
+			}
+		}
+	}
+}
+
+// reachesSCC reports whether SCC a reaches SCC b through at least one call.
+// dag, pre and post may be partial, covering the SCCs numbered b to a.
+func reachesSCC(a, b int32, size func(int32) int32, dag func(int32) []int32, pre, post func(int32) int32) bool {
+	if a == b {
+		return size(a) > 1
+	}
+	if a < b {
+		return false
+	}
+	inside := func(x int32) bool { return pre(x) <= pre(b) && post(b) <= post(x) }
+	if inside(a) {
+		return true
+	}
+	visited := map[int32]bool{a: true}
+	stack := []int32{a}
+	for len(stack) > 0 {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, c := range dag(x) {
+			if c == b || (c > b && inside(c)) {
+				return true
+			}
+			if c > b && !visited[c] {
+				visited[c] = true
+				stack = append(stack, c)
+			}
+		}
+	}
+	return false
+}
+
+// reaches reports whether function a calls function b, directly or not
+func (r *reachIndex) reaches(a, b int64) bool {
+	sa, ok1 := r.scc[a]
+	sb, ok2 := r.scc[b]
+	if !ok1 || !ok2 {
+		return false
+	}
+	return reachesSCC(sa, sb,
+		func(s int32) int32 { return r.size[s] },
+		func(s int32) []int32 { return r.dag[s] },
+		func(s int32) int32 { return r.pre[s] },
+		func(s int32) int32 { return r.post[s] },
+	)
+}
+
+// totalCallers returns the number of distinct functions that reach a node
+func (r *reachIndex) totalCallers(id int64) int {
+	if s, ok := r.scc[id]; ok {
+		return int(r.total[s])
+	}
+	return 0
+}
+
+// maxDepth returns the length of the longest caller chain above a node
+func (r *reachIndex) maxDepth(id int64) int {
+	if s, ok := r.scc[id]; ok {
+		return int(r.depth[s])
+	}
+	return 0
+}
+
+// buildReachability rebuilds the stored reachability index from the graph in tx
+func buildReachability(tx *sql.Tx) error {
+	var nodes []int64
+	rows, err := tx.Query(`SELECT id FROM nodes WHERE kind = 'func' ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		nodes = append(nodes, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var calls [][2]int64
+	rows, err = tx.Query(`SELECT e.from_id, e.to_id FROM edges e
+		JOIN nodes a ON a.id = e.from_id JOIN nodes b ON b.id = e.to_id
+		WHERE e.kind = 'calls'`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var c [2]int64
+		if err := rows.Scan(&c[0], &c[1]); err != nil {
+			rows.Close()
+			return err
+		}
+		calls = append(calls, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	r := buildReachIndex(nodes, calls)
+	return r.save(tx)
+}
+
+// save replaces the stored index
+func (r *reachIndex) save(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DELETE FROM reach_nodes; DELETE FROM reach_sccs; DELETE FROM reach_edges;`); err != nil {
+		return err
+	}
+	insertNode, err := tx.Prepare(`INSERT INTO reach_nodes (node_id, scc) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertNode.Close()
+	insertSCC, err := tx.Prepare(`INSERT INTO reach_sccs (scc, size, pre, post, total_callers, max_depth) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertSCC.Close()
+	insertEdge, err := tx.Prepare(`INSERT INTO reach_edges (from_scc, to_scc) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertEdge.Close()
+
+	for id, s := range r.scc {
+		if _, err := insertNode.Exec(id, s); err != nil {
+			return err
+		}
+	}
+	for s := range r.size {
+		if _, err := insertSCC.Exec(s, r.size[s], r.pre[s], r.post[s], r.total[s], r.depth[s]); err != nil {
+			return err
+		}
+		for _, c := range r.dag[s] {
+			if _, err := insertEdge.Exec(s, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reachStats returns the transitive caller count and longest caller chain of
+// a node from the stored index; ok is false for nodes outside the index
+func (db *DB) reachStats(nodeID int64) (total, depth int, ok bool, err error) {
+	err = db.conn.QueryRow(
+		`SELECT s.total_callers, s.max_depth FROM reach_nodes r JOIN reach_sccs s ON s.scc = r.scc WHERE r.node_id = ?`,
+		nodeID,
+	).Scan(&total, &depth)
+	if err == sql.ErrNoRows {
+		return 0, 0, false, nil
+	}
+	return total, depth, err == nil, err
+}
+
+// GetAllTotalCallers returns the transitive caller count of every function in
+// the stored index, by node ID
+func (db *DB) GetAllTotalCallers() (map[int64]int, error) {
+	rows, err := db.conn.Query(`SELECT r.node_id, s.total_callers FROM reach_nodes r JOIN reach_sccs s ON s.scc = r.scc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var total int
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		totals[id] = total
+	}
+	return totals, rows.Err()
+}
+
+// Reaches reports whether function from calls function to, directly or
+// through other functions. A function reaches itself only through recursion.
+func (db *DB) Reaches(fromID, toID int64) (bool, error) {
+	type label struct{ size, pre, post int32 }
+	labels := make(map[int32]label)
+	lookup := func(nodeID int64) (int32, bool, error) {
+		var s int32
+		var l label
+		err := db.conn.QueryRow(
+			`SELECT r.scc, s.size, s.pre, s.post FROM reach_nodes r JOIN reach_sccs s ON s.scc = r.scc WHERE r.node_id = ?`,
+			nodeID,
+		).Scan(&s, &l.size, &l.pre, &l.post)
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		labels[s] = l
+		return s, err == nil, err
+	}
+	a, ok, err := lookup(fromID)
+	if !ok || err != nil {
+		return false, err
+	}
+	b, ok, err := lookup(toID)
+	if !ok || err != nil {
+		return false, err
+	}
+
+	// The search only follows edges into SCCs numbered above b, loading
+	// them with their labels as it goes
+	stmt, err := db.conn.Prepare(`SELECT e.to_scc, s.size, s.pre, s.post FROM reach_edges e
+		JOIN reach_sccs s ON s.scc = e.to_scc WHERE e.from_scc = ? AND e.to_scc >= ?`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	var queryErr error
+	dag := func(x int32) []int32 {
+		if queryErr != nil {
+			return nil
+		}
+		rows, err := stmt.Query(x, b)
+		if err != nil {
+			queryErr = err
+			return nil
+		}
+		defer rows.Close()
+		var out []int32
+		for rows.Next() {
+			var c int32
+			var l label
+			if err := rows.Scan(&c, &l.size, &l.pre, &l.post); err != nil {
+				queryErr = err
+				return nil
+			}
+			labels[c] = l
+			out = append(out, c)
+		}
+		queryErr = rows.Err()
+		return out
+	}
+
+	found := reachesSCC(a, b,
+		func(s int32) int32 { return labels[s].size },
+		dag,
+		func(s int32) int32 { return labels[s].pre },
+		func(s int32) int32 { return labels[s].post },
+	)
+	return found, queryErr
+}
@@ -1,3 +1,5 @@
+-- 初始表结构 (schema 版本 1)，之后的变更见 migrations.go
+
 -- 节点表：存储函数、结构体、接口、变量、常量
 CREATE TABLE IF NOT EXISTS nodes (
     id INTEGER PRIMARY KEY,
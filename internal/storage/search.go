@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/zheng/crag/internal/graph"
+)
+
+// nodes_fts indexes every node for crag search. Its rowid is the node ID.
+//
+//	name  short name without the package path, e.g. "(*storage.DB).FindNodesByPattern"
+//	words camelCase parts of the identifiers in name, e.g. "find nodes by pattern"
+//	doc   doc comment
+//	sig   signature
+//	pkg   package path
+const createNodesFTS = `
+CREATE VIRTUAL TABLE IF NOT EXISTS nodes_fts USING fts5(name, words, doc, sig, pkg);`
+
+const insertNodeFTSSQL = `INSERT INTO nodes_fts (rowid, name, words, doc, sig, pkg) VALUES (?, ?, ?, ?, ?, ?)`
+
+// searchWeights are the BM25 weights of the nodes_fts columns, in column order:
+// name matches rank far above doc comment matches
+const searchWeights = "10.0, 5.0, 1.0, 2.0, 1.0"
+
+// searchFields maps query field prefixes to nodes_fts columns
+var searchFields = map[string]string{
+	"doc": "doc",
+	"sig": "sig",
+	"pkg": "pkg",
+}
+
+// pkgPathRe matches the import path part of a qualified name, e.g. "github.com/x/"
+var pkgPathRe = regexp.MustCompile(`[\w.\-~]+/`)
+
+func nodeFTSArgs(id int64, node *graph.Node) []any {
+	name := pkgPathRe.ReplaceAllString(node.Name, "")
+	return []any{id, name, ftsWords(name), node.Doc, node.Signature, node.Package}
+}
+
+// indexNode adds a node to the full-text index
+func indexNode(e execer, id int64, node *graph.Node) error {
+	_, err := e.Exec(insertNodeFTSSQL, nodeFTSArgs(id, node)...)
+	return err
+}
+
+// rebuildNodesFTS indexes all existing nodes; used when upgrading older databases
+func rebuildNodesFTS(tx *sql.Tx) error {
+	if _, err := tx.Exec(createNodesFTS); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM nodes_fts`); err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT id, kind, name, package, file, line, signature, doc, module, module_version, value FROM nodes`)
+	if err != nil {
+		return err
+	}
+	nodes, err := scanNodes(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := indexNode(tx, n.ID, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ftsWords splits the multi-part identifiers in name into lowercase words:
+// "(*storage.DB).FindNodesByPattern" -> "find nodes by pattern".
+// Single-word identifiers are already tokens of the name column.
+func ftsWords(name string) string {
+	var words []string
+	for _, ident := range splitIdents(name) {
+		if parts := splitCamel(ident); len(parts) > 1 {
+			words = append(words, parts...)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// splitIdents splits s on everything that is not a letter or digit
+func splitIdents(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// splitCamel splits an identifier into lowercase words at case changes:
+// "HTTPServerV2" -> ["http", "server", "v2"]
+func splitCamel(ident string) []string {
+	runes := []rune(ident)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		lowerToUpper := unicode.IsUpper(cur) && !unicode.IsUpper(prev)
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+	}
+	return append(words, strings.ToLower(string(runes[start:])))
+}
+
+// buildMatchQuery turns a search query into an FTS5 MATCH expression.
+//
+// Terms are ANDed. camelCase terms match consecutive words ("FindNodes" finds
+// FindNodesByPattern), a trailing * matches by prefix, "quoted words" match as
+// a phrase, and doc:, sig: and pkg: restrict a term to one field.
+func buildMatchQuery(query string) (string, error) {
+	var clauses []string
+	for _, tok := range splitQuery(query) {
+		column := ""
+		if i := strings.Index(tok, ":"); i > 0 {
+			if c, ok := searchFields[strings.ToLower(tok[:i])]; ok {
+				column, tok = c, tok[i+1:]
+			}
+		}
+
+		quoted := len(tok) >= 2 && strings.HasPrefix(tok, `"`) && strings.HasSuffix(tok, `"`)
+		prefix := strings.HasSuffix(tok, "*")
+		idents := splitIdents(tok)
+		if len(idents) == 0 {
+			continue
+		}
+
+		var phrases []string
+		if quoted {
+			var words []string
+			for _, ident := range idents {
+				words = append(words, splitCamel(ident)...)
+			}
+			phrases = append(phrases, `"`+strings.Join(words, " ")+`"`)
+		} else {
+			for i, ident := range idents {
+				phrase := `"` + strings.Join(splitCamel(ident), " ") + `"`
+				if prefix && i == len(idents)-1 {
+					phrase += "*"
+				}
+				phrases = append(phrases, phrase)
+			}
+		}
+
+		clause := strings.Join(phrases, " ")
+		if column != "" {
+			clause = column + " : (" + clause + ")"
+		} else if len(phrases) > 1 {
+			clause = "(" + clause + ")"
+		}
+		clauses = append(clauses, clause)
+	}
+
+	if len(clauses) == 0 {
+		return "", fmt.Errorf("搜索条件为空")
+	}
+	return strings.Join(clauses, " AND "), nil
+}
+
+// splitQuery splits a query on whitespace, keeping "quoted phrases" together
+func splitQuery(query string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+// SearchNodes runs a full-text search over node names, doc comments, signatures
+// and packages, ranked by BM25 (limit <= 0 = no limit). See buildMatchQuery for
+// the query syntax. A single plain term without full-text hits falls back to
+// FindNodesByPattern, so substrings inside identifiers are still found.
+func (db *DB) SearchNodes(query string, limit int) ([]*graph.Node, error) {
+	match, err := buildMatchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := db.conn.Query(
+		`SELECT n.id, n.kind, n.name, n.package, n.file, n.line, n.signature, n.doc, n.module, n.module_version, n.value
+		 FROM nodes_fts
+		 JOIN nodes n ON n.id = nodes_fts.rowid
+		 WHERE nodes_fts MATCH ?
+		 ORDER BY bm25(nodes_fts, `+searchWeights+`), length(n.name) ASC
+		 LIMIT ?`,
+		match, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := scanNodes(rows)
+	rows.Close()
+	if err != nil || len(nodes) > 0 {
+		return nodes, err
+	}
+
+	terms := splitQuery(query)
+	if len(terms) != 1 || strings.ContainsAny(terms[0], `:"*`) {
+		return nil, nil
+	}
+	nodes, err = db.FindNodesByPattern(terms[0])
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	return nodes, nil
+}
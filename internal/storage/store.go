@@ -0,0 +1,78 @@
+package storage
+
+import "github.com/zheng/crag/internal/graph"
+
+// Store is the query interface over a stored graph. Apart from user notes it is read-only.
+// It is implemented by the SQLite database (*DB) and by the in-memory
+// adjacency graph (*MemStore), which answers traversals without recursive SQL.
+type Store interface {
+	// Node lookup
+	GetNodeByName(name string) (*graph.Node, error)
+	GetNodeByID(id int64) (*graph.Node, error)
+	FindNodesByPattern(pattern string) ([]*graph.Node, error)
+	SearchNodes(query string, limit int) ([]*graph.Node, error)
+	GetAllFunctions() ([]*graph.Node, error)
+	GetNodesByKinds(kinds ...graph.NodeKind) ([]*graph.Node, error)
+	GetNodesByPackage(packages []string) ([]*graph.Node, error)
+	GetNodesInFiles(files []string) ([]*graph.Node, error)
+	GetAllEdges() ([]*graph.Edge, error)
+	GetStats() (nodeCount, edgeCount int64, err error)
+	GetMetadata() (*graph.Metadata, error)
+
+	// Call graph traversal
+	GetDirectCallers(nodeID int64) ([]*graph.Node, error)
+	GetDirectCallees(nodeID int64) ([]*graph.Node, error)
+	GetUpstreamCallers(nodeID int64, maxDepth int) ([]*graph.Node, error)
+	GetDownstreamCallees(nodeID int64, maxDepth int) ([]*graph.Node, error)
+	GetUpstreamCallersWithDepth(nodeID int64, maxDepth int) ([]*NodeWithDepth, error)
+	GetDownstreamCalleesWithDepth(nodeID int64, maxDepth int) ([]*NodeWithDepth, error)
+	GetUpstreamCallTree(nodeID int64, maxDepth int) ([]*CallTreeNode, error)
+	GetDownstreamCallTree(nodeID int64, maxDepth int) ([]*CallTreeNode, error)
+	GetCallEdgesForNode(nodeID int64) ([]*graph.Edge, error)
+	Reaches(fromID, toID int64) (bool, error)
+
+	// Interfaces and types
+	GetAllInterfaces() ([]*graph.Node, error)
+	FindInterfacesByPattern(pattern string) ([]*graph.Node, error)
+	GetImplementations(interfaceID int64) ([]*graph.Node, error)
+	GetImplementedInterfaces(typeID int64) ([]*graph.Node, error)
+	GetAllTypes() ([]*graph.Node, error)
+	GetTypeAssertingFunctions(typeID int64) ([]*graph.Node, error)
+
+	// Variables and constants
+	GetAllVars() ([]*graph.Node, error)
+	GetAllConsts() ([]*graph.Node, error)
+	GetReferencingFunctions(nodeID int64) ([]*graph.Node, error)
+	GetReferencedVarConsts(funcID int64) ([]*graph.Node, error)
+	GetDerivedVarConsts(nodeID int64) ([]*graph.Node, error)
+
+	// Risk and summaries
+	GetDirectCallerCount(nodeID int64) (int, error)
+	GetTotalCallerCount(nodeID int64) (int, error)
+	GetMaxCallDepth(nodeID int64) (int, error)
+	GetRiskScore(nodeID int64) (*RiskScore, error)
+	GetTopRiskyFunctions(limit int) ([]*RiskScore, error)
+	GetSummaryByKind() (map[string]int64, error)
+	GetPackageSummary() ([]*PackageSummary, error)
+
+	// Struct fields and tags
+	FindFieldsByTag(key, name string) ([]*TaggedField, error)
+	GetFieldTags(fieldID int64) ([]*graph.FieldTag, error)
+	GetFieldOwner(fieldID int64) (*graph.Node, error)
+	GetStructFields(structID int64) ([]*graph.Node, error)
+	GetStructUsers(structID int64) ([]*TypeUsage, error)
+
+	// Enums
+	GetEnumMembers(enumID int64) ([]*graph.Node, error)
+	GetEnumSwitches(enumID int64) ([]*EnumSwitchUsage, error)
+
+	// User notes, keyed by node name so they survive re-analysis
+	AddNote(nodeName, text string) (*graph.Note, error)
+	GetNotes(nodeName string) ([]*graph.Note, error)
+	GetAllNotes() ([]*graph.Note, error)
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*MemStore)(nil)
+)
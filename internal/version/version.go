@@ -0,0 +1,19 @@
+// Package version reports the crag build version.
+package version
+
+import "runtime/debug"
+
+// Version is set at build time via -ldflags "-X github.com/zheng/crag/internal/version.Version=v1.2.3"
+var Version = "dev"
+
+// String returns the build version, falling back to the module version
+// recorded by `go install` when Version was not set
+func String() string {
+	if Version != "dev" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return Version
+}
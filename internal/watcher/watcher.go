@@ -20,6 +20,11 @@ type Watcher struct {
 	dbPath      string
 	fsWatcher   *fsnotify.Watcher
 
+	// Extra dependency module patterns analyzed as project code
+	includeModules []string
+	// External interfaces checked against project types (nil means defaults)
+	externalIfaces []string
+
 	// Debouncing
 	debounceDelay time.Duration
 	pendingFiles  map[string]struct{}
@@ -45,6 +50,20 @@ func WithDebounceDelay(d time.Duration) WatcherOption {
 	}
 }
 
+// WithIncludeModules sets dependency module patterns to analyze as project code
+func WithIncludeModules(patterns []string) WatcherOption {
+	return func(w *Watcher) {
+		w.includeModules = patterns
+	}
+}
+
+// WithExternalInterfaces sets the external interfaces checked against project types
+func WithExternalInterfaces(names []string) WatcherOption {
+	return func(w *Watcher) {
+		w.externalIfaces = names
+	}
+}
+
 // WithOnAnalysisStart sets the callback for when analysis starts
 func WithOnAnalysisStart(fn func()) WatcherOption {
 	return func(w *Watcher) {
@@ -225,8 +244,10 @@ func (w *Watcher) triggerAnalysis() {
 
 // runAnalysis performs the actual code analysis
 func (w *Watcher) runAnalysis() (nodeCount, edgeCount int64, err error) {
+	start := time.Now()
+
 	// Load packages
-	pkgs, err := analyzer.LoadPackages(w.projectPath)
+	pkgs, err := analyzer.LoadPackages(w.projectPath, w.includeModules...)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to load packages: %w", err)
 	}
@@ -237,8 +258,14 @@ func (w *Watcher) runAnalysis() (nodeCount, edgeCount int64, err error) {
 		return 0, 0, fmt.Errorf("no valid Go packages found")
 	}
 
-	// Build SSA
-	prog, _ := analyzer.BuildSSA(pkgs)
+	// Build SSA; an ill-typed package (e.g. a file mid-edit) would leave a hole
+	// in the graph, so keep the previous one instead
+	prog, ssaPkgs := analyzer.BuildSSA(pkgs)
+	for i, ssaPkg := range ssaPkgs {
+		if ssaPkg == nil {
+			return 0, 0, fmt.Errorf("failed to build SSA for %s: package has type errors", pkgs[i].PkgPath)
+		}
+	}
 
 	// Build call graph
 	cg, err := analyzer.BuildCallGraph(prog)
@@ -253,8 +280,15 @@ func (w *Watcher) runAnalysis() (nodeCount, edgeCount int64, err error) {
 	}
 	defer db.Close()
 
+	// Write everything in one transaction; on failure the previous graph is kept
+	batch, err := db.BeginBatch(storage.BatchOptions{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer batch.Rollback()
+
 	// Clear existing data
-	if err := db.Clear(); err != nil {
+	if err := batch.Clear(); err != nil {
 		return 0, 0, fmt.Errorf("failed to clear database: %w", err)
 	}
 
@@ -263,21 +297,68 @@ func (w *Watcher) runAnalysis() (nodeCount, edgeCount int64, err error) {
 		prog.Fset,
 		pkgs,
 		w.projectPath,
-		db.InsertNode,
-		db.InsertEdge,
+		batch.InsertNode,
+		batch.InsertEdge,
 	)
 
+	builder.SetExternalCallFn(batch.InsertExternalCall)
+
 	if err := builder.Build(cg); err != nil {
 		return 0, 0, fmt.Errorf("failed to build graph: %w", err)
 	}
 
 	// Build interface implementation graph
 	interfaceAnalyzer := analyzer.NewInterfaceAnalyzer(pkgs, w.projectPath)
-	interfaceAnalyzer.BuildInterfaceGraph(db.InsertNode, db.InsertEdge)
+	if w.externalIfaces != nil {
+		interfaceAnalyzer.SetExternalInterfaces(w.externalIfaces)
+	}
+	if _, _, _, err := interfaceAnalyzer.BuildInterfaceGraph(batch.InsertNode, batch.InsertEdge); err != nil {
+		return 0, 0, fmt.Errorf("failed to build interface graph: %w", err)
+	}
+	if _, err := builder.BuildTypeAssertions(prog, interfaceAnalyzer.GetNamedTypeNodeMap()); err != nil {
+		return 0, 0, fmt.Errorf("failed to build type assertions: %w", err)
+	}
+	if _, _, _, err := interfaceAnalyzer.BuildInterfaceMethodGraph(batch.InsertNode, batch.InsertEdge, builder.GetNodeMap()); err != nil {
+		return 0, 0, fmt.Errorf("failed to build interface method graph: %w", err)
+	}
 
 	// Build var/const reference graph
 	varConstAnalyzer := analyzer.NewVarConstAnalyzer(pkgs, w.projectPath)
-	varConstAnalyzer.BuildVarConstGraph(db.InsertNode, db.InsertEdge, builder.GetNodeMap())
+	if _, _, _, err := varConstAnalyzer.BuildVarConstGraph(batch.InsertNode, batch.InsertEdge, builder.GetNodeMap()); err != nil {
+		return 0, 0, fmt.Errorf("failed to build var/const graph: %w", err)
+	}
+	if _, _, _, err := varConstAnalyzer.BuildEnumGraph(batch.InsertNode, batch.InsertEdge, batch.InsertEnumSwitch, builder.GetNodeMap()); err != nil {
+		return 0, 0, fmt.Errorf("failed to build enum graph: %w", err)
+	}
+
+	// Build struct field / tag graph
+	structTagAnalyzer := analyzer.NewStructTagAnalyzer(pkgs, w.projectPath)
+	if _, _, _, err := structTagAnalyzer.BuildStructTagGraph(batch.InsertNode, batch.InsertEdge, batch.InsertFieldTag,
+		interfaceAnalyzer.GetTypeNodeMap(), builder.GetNodeMap()); err != nil {
+		return 0, 0, fmt.Errorf("failed to build struct tag graph: %w", err)
+	}
+
+	// Record the source file hashes so that a later crag analyze -i can compare against them
+	files, err := analyzer.ScanSourceFiles(w.projectPath, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to scan source files: %w", err)
+	}
+	analyzer.SetFilePackages(files, w.projectPath, pkgs)
+	if err := batch.SaveSourceFiles(files); err != nil {
+		return 0, 0, fmt.Errorf("failed to save source files: %w", err)
+	}
+
+	// Record how this graph was built
+	meta := analyzer.CollectMetadata(w.projectPath, pkgs, analyzer.AnalysisFlags(w.includeModules, w.externalIfaces))
+	meta.DurationMs = time.Since(start).Milliseconds()
+	meta.AnalyzedAt = time.Now()
+	if err := batch.SaveMetadata(meta); err != nil {
+		return 0, 0, fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	if err := batch.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit analysis: %w", err)
+	}
 
 	nodeCount, edgeCount, _ = db.GetStats()
 	return nodeCount, edgeCount, nil
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"database/sql"
 	"embed"
 	"encoding/json"
 	"fmt"
@@ -19,12 +20,12 @@ var staticFS embed.FS
 
 // Server is the web server for visualizing call graphs
 type Server struct {
-	db   *storage.DB
+	db   storage.Store
 	port int
 }
 
 // NewServer creates a new web server
-func NewServer(db *storage.DB, port int) *Server {
+func NewServer(db storage.Store, port int) *Server {
 	return &Server{db: db, port: port}
 }
 
@@ -155,30 +156,31 @@ func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
 
 // handleNode returns a single node with its connections
 func (s *Server) handleNode(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/node/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
+	id, ok := storage.ParseNodeID(strings.TrimPrefix(r.URL.Path, "/api/node/"))
+	if !ok {
 		http.Error(w, "Invalid node ID", http.StatusBadRequest)
 		return
 	}
 
 	node, err := s.db.GetNodeByID(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err == sql.ErrNoRows || (err == nil && node == nil) {
+		http.Error(w, "Node not found", http.StatusNotFound)
 		return
 	}
-	if node == nil {
-		http.Error(w, "Node not found", http.StatusNotFound)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	callers, _ := s.db.GetDirectCallers(id)
 	callees, _ := s.db.GetDirectCallees(id)
+	notes, _ := s.db.GetNotes(node.Name)
 
 	result := map[string]interface{}{
 		"node":    nodeToData(node),
 		"callers": nodesToData(callers),
 		"callees": nodesToData(callees),
+		"notes":   notes,
 	}
 
 	writeJSON(w, result)
@@ -186,9 +188,8 @@ func (s *Server) handleNode(w http.ResponseWriter, r *http.Request) {
 
 // handleImpact returns impact analysis for a node
 func (s *Server) handleImpact(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/impact/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
+	id, ok := storage.ParseNodeID(strings.TrimPrefix(r.URL.Path, "/api/impact/"))
+	if !ok {
 		http.Error(w, "Invalid node ID", http.StatusBadRequest)
 		return
 	}
@@ -220,9 +221,8 @@ func (s *Server) handleImpact(w http.ResponseWriter, r *http.Request) {
 
 // handleCallChain returns hierarchical call chain for a node
 func (s *Server) handleCallChain(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/chain/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
+	id, ok := storage.ParseNodeID(strings.TrimPrefix(r.URL.Path, "/api/chain/"))
+	if !ok {
 		http.Error(w, "Invalid node ID", http.StatusBadRequest)
 		return
 	}
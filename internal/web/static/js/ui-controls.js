@@ -276,6 +276,16 @@ function showNodeDetail(data) {
     `;
   }
 
+  if (data.notes && data.notes.length > 0) {
+    const notes = data.notes.map(n => `📝 ${escapeHtml(n.text)}`).join('\n');
+    html += `
+      <div class="detail-row">
+        <span class="detail-label">注释</span>
+        <span class="detail-value" style="white-space: pre-wrap; font-size: 12px;">${notes}</span>
+      </div>
+    `;
+  }
+
   content.innerHTML = html;
   panel.style.display = 'block';
 }
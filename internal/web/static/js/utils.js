@@ -21,6 +21,15 @@ function escapeLabel(str) {
     .replace(/\]/g, '❳');
 }
 
+// Escape text for insertion into HTML
+function escapeHtml(str) {
+  return String(str)
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;');
+}
+
 // Copy text to clipboard with fallback
 function copyToClipboard(text, successMessage) {
   navigator.clipboard.writeText(text).then(() => {
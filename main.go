@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/zheng/crag/cmd"
+	"github.com/zheng/crag/internal/version"
 )
 
 func main() {
@@ -14,6 +15,7 @@ func main() {
 		Short: "Code RAG - Go代码调用图分析工具",
 		Long: `crag 是一个 Go 代码静态分析工具，用于构建函数调用图谱，
 帮助追踪代码变更的影响范围，减少 AI 编码时的漏改问题。`,
+		Version: version.String(),
 	}
 
 	// Global flags